@@ -19,22 +19,29 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/balance/": {
-            "get": {
+        "/admin/account/close/": {
+            "post": {
+                "description": "Закрыть можно только счет с нулевым балансом и без открытых резерваций. Закрытый счет не принимает операций",
                 "tags": [
-                    "Balance"
+                    "Account"
                 ],
-                "summary": "Получение баланса пользователя",
-                "operationId": "get-balance",
+                "summary": "Закрытие счета пользователя",
+                "operationId": "account-close",
                 "parameters": [
                     {
-                        "description": "User ID",
-                        "name": "user_id",
+                        "description": "Account",
+                        "name": "status",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/BalanceGetRequest"
+                            "$ref": "#/definitions/AccountStatusRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -42,6 +49,12 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/Balance"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Balance version"
+                            }
                         }
                     },
                     "400": {
@@ -56,6 +69,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/AppError"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
                     "418": {
                         "description": "I'm a teapot",
                         "schema": {
@@ -65,30 +90,42 @@ const docTemplate = `{
                 }
             }
         },
-        "/balance/reduce/": {
+        "/admin/account/credit-limit/": {
             "post": {
-                "description": "В случае уменьшения баланса ранее не упомянутого пользователя, он НЕ создается в БД (возвращается 404)",
+                "description": "Баланс пользователя сможет уйти в минус не больше чем на credit_limit. Нельзя установить лимит меньше текущего долга",
                 "tags": [
-                    "Balance"
+                    "Account"
                 ],
-                "summary": "Уменьшает баланс пользователя",
-                "operationId": "reduce-balance",
+                "summary": "Установка кредитного лимита пользователя",
+                "operationId": "account-credit-limit",
                 "parameters": [
                     {
-                        "description": "User balance",
-                        "name": "balance",
+                        "description": "Credit limit",
+                        "name": "credit_limit",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/BalanceChangeRequest"
+                            "$ref": "#/definitions/CreditLimitRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/BalanceChangeRequest"
+                            "$ref": "#/definitions/Balance"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Balance version"
+                            }
                         }
                     },
                     "400": {
@@ -97,6 +134,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/AppError"
                         }
                     },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
                     "418": {
                         "description": "I'm a teapot",
                         "schema": {
@@ -106,30 +149,42 @@ const docTemplate = `{
                 }
             }
         },
-        "/balance/replenish/": {
+        "/admin/account/freeze/": {
             "post": {
-                "description": "В случае пополнения баланса ранее не упомянутого пользователя, он создается в БД",
+                "description": "Замороженный счет принимает пополнения, переводы и отмены резервов, но не допускает списаний",
                 "tags": [
-                    "Balance"
+                    "Account"
                 ],
-                "summary": "Пополняет баланс пользователя",
-                "operationId": "replenish-balance",
+                "summary": "Заморозка счета пользователя",
+                "operationId": "account-freeze",
                 "parameters": [
                     {
-                        "description": "User balance",
-                        "name": "balance",
+                        "description": "Account",
+                        "name": "status",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/BalanceChangeRequest"
+                            "$ref": "#/definitions/AccountStatusRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/BalanceChangeRequest"
+                            "$ref": "#/definitions/Balance"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Balance version"
+                            }
                         }
                     },
                     "400": {
@@ -138,6 +193,24 @@ const docTemplate = `{
                             "$ref": "#/definitions/AppError"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
                     "418": {
                         "description": "I'm a teapot",
                         "schema": {
@@ -147,27 +220,43 @@ const docTemplate = `{
                 }
             }
         },
-        "/balance/transfer/": {
+        "/admin/account/unfreeze/": {
             "post": {
+                "description": "Возвращает замороженный счет в статус active",
                 "tags": [
-                    "Balance"
+                    "Account"
                 ],
-                "summary": "Переводит деньги с одного счета на другой",
-                "operationId": "transfer-balance",
+                "summary": "Разморозка счета пользователя",
+                "operationId": "account-unfreeze",
                 "parameters": [
                     {
-                        "description": "Transfer money",
-                        "name": "balance",
+                        "description": "Account",
+                        "name": "status",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/TransferRequest"
+                            "$ref": "#/definitions/AccountStatusRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/Balance"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Balance version"
+                            }
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -175,6 +264,24 @@ const docTemplate = `{
                             "$ref": "#/definitions/AppError"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
                     "418": {
                         "description": "I'm a teapot",
                         "schema": {
@@ -184,21 +291,22 @@ const docTemplate = `{
                 }
             }
         },
-        "/history/": {
+        "/admin/fee/": {
             "get": {
+                "description": "Есть необязательные фильтры по операции, услуге и активности",
                 "tags": [
-                    "History"
+                    "Fee"
                 ],
-                "summary": "Получение истории баланса пользователя",
-                "operationId": "get-balance-history",
+                "summary": "Список правил комиссий",
+                "operationId": "get-fee-rules",
                 "parameters": [
                     {
-                        "description": "User ID",
-                        "name": "user_id",
+                        "description": "Fee rules filter",
+                        "name": "fee_rules",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/BalanceHistory"
+                            "$ref": "#/definitions/FeeRuleListRequest"
                         }
                     }
                 ],
@@ -208,7 +316,7 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/HistoryRow"
+                                "$ref": "#/definitions/FeeRule"
                             }
                         }
                     },
@@ -218,8 +326,47 @@ const docTemplate = `{
                             "$ref": "#/definitions/AppError"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Комиссия равна flat + percent% от суммы операции, но не меньше min_fee и не больше max_fee.\nЗа перевод комиссия списывается с отправителя, за резервирование и подтверждение - с пользователя резерва\n(за подтверждение - от подтвержденной суммы). Правило услуги важнее общего правила операции.\nНовое правило заменяет действовавшее правило той же операции и услуги",
+                "tags": [
+                    "Fee"
+                ],
+                "summary": "Установка комиссии за операцию",
+                "operationId": "create-fee-rule",
+                "parameters": [
+                    {
+                        "description": "Fee rule",
+                        "name": "fee_rule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/FeeRuleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/FeeRule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/AppError"
                         }
@@ -233,22 +380,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/report/": {
+        "/admin/fee/deactivate/": {
             "post": {
-                "description": "Отчет пересоздается только за текущий месяц",
                 "tags": [
-                    "Report"
+                    "Fee"
                 ],
-                "summary": "Получение ссылки на сформированный отчет",
-                "operationId": "get-report",
+                "summary": "Отключение правила комиссии",
+                "operationId": "deactivate-fee-rule",
                 "parameters": [
                     {
-                        "description": "Report options",
-                        "name": "report",
+                        "description": "Fee rule ID",
+                        "name": "fee_rule_id",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/ReportRequest"
+                            "$ref": "#/definitions/FeeRuleIDRequest"
                         }
                     }
                 ],
@@ -256,10 +402,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/ReportResponse"
-                            }
+                            "$ref": "#/definitions/FeeRule"
                         }
                     },
                     "400": {
@@ -268,6 +411,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/AppError"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
                     "418": {
                         "description": "I'm a teapot",
                         "schema": {
@@ -277,27 +426,34 @@ const docTemplate = `{
                 }
             }
         },
-        "/reservation/cancel/": {
-            "post": {
+        "/admin/limit/": {
+            "get": {
+                "description": "Действующие лимиты пользователя, использованная в текущем периоде сумма, остаток и время обновления лимита",
                 "tags": [
-                    "Reservation"
+                    "Limit"
                 ],
-                "summary": "Отмена резервации денег за услугу",
-                "operationId": "reservation-cancel",
+                "summary": "Остаток лимитов пользователя",
+                "operationId": "get-limit-usage",
                 "parameters": [
                     {
-                        "description": "Reservation",
-                        "name": "reservation",
+                        "description": "User ID",
+                        "name": "user_id",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/Reservation"
+                            "$ref": "#/definitions/LimitUsageRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/LimitUsage"
+                            }
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -305,8 +461,44 @@ const docTemplate = `{
                             "$ref": "#/definitions/AppError"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Лимит суммы списаний (reduce), исходящих переводов (transfer) или резервирования (reserve) за календарный\nдень, неделю или месяц в UTC либо максимальная сумма одной операции (single). Лимит пользователя заменяет\nобщий лимит из конфигурации. Операция сверх лимита отклоняется с кодом 422",
+                "tags": [
+                    "Limit"
+                ],
+                "summary": "Установка лимита пользователя",
+                "operationId": "set-limit",
+                "parameters": [
+                    {
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/LimitRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/LimitUsage"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/AppError"
                         }
@@ -320,27 +512,34 @@ const docTemplate = `{
                 }
             }
         },
-        "/reservation/confirm/": {
+        "/admin/limit/reset/": {
             "post": {
+                "description": "После удаления для пользователя действует общий лимит из конфигурации, если он задан",
                 "tags": [
-                    "Reservation"
+                    "Limit"
                 ],
-                "summary": "Подтверждение списывания денег за услугу",
-                "operationId": "reservation-confirm",
+                "summary": "Удаление лимита пользователя",
+                "operationId": "reset-limit",
                 "parameters": [
                     {
-                        "description": "Reservation",
-                        "name": "reservation",
+                        "description": "Limit",
+                        "name": "limit",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/Reservation"
+                            "$ref": "#/definitions/LimitResetRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/LimitUsage"
+                            }
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -363,27 +562,34 @@ const docTemplate = `{
                 }
             }
         },
-        "/reservation/reserve/": {
-            "post": {
+        "/admin/review/": {
+            "get": {
+                "description": "Списания и переводы с высокой оценкой риска. Есть необязательная пагинация (limit, offset) и фильтры\nпо статусу и пользователю, сортировка по дате создания",
                 "tags": [
-                    "Reservation"
+                    "Review"
                 ],
-                "summary": "Резервация денег на услугу",
-                "operationId": "reservation-reserve",
+                "summary": "Очередь операций на ручной проверке",
+                "operationId": "get-reviews",
                 "parameters": [
                     {
-                        "description": "Reservation",
-                        "name": "reservation",
+                        "description": "Reviews filter",
+                        "name": "reviews",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/Reservation"
+                            "$ref": "#/definitions/ReviewListRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Review"
+                            }
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -399,245 +605,3525 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "AppError": {
-            "type": "object",
-            "properties": {
-                "developer_message": {
-                    "description": "Сообщение для разработчика",
-                    "type": "string"
-                },
-                "message": {
-                    "description": "Сообщение",
-                    "type": "string"
-                }
-            }
         },
-        "Balance": {
-            "type": "object",
-            "required": [
-                "balance",
-                "user_id"
-            ],
-            "properties": {
-                "balance": {
-                    "description": "Баланс пользователя",
-                    "type": "number",
+        "/admin/review/approve/": {
+            "post": {
+                "description": "Исходный запрос выполняется с его ключом идемпотентности. Если операция не выполнилась (например, не хватило\nденег), заявка получает статус failed с описанием ошибки и может быть одобрена повторно",
+                "tags": [
+                    "Review"
+                ],
+                "summary": "Одобрение отложенной операции",
+                "operationId": "approve-review",
+                "parameters": [
+                    {
+                        "description": "Review decision",
+                        "name": "review",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ReviewDecisionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/Review"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/review/reject/": {
+            "post": {
+                "tags": [
+                    "Review"
+                ],
+                "summary": "Отклонение отложенной операции",
+                "operationId": "reject-review",
+                "parameters": [
+                    {
+                        "description": "Review decision",
+                        "name": "review",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ReviewDecisionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/Review"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/service/": {
+            "get": {
+                "description": "Есть необязательная пагинация (limit, offset) и фильтр по активности, сортировка по названию",
+                "tags": [
+                    "Catalog"
+                ],
+                "summary": "Список услуг каталога",
+                "operationId": "get-services",
+                "parameters": [
+                    {
+                        "description": "Services filter",
+                        "name": "services",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ServiceListRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Service"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Если service_id не передан, он генерируется. Название услуги должно быть уникальным",
+                "tags": [
+                    "Catalog"
+                ],
+                "summary": "Добавление услуги в каталог",
+                "operationId": "create-service",
+                "parameters": [
+                    {
+                        "description": "Service",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ServiceCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/Service"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/service/activate/": {
+            "post": {
+                "tags": [
+                    "Catalog"
+                ],
+                "summary": "Повторное включение отключенной услуги",
+                "operationId": "activate-service",
+                "parameters": [
+                    {
+                        "description": "Service ID",
+                        "name": "service_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ServiceIDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/Service"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/service/deactivate/": {
+            "post": {
+                "description": "Новые резервы на отключенную услугу отклоняются. Открытые резервы можно подтвердить или отменить,\nистория и отчеты по услуге сохраняются",
+                "tags": [
+                    "Catalog"
+                ],
+                "summary": "Отключение услуги",
+                "operationId": "deactivate-service",
+                "parameters": [
+                    {
+                        "description": "Service ID",
+                        "name": "service_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ServiceIDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/Service"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/service/price/": {
+            "get": {
+                "description": "Все цены услуги, включая прошедшие и будущие, сортировка по valid_from в desc",
+                "tags": [
+                    "Catalog"
+                ],
+                "summary": "Прайс-лист услуги",
+                "operationId": "get-service-prices",
+                "parameters": [
+                    {
+                        "description": "Service ID",
+                        "name": "service_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ServiceIDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ServicePrice"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Цена задается фиксированной (price) либо диапазоном (min_price, max_price, одна из границ необязательна).\nПри резервировании действует цена с наибольшим valid_from среди периодов, в которые попадает время резервирования.\nЕсли у услуги нет действующей цены, стоимость резерва не проверяется",
+                "tags": [
+                    "Catalog"
+                ],
+                "summary": "Добавление цены услуги в прайс-лист",
+                "operationId": "create-service-price",
+                "parameters": [
+                    {
+                        "description": "Service price",
+                        "name": "price",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ServicePriceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/ServicePrice"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/service/rename/": {
+            "post": {
+                "description": "История и отчеты показывают новое название, в том числе для прошлых операций",
+                "tags": [
+                    "Catalog"
+                ],
+                "summary": "Переименование услуги",
+                "operationId": "rename-service",
+                "parameters": [
+                    {
+                        "description": "Service",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ServiceRenameRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/Service"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/balance/": {
+            "get": {
+                "description": "Если передан as_of, доступный и зарезервированный остатки считаются на этот момент по истории операций",
+                "tags": [
+                    "Balance"
+                ],
+                "summary": "Получение баланса пользователя",
+                "operationId": "get-balance",
+                "parameters": [
+                    {
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/BalanceGetRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/Balance"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Balance version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/balance/batch/": {
+            "post": {
+                "description": "Операции выполняются по порядку в одной транзакции. В режиме atomic ошибка любой операции отменяет весь пакет,\nв режиме best_effort применяются все успешные операции. Для каждой операции возвращается новый баланс или ошибка.\nСписания и переводы с высокой оценкой риска не выполняются и получают статус pending_review с review_id заявки.\nЗаголовок If-Match не поддерживается (возвращается 400)",
+                "tags": [
+                    "Balance"
+                ],
+                "summary": "Выполняет пакет пополнений, списаний и переводов",
+                "operationId": "batch-balance",
+                "parameters": [
+                    {
+                        "description": "Batch",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/BatchRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Idempotency key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/BatchResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/balance/reduce/": {
+            "post": {
+                "description": "В случае уменьшения баланса ранее не упомянутого пользователя, он НЕ создается в БД (возвращается 404).\nСписание с высокой оценкой риска не выполняется, а отправляется на ручную проверку (возвращается 202)",
+                "tags": [
+                    "Balance"
+                ],
+                "summary": "Уменьшает баланс пользователя",
+                "operationId": "reduce-balance",
+                "parameters": [
+                    {
+                        "description": "User balance",
+                        "name": "balance",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/BalanceChangeRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Idempotency key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/BalanceChangeRequest"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Balance version after the operation"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/Review"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/balance/replenish/": {
+            "post": {
+                "description": "В случае пополнения баланса ранее не упомянутого пользователя, он создается в БД",
+                "tags": [
+                    "Balance"
+                ],
+                "summary": "Пополняет баланс пользователя",
+                "operationId": "replenish-balance",
+                "parameters": [
+                    {
+                        "description": "User balance",
+                        "name": "balance",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/BalanceChangeRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Idempotency key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/BalanceChangeRequest"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Balance version after the operation"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/balance/transfer/": {
+            "post": {
+                "description": "С отправителя дополнительно списывается комиссия за перевод, если она настроена.\nПеревод с высокой оценкой риска не выполняется, а отправляется на ручную проверку (возвращается 202)",
+                "tags": [
+                    "Balance"
+                ],
+                "summary": "Переводит деньги с одного счета на другой",
+                "operationId": "transfer-balance",
+                "parameters": [
+                    {
+                        "description": "Transfer money",
+                        "name": "balance",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/TransferRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Idempotency key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/Review"
+                        }
+                    },
+                    "204": {
+                        "description": "No Content",
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Sender balance version after the operation"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/balance/transfer/split/": {
+            "post": {
+                "description": "Все зачисления выполняются в одной транзакции. В истории отправителя перевод отображается одной строкой,\nу каждого получателя - своей строкой с тем же group_id. Перевод с высокой оценкой риска не выполняется,\nа отправляется на проверку (202)",
+                "tags": [
+                    "Balance"
+                ],
+                "summary": "Переводит деньги от одного отправителя нескольким получателям",
+                "operationId": "split-transfer-balance",
+                "parameters": [
+                    {
+                        "description": "Split transfer",
+                        "name": "transfer",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/SplitTransferRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Idempotency key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/SplitTransfer"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Sender balance version after the operation"
+                            }
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/Review"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/history/": {
+            "get": {
+                "tags": [
+                    "History"
+                ],
+                "summary": "Получение истории баланса пользователя",
+                "operationId": "get-balance-history",
+                "parameters": [
+                    {
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/BalanceHistory"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/HistoryRow"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/report/": {
+            "post": {
+                "description": "Отчет пересоздается только за текущий месяц",
+                "tags": [
+                    "Report"
+                ],
+                "summary": "Получение ссылки на сформированный отчет",
+                "operationId": "get-report",
+                "parameters": [
+                    {
+                        "description": "Report options",
+                        "name": "report",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ReportRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ReportResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservation/": {
+            "get": {
+                "description": "Фильтры по user_id, order_id, service_id и возрасту резерва (older_than, в секундах), необязательная пагинация\n(limit, offset), сортировка по дате создания в asc. Итоги total и held считаются по всем резервам под фильтром",
+                "tags": [
+                    "Reservation"
+                ],
+                "summary": "Список действующих резервов",
+                "operationId": "get-open-reservations",
+                "parameters": [
+                    {
+                        "description": "Reservations filter",
+                        "name": "filter",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReservationListRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ReservationList"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservation/adjust/": {
+            "post": {
+                "description": "Резерв указывается по reservation_id либо по order_id и service_id. Разница между новой и текущей суммой списывается с баланса\nили возвращается на него, каждое изменение сохраняется и отображается в истории с типом adjust",
+                "tags": [
+                    "Reservation"
+                ],
+                "summary": "Изменение суммы действующего резерва",
+                "operationId": "reservation-adjust",
+                "parameters": [
+                    {
+                        "description": "Reservation adjustment",
+                        "name": "adjustment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReservationAdjustRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Idempotency key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ReservationAdjustment"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservation/cancel/": {
+            "post": {
+                "description": "Резерв указывается по reservation_id либо по order_id и service_id",
+                "tags": [
+                    "Reservation"
+                ],
+                "summary": "Отмена резервации денег за услугу",
+                "operationId": "reservation-cancel",
+                "parameters": [
+                    {
+                        "description": "Reservation",
+                        "name": "reservation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReservationCommitRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservation/confirm/": {
+            "post": {
+                "description": "Резерв указывается по reservation_id либо по order_id и service_id. Необязательное поле capture позволяет\nсписать часть резерва, остаток в той же транзакции возвращается на баланс и отображается в истории с типом release",
+                "tags": [
+                    "Reservation"
+                ],
+                "summary": "Подтверждение списывания денег за услугу",
+                "operationId": "reservation-confirm",
+                "parameters": [
+                    {
+                        "description": "Reservation",
+                        "name": "reservation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReservationCommitRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservation/order/cancel/": {
+            "post": {
+                "tags": [
+                    "Reservation"
+                ],
+                "summary": "Отмена всех действующих резервов заказа",
+                "operationId": "reservation-order-cancel",
+                "parameters": [
+                    {
+                        "description": "Order",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.OrderCommitRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag of each user of the order",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservation/order/confirm/": {
+            "post": {
+                "tags": [
+                    "Reservation"
+                ],
+                "summary": "Подтверждение всех действующих резервов заказа",
+                "operationId": "reservation-order-confirm",
+                "parameters": [
+                    {
+                        "description": "Order",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.OrderCommitRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag of each user of the order",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservation/order/reserve/": {
+            "post": {
+                "description": "Для каждой услуги создается отдельный резерв, все резервы создаются в одной транзакции.\nОтдельную услугу заказа можно подтвердить или отменить по order_id и service_id",
+                "tags": [
+                    "Reservation"
+                ],
+                "summary": "Резервация денег на все услуги заказа",
+                "operationId": "reservation-order-reserve",
+                "parameters": [
+                    {
+                        "description": "Order",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.OrderReservationRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Idempotency key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/OrderReservation"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Balance version after the operation"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservation/refund/": {
+            "post": {
+                "description": "Резерв указывается по reservation_id либо по order_id и service_id. Без amount возвращается весь\nневозвращенный остаток подтвержденной суммы. Возврат отображается в истории с типом refund\nи уменьшает выручку услуги в отчете за месяц возврата",
+                "tags": [
+                    "Reservation"
+                ],
+                "summary": "Возврат денег по подтвержденному резерву",
+                "operationId": "reservation-refund",
+                "parameters": [
+                    {
+                        "description": "Refund",
+                        "name": "refund",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RefundRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Idempotency key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ReservationState"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservation/reserve/": {
+            "post": {
+                "description": "Услуга должна быть активна, а стоимость - совпадать с ценой услуги, действующей в момент резервирования",
+                "tags": [
+                    "Reservation"
+                ],
+                "summary": "Резервация денег на услугу",
+                "operationId": "reservation-reserve",
+                "parameters": [
+                    {
+                        "description": "Reservation",
+                        "name": "reservation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/Reservation"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Balance version from ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Idempotency key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/ReservationState"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Balance version after the operation"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/VersionMismatch"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/reservation/{id}": {
+            "get": {
+                "tags": [
+                    "Reservation"
+                ],
+                "summary": "Текущее состояние резерва",
+                "operationId": "get-reservation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ReservationState"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/schedule/": {
+            "get": {
+                "description": "Есть необязательная пагинация (limit, offset) и фильтр по статусу, сортировка по дате создания в desc",
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Список расписаний пользователя",
+                "operationId": "get-schedules",
+                "parameters": [
+                    {
+                        "description": "Schedules filter",
+                        "name": "schedules",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ScheduleListRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Schedule"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Операция выполняется фоновым исполнителем начиная со start_at. Для monthly выполнение происходит в day_of_month,\nв коротких месяцах - в последний день месяца. Неудачное выполнение повторяется до max_retries раз",
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Создание отложенного или регулярного списания либо перевода",
+                "operationId": "create-schedule",
+                "parameters": [
+                    {
+                        "description": "Schedule",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ScheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/schedule/cancel/": {
+            "post": {
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Отмена расписания",
+                "operationId": "cancel-schedule",
+                "parameters": [
+                    {
+                        "description": "Schedule ID",
+                        "name": "schedule_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ScheduleIDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/schedule/pause/": {
+            "post": {
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Приостановка активного расписания",
+                "operationId": "pause-schedule",
+                "parameters": [
+                    {
+                        "description": "Schedule ID",
+                        "name": "schedule_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ScheduleIDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/schedule/resume/": {
+            "post": {
+                "description": "Выполнение, пропущенное на паузе, выполняется один раз сразу после возобновления",
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Возобновление приостановленного расписания",
+                "operationId": "resume-schedule",
+                "parameters": [
+                    {
+                        "description": "Schedule ID",
+                        "name": "schedule_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ScheduleIDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        },
+        "/schedule/runs/": {
+            "get": {
+                "tags": [
+                    "Schedule"
+                ],
+                "summary": "Попытки выполнения расписания, включая неудачные",
+                "operationId": "get-schedule-runs",
+                "parameters": [
+                    {
+                        "description": "Schedule ID",
+                        "name": "schedule_id",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ScheduleIDRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ScheduleRun"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    },
+                    "418": {
+                        "description": "I'm a teapot",
+                        "schema": {
+                            "$ref": "#/definitions/AppError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "AccountStatusRequest": {
+            "type": "object",
+            "required": [
+                "user_id"
+            ],
+            "properties": {
+                "comment": {
+                    "description": "Причина смены статуса",
+                    "type": "string"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "AppError": {
+            "type": "object",
+            "properties": {
+                "developer_message": {
+                    "description": "Сообщение для разработчика",
+                    "type": "string"
+                },
+                "message": {
+                    "description": "Сообщение",
+                    "type": "string"
+                }
+            }
+        },
+        "Balance": {
+            "type": "object",
+            "required": [
+                "balance",
+                "user_id"
+            ],
+            "properties": {
+                "as_of": {
+                    "description": "Момент времени, на который посчитан баланс",
+                    "type": "string"
+                },
+                "available_credit": {
+                    "description": "Неиспользованная часть кредитного лимита",
+                    "type": "number",
+                    "example": 1000
+                },
+                "balance": {
+                    "description": "Баланс пользователя",
+                    "type": "number",
+                    "example": 120.5
+                },
+                "credit_limit": {
+                    "description": "Кредитный лимит: насколько баланс может уйти в минус",
+                    "type": "number",
+                    "example": 1000
+                },
+                "reserved": {
+                    "description": "Зарезервированные деньги пользователя",
+                    "type": "number",
+                    "example": 50
+                },
+                "status": {
+                    "description": "Статус счета: active, frozen или closed",
+                    "type": "string",
+                    "example": "active"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Версия баланса, также возвращается в заголовке ETag",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "BalanceChangeRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "user_id"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Баланс пользователя",
+                    "type": "number",
+                    "example": 100.5
+                },
+                "comment": {
+                    "description": "Коментарий",
+                    "type": "string"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "BalanceGetRequest": {
+            "type": "object",
+            "required": [
+                "user_id"
+            ],
+            "properties": {
+                "as_of": {
+                    "description": "Момент времени, на который нужен баланс. По умолчанию - текущий баланс",
+                    "type": "string",
+                    "example": "2022-11-01T00:00:00Z"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "BalanceHistory": {
+            "type": "object",
+            "required": [
+                "order_by",
+                "order_field",
+                "user_id"
+            ],
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "offset": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "order_by": {
+                    "description": "Порядок сортировки",
+                    "type": "string",
+                    "default": "desc",
+                    "enum": [
+                        "desc",
+                        "asc"
+                    ]
+                },
+                "order_field": {
+                    "description": "Поле для сортировки",
+                    "type": "string",
+                    "default": "create_date",
+                    "enum": [
+                        "create_date",
+                        "amount"
+                    ]
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "BatchItem": {
+            "type": "object",
+            "required": [
+                "amount",
+                "type",
+                "user_id"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Сумма операции",
+                    "type": "number",
+                    "example": 100.5
+                },
+                "comment": {
+                    "description": "Коментарий",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Тип операции",
+                    "type": "string",
+                    "enum": [
+                        "replenish",
+                        "reduce",
+                        "transfer"
+                    ],
+                    "example": "reduce"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя (отправителя для перевода)",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                },
+                "user_id_to": {
+                    "description": "UUID баланса получателя, обязателен для перевода и игнорируется для остальных операций",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610068"
+                }
+            }
+        },
+        "BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "description": "Баланс пользователя (отправителя для перевода) после операции",
+                    "type": "number",
+                    "example": 120.5
+                },
+                "balance_to": {
+                    "description": "Баланс получателя после перевода",
+                    "type": "number",
+                    "example": 20
+                },
+                "error": {
+                    "description": "Ошибка операции",
+                    "$ref": "#/definitions/AppError"
+                },
+                "index": {
+                    "description": "Порядковый номер операции в запросе",
+                    "type": "integer"
+                },
+                "review_id": {
+                    "description": "UUID заявки на проверку для операции со статусом pending_review",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Результат: applied, failed, rolled_back (отменена вместе с пакетом), skipped (не выполнялась)\nили pending_review (отправлена на проверку)",
+                    "type": "string",
+                    "example": "applied"
+                }
+            }
+        },
+        "BatchRequest": {
+            "type": "object",
+            "required": [
+                "items",
+                "mode"
+            ],
+            "properties": {
+                "items": {
+                    "description": "Операции выполняются по порядку в одной транзакции",
+                    "type": "array",
+                    "maxItems": 1000,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/BatchItem"
+                    }
+                },
+                "mode": {
+                    "description": "atomic - все или ничего, best_effort - применить все успешные операции",
+                    "type": "string",
+                    "enum": [
+                        "atomic",
+                        "best_effort"
+                    ],
+                    "example": "atomic"
+                }
+            }
+        },
+        "BatchResult": {
+            "type": "object",
+            "properties": {
+                "committed": {
+                    "description": "Были ли изменения сохранены",
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/BatchItemResult"
+                    }
+                },
+                "mode": {
+                    "description": "atomic - все или ничего, best_effort - применить все успешные операции",
+                    "type": "string",
+                    "example": "atomic"
+                }
+            }
+        },
+        "CreditLimitRequest": {
+            "type": "object",
+            "required": [
+                "user_id"
+            ],
+            "properties": {
+                "credit_limit": {
+                    "description": "Сумма, на которую баланс может уйти в минус",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 10000
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "FeeRule": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Отключенное правило не применяется",
+                    "type": "boolean",
+                    "example": true
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "fee_rule_id": {
+                    "description": "UUID правила",
+                    "type": "string"
+                },
+                "flat": {
+                    "description": "Фиксированная часть комиссии",
+                    "type": "number",
+                    "example": 10
+                },
+                "max_fee": {
+                    "description": "Максимальная комиссия, 0 - без ограничения",
+                    "type": "number",
+                    "example": 500
+                },
+                "min_fee": {
+                    "description": "Минимальная комиссия",
+                    "type": "number",
+                    "example": 5
+                },
+                "operation": {
+                    "description": "Операция: transfer, reserve или confirm",
+                    "type": "string",
+                    "example": "transfer"
+                },
+                "percent": {
+                    "description": "Процент от суммы операции",
+                    "type": "number",
+                    "example": 1.5
+                },
+                "service_id": {
+                    "description": "UUID сервиса, пустой для правила, действующего для всех услуг",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                }
+            }
+        },
+        "FeeRuleIDRequest": {
+            "type": "object",
+            "required": [
+                "fee_rule_id"
+            ],
+            "properties": {
+                "fee_rule_id": {
+                    "description": "UUID правила",
+                    "type": "string",
+                    "example": "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+                }
+            }
+        },
+        "FeeRuleListRequest": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Только действующие (true) или только отключенные (false) правила, по умолчанию все",
+                    "type": "boolean",
+                    "example": true
+                },
+                "operation": {
+                    "description": "Фильтр по операции",
+                    "type": "string",
+                    "enum": [
+                        "transfer",
+                        "reserve",
+                        "confirm"
+                    ],
+                    "example": "transfer"
+                },
+                "service_id": {
+                    "description": "Фильтр по услуге",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                }
+            }
+        },
+        "FeeRuleRequest": {
+            "type": "object",
+            "required": [
+                "operation"
+            ],
+            "properties": {
+                "flat": {
+                    "description": "Фиксированная часть комиссии",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 10
+                },
+                "max_fee": {
+                    "description": "Максимальная комиссия, по умолчанию без ограничения",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 500
+                },
+                "min_fee": {
+                    "description": "Минимальная комиссия",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 5
+                },
+                "operation": {
+                    "description": "Операция: transfer, reserve или confirm",
+                    "type": "string",
+                    "enum": [
+                        "transfer",
+                        "reserve",
+                        "confirm"
+                    ],
+                    "example": "transfer"
+                },
+                "percent": {
+                    "description": "Процент от суммы операции",
+                    "type": "number",
+                    "maximum": 100,
+                    "minimum": 0,
+                    "example": 1.5
+                },
+                "service_id": {
+                    "description": "UUID сервиса для reserve и confirm, без него правило действует для всех услуг",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                }
+            }
+        },
+        "HistoryRow": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Сумма",
+                    "type": "number",
+                    "example": -20.25
+                },
+                "comment": {
+                    "description": "Комментарий",
+                    "type": "string"
+                },
+                "create_at": {
+                    "description": "Время создания",
+                    "type": "string"
+                },
+                "group_id": {
+                    "description": "UUID группы разделенного перевода, общий для отправителя и всех получателей",
+                    "type": "string"
+                },
+                "order_id": {
+                    "description": "UUID заказа",
+                    "type": "string"
+                },
+                "service_name": {
+                    "description": "Название услуги",
+                    "type": "string"
+                },
+                "transaction_type": {
+                    "description": "Тип транзакции",
+                    "type": "string"
+                },
+                "user_id_from": {
+                    "description": "UUID отправителя",
+                    "type": "string"
+                },
+                "user_id_to": {
+                    "description": "UUID получателя",
+                    "type": "string"
+                }
+            }
+        },
+        "LimitRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "operation",
+                "period",
+                "user_id"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Лимит",
+                    "type": "number",
+                    "example": 10000
+                },
+                "operation": {
+                    "description": "Операция: reduce, transfer (исходящие переводы) или reserve",
+                    "type": "string",
+                    "enum": [
+                        "reduce",
+                        "transfer",
+                        "reserve"
+                    ],
+                    "example": "transfer"
+                },
+                "period": {
+                    "description": "Период: single (одна операция), daily, weekly или monthly",
+                    "type": "string",
+                    "enum": [
+                        "single",
+                        "daily",
+                        "weekly",
+                        "monthly"
+                    ],
+                    "example": "daily"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "LimitResetRequest": {
+            "type": "object",
+            "required": [
+                "operation",
+                "period",
+                "user_id"
+            ],
+            "properties": {
+                "operation": {
+                    "description": "Операция: reduce, transfer или reserve",
+                    "type": "string",
+                    "enum": [
+                        "reduce",
+                        "transfer",
+                        "reserve"
+                    ],
+                    "example": "transfer"
+                },
+                "period": {
+                    "description": "Период: single, daily, weekly или monthly",
+                    "type": "string",
+                    "enum": [
+                        "single",
+                        "daily",
+                        "weekly",
+                        "monthly"
+                    ],
+                    "example": "daily"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "LimitUsage": {
+            "type": "object",
+            "properties": {
+                "default": {
+                    "description": "Общий лимит из конфигурации, а не лимит пользователя",
+                    "type": "boolean",
+                    "example": false
+                },
+                "limit": {
+                    "description": "Лимит",
+                    "type": "number",
+                    "example": 10000
+                },
+                "operation": {
+                    "description": "Операция: reduce, transfer или reserve",
+                    "type": "string",
+                    "example": "transfer"
+                },
+                "period": {
+                    "description": "Период: single, daily, weekly или monthly",
+                    "type": "string",
+                    "example": "daily"
+                },
+                "remaining": {
+                    "description": "Остаток лимита, для single - максимальная сумма одной операции",
+                    "type": "number",
+                    "example": 7500
+                },
+                "resets_at": {
+                    "description": "Время обновления лимита",
+                    "type": "string"
+                },
+                "used": {
+                    "description": "Использовано в текущем периоде",
+                    "type": "number",
+                    "example": 2500
+                }
+            }
+        },
+        "LimitUsageRequest": {
+            "type": "object",
+            "required": [
+                "user_id"
+            ],
+            "properties": {
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "OpenReservation": {
+            "type": "object",
+            "properties": {
+                "captured": {
+                    "description": "Подтвержденная сумма",
+                    "type": "number",
+                    "example": 120
+                },
+                "closed_at": {
+                    "description": "Время подтверждения или отмены",
+                    "type": "string"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "cost": {
+                    "description": "Зарезервированная сумма",
+                    "type": "number",
+                    "example": 150.5
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "Срок резерва, отсутствует у бессрочного резерва",
+                    "type": "string"
+                },
+                "order_id": {
+                    "type": "string",
+                    "example": "983e8792-6736-41bd-9f1a-7c67f8501645"
+                },
+                "refunded": {
+                    "description": "Возвращенная пользователю часть подтвержденной суммы",
+                    "type": "number",
+                    "example": 20
+                },
+                "reservation_id": {
+                    "description": "UUID резерва",
+                    "type": "string",
+                    "example": "5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d"
+                },
+                "service_id": {
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                },
+                "status": {
+                    "description": "Статус: active, confirm, cancel или expired",
+                    "type": "string",
+                    "example": "active"
+                },
+                "user_held": {
+                    "description": "Сколько всего денег пользователя сейчас зарезервировано",
+                    "type": "number",
+                    "example": 300
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "OrderReservation": {
+            "type": "object",
+            "properties": {
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ReservationState"
+                    }
+                },
+                "order_id": {
+                    "type": "string",
+                    "example": "983e8792-6736-41bd-9f1a-7c67f8501645"
+                },
+                "total": {
+                    "description": "Суммарная стоимость услуг заказа",
+                    "type": "number",
+                    "example": 300
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "ReportRequest": {
+            "type": "object",
+            "required": [
+                "month",
+                "year"
+            ],
+            "properties": {
+                "month": {
+                    "description": "UUID баланса пользователя",
+                    "type": "integer",
+                    "maximum": 12,
+                    "minimum": 1,
+                    "example": 11
+                },
+                "year": {
+                    "description": "Баланс пользователя",
+                    "type": "integer",
+                    "minimum": 2000,
+                    "example": 2022
+                }
+            }
+        },
+        "ReportResponse": {
+            "type": "object",
+            "required": [
+                "file_url"
+            ],
+            "properties": {
+                "file_url": {
+                    "description": "Ссылка на скачивание файла",
+                    "type": "string"
+                }
+            }
+        },
+        "Reservation": {
+            "type": "object",
+            "required": [
+                "cost",
+                "order_id",
+                "service_id",
+                "user_id"
+            ],
+            "properties": {
+                "comment": {
+                    "description": "Дополнительный комментарий",
+                    "type": "string"
+                },
+                "cost": {
+                    "description": "Стоимость услуги",
+                    "type": "number",
+                    "example": 150.5
+                },
+                "order_id": {
+                    "description": "UUID заказа",
+                    "type": "string",
+                    "example": "983e8792-6736-41bd-9f1a-7c67f8501645"
+                },
+                "service_id": {
+                    "description": "UUID сервиса",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                },
+                "ttl": {
+                    "description": "Время жизни резерва в секундах, 0 - бессрочный резерв. По умолчанию RESERVATION_TTL",
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 3600
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "ReservationAdjustment": {
+            "type": "object",
+            "properties": {
+                "adjustment_id": {
+                    "type": "string"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "new_cost": {
+                    "description": "Сумма резерва после изменения",
+                    "type": "number",
+                    "example": 180
+                },
+                "old_cost": {
+                    "description": "Сумма резерва до изменения",
+                    "type": "number",
+                    "example": 150.5
+                },
+                "order_id": {
+                    "type": "string"
+                },
+                "reservation_id": {
+                    "type": "string"
+                },
+                "service_id": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "ReservationList": {
+            "type": "object",
+            "properties": {
+                "held": {
+                    "description": "Сумма резервов",
+                    "type": "number",
+                    "example": 300
+                },
+                "reservations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/OpenReservation"
+                    }
+                },
+                "total": {
+                    "description": "Число резервов",
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "ReservationState": {
+            "type": "object",
+            "properties": {
+                "captured": {
+                    "description": "Подтвержденная сумма",
+                    "type": "number",
+                    "example": 120
+                },
+                "closed_at": {
+                    "description": "Время подтверждения или отмены",
+                    "type": "string"
+                },
+                "comment": {
+                    "type": "string"
+                },
+                "cost": {
+                    "description": "Зарезервированная сумма",
+                    "type": "number",
+                    "example": 150.5
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "Срок резерва, отсутствует у бессрочного резерва",
+                    "type": "string"
+                },
+                "order_id": {
+                    "type": "string",
+                    "example": "983e8792-6736-41bd-9f1a-7c67f8501645"
+                },
+                "refunded": {
+                    "description": "Возвращенная пользователю часть подтвержденной суммы",
+                    "type": "number",
+                    "example": 20
+                },
+                "reservation_id": {
+                    "description": "UUID резерва",
+                    "type": "string",
+                    "example": "5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d"
+                },
+                "service_id": {
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                },
+                "status": {
+                    "description": "Статус: active, confirm, cancel или expired",
+                    "type": "string",
+                    "example": "active"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "Review": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Сумма операции",
+                    "type": "number",
+                    "example": 100.5
+                },
+                "comment": {
+                    "description": "Комментарий администратора",
+                    "type": "string"
+                },
+                "created_at": {
+                    "description": "Время создания",
+                    "type": "string"
+                },
+                "decided_at": {
+                    "description": "Время решения администратора",
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Ошибка выполнения одобренной операции",
+                    "type": "string"
+                },
+                "operation": {
+                    "description": "Операция: reduce, transfer или split_transfer",
+                    "type": "string",
+                    "example": "transfer"
+                },
+                "reasons": {
+                    "description": "Причины оценки",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "request": {
+                    "description": "Исходный запрос, выполняется при одобрении",
+                    "type": "object"
+                },
+                "review_id": {
+                    "description": "UUID заявки на проверку",
+                    "type": "string"
+                },
+                "score": {
+                    "description": "Оценка риска",
+                    "type": "integer",
+                    "example": 120
+                },
+                "status": {
+                    "description": "Статус: pending, approved, rejected или failed",
+                    "type": "string",
+                    "example": "pending"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя (отправителя для перевода)",
+                    "type": "string"
+                },
+                "user_id_to": {
+                    "description": "UUID баланса получателя перевода. Пустой для перевода нескольким получателям",
+                    "type": "string"
+                }
+            }
+        },
+        "ReviewDecisionRequest": {
+            "type": "object",
+            "required": [
+                "review_id"
+            ],
+            "properties": {
+                "comment": {
+                    "description": "Комментарий администратора",
+                    "type": "string"
+                },
+                "review_id": {
+                    "description": "UUID заявки на проверку",
+                    "type": "string",
+                    "example": "5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d"
+                }
+            }
+        },
+        "ReviewListRequest": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "offset": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "status": {
+                    "description": "Фильтр по статусу",
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "approved",
+                        "rejected",
+                        "failed"
+                    ],
+                    "example": "pending"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "Schedule": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Сумма операции",
+                    "type": "number",
+                    "example": 100.5
+                },
+                "attempt": {
+                    "description": "Номер повтора текущего выполнения",
+                    "type": "integer"
+                },
+                "comment": {
+                    "description": "Коментарий",
+                    "type": "string"
+                },
+                "created_at": {
+                    "description": "Время создания",
+                    "type": "string"
+                },
+                "day_of_month": {
+                    "description": "День месяца для monthly",
+                    "type": "integer",
+                    "example": 10
+                },
+                "due_at": {
+                    "description": "Плановое время текущего выполнения",
+                    "type": "string"
+                },
+                "max_retries": {
+                    "description": "Сколько раз повторять неудачное выполнение",
+                    "type": "integer"
+                },
+                "next_run_at": {
+                    "description": "Время следующей попытки, позже due_at при повторе после ошибки",
+                    "type": "string"
+                },
+                "operation": {
+                    "description": "Операция: reduce или transfer",
+                    "type": "string",
+                    "example": "transfer"
+                },
+                "recurrence": {
+                    "description": "Периодичность: once, daily, weekly или monthly",
+                    "type": "string",
+                    "example": "monthly"
+                },
+                "schedule_id": {
+                    "description": "UUID расписания",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Статус: active, paused, cancelled, completed или failed",
+                    "type": "string",
+                    "example": "active"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя (отправителя для перевода)",
+                    "type": "string"
+                },
+                "user_id_to": {
+                    "description": "UUID баланса получателя перевода",
+                    "type": "string"
+                }
+            }
+        },
+        "ScheduleIDRequest": {
+            "type": "object",
+            "required": [
+                "schedule_id"
+            ],
+            "properties": {
+                "schedule_id": {
+                    "description": "UUID расписания",
+                    "type": "string",
+                    "example": "5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d"
+                }
+            }
+        },
+        "ScheduleListRequest": {
+            "type": "object",
+            "required": [
+                "user_id"
+            ],
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "offset": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "status": {
+                    "description": "Фильтр по статусу",
+                    "type": "string",
+                    "enum": [
+                        "active",
+                        "paused",
+                        "cancelled",
+                        "completed",
+                        "failed"
+                    ],
+                    "example": "active"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "ScheduleRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "operation",
+                "start_at",
+                "user_id"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Сумма операции",
+                    "type": "number",
+                    "example": 100.5
+                },
+                "comment": {
+                    "description": "Коментарий",
+                    "type": "string"
+                },
+                "day_of_month": {
+                    "description": "День месяца для monthly, по умолчанию день start_at",
+                    "type": "integer",
+                    "maximum": 31,
+                    "minimum": 1,
+                    "example": 10
+                },
+                "max_retries": {
+                    "description": "Сколько раз повторять неудачное выполнение, по умолчанию 3",
+                    "type": "integer",
+                    "maximum": 10,
+                    "minimum": 0,
+                    "example": 3
+                },
+                "operation": {
+                    "description": "Операция: reduce или transfer",
+                    "type": "string",
+                    "enum": [
+                        "reduce",
+                        "transfer"
+                    ],
+                    "example": "transfer"
+                },
+                "recurrence": {
+                    "description": "Периодичность, по умолчанию once",
+                    "type": "string",
+                    "enum": [
+                        "once",
+                        "daily",
+                        "weekly",
+                        "monthly"
+                    ],
+                    "example": "monthly"
+                },
+                "start_at": {
+                    "description": "Время первого выполнения. Время в прошлом означает выполнение при ближайшем запуске исполнителя,\nпропущенные до текущего момента выполнения регулярного расписания не наверстываются",
+                    "type": "string",
+                    "example": "2022-12-10T09:00:00Z"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя (отправителя для перевода)",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                },
+                "user_id_to": {
+                    "description": "UUID баланса получателя, только для перевода",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610068"
+                }
+            }
+        },
+        "ScheduleRun": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "description": "Номер повтора, 0 - первая попытка",
+                    "type": "integer"
+                },
+                "due_at": {
+                    "description": "Плановое время выполнения, к которому относится попытка",
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Ошибка неудачной попытки",
+                    "type": "string",
+                    "example": "not enough money on balance"
+                },
+                "executed_at": {
+                    "type": "string"
+                },
+                "review_id": {
+                    "description": "UUID заявки на проверку для попытки со статусом pending_review",
+                    "type": "string"
+                },
+                "schedule_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "failed"
+                }
+            }
+        },
+        "Service": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Неактивная услуга недоступна для новых резервов",
+                    "type": "boolean",
+                    "example": true
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Название",
+                    "type": "string",
+                    "example": "Курьерская доставка"
+                },
+                "service_id": {
+                    "description": "UUID сервиса",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                }
+            }
+        },
+        "ServiceCreateRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "description": "Название, уникальное в каталоге",
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "Курьерская доставка"
+                },
+                "service_id": {
+                    "description": "UUID сервиса, по умолчанию генерируется",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                }
+            }
+        },
+        "ServiceIDRequest": {
+            "type": "object",
+            "required": [
+                "service_id"
+            ],
+            "properties": {
+                "service_id": {
+                    "description": "UUID сервиса",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                }
+            }
+        },
+        "ServiceListRequest": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Только активные (true) или только неактивные (false) услуги, по умолчанию все",
+                    "type": "boolean",
+                    "example": true
+                },
+                "limit": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "offset": {
+                    "type": "integer",
                     "minimum": 0
+                }
+            }
+        },
+        "ServicePrice": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "max_price": {
+                    "description": "Максимальная цена",
+                    "type": "number",
+                    "example": 500
+                },
+                "min_price": {
+                    "description": "Минимальная цена",
+                    "type": "number",
+                    "example": 50
+                },
+                "price": {
+                    "description": "Фиксированная цена",
+                    "type": "number",
+                    "example": 100.5
+                },
+                "price_id": {
+                    "description": "UUID цены",
+                    "type": "string"
+                },
+                "service_id": {
+                    "description": "UUID сервиса",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                },
+                "valid_from": {
+                    "description": "Начало действия цены",
+                    "type": "string"
+                },
+                "valid_to": {
+                    "description": "Окончание действия цены, без него цена действует до появления более новой",
+                    "type": "string"
+                }
+            }
+        },
+        "ServicePriceRequest": {
+            "type": "object",
+            "required": [
+                "service_id"
+            ],
+            "properties": {
+                "max_price": {
+                    "description": "Максимальная цена диапазона",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 500
+                },
+                "min_price": {
+                    "description": "Минимальная цена диапазона",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 50
+                },
+                "price": {
+                    "description": "Фиксированная цена, нельзя передавать вместе с min_price и max_price",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 100.5
+                },
+                "service_id": {
+                    "description": "UUID сервиса",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                },
+                "valid_from": {
+                    "description": "Начало действия цены, по умолчанию текущее время",
+                    "type": "string",
+                    "example": "2022-12-01T00:00:00Z"
+                },
+                "valid_to": {
+                    "description": "Окончание действия цены",
+                    "type": "string",
+                    "example": "2023-01-01T00:00:00Z"
+                }
+            }
+        },
+        "ServiceRenameRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "service_id"
+            ],
+            "properties": {
+                "name": {
+                    "description": "Новое название, уникальное в каталоге",
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "Экспресс-доставка"
                 },
-                "user_id": {
-                    "description": "UUID баланса пользователя",
-                    "type": "string"
+                "service_id": {
+                    "description": "UUID сервиса",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
                 }
             }
         },
-        "BalanceChangeRequest": {
+        "SplitTransfer": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "description": "Баланс отправителя после перевода",
+                    "type": "number",
+                    "example": 120.5
+                },
+                "group_id": {
+                    "description": "UUID группы, по которому разделенный перевод связан в истории отправителя и получателей",
+                    "type": "string",
+                    "example": "0f8b4c53-7d3e-4d2a-9a4e-6a8f0c1d2e3f"
+                }
+            }
+        },
+        "SplitTransferRecipient": {
             "type": "object",
             "required": [
                 "amount",
-                "user_id"
+                "user_id_to"
             ],
             "properties": {
                 "amount": {
-                    "description": "Баланс пользователя",
-                    "type": "number"
+                    "description": "Сумма получателю",
+                    "type": "number",
+                    "example": 100.5
                 },
                 "comment": {
-                    "description": "Коментарий",
+                    "description": "Коментарий для получателя",
                     "type": "string"
                 },
-                "user_id": {
-                    "description": "UUID баланса пользователя",
+                "user_id_to": {
+                    "description": "UUID баланса получателя",
                     "type": "string",
-                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610068"
                 }
             }
         },
-        "BalanceGetRequest": {
+        "SplitTransferRequest": {
             "type": "object",
             "required": [
-                "user_id"
+                "recipients",
+                "user_id_from"
             ],
             "properties": {
-                "user_id": {
+                "comment": {
+                    "description": "Коментарий для отправителя",
+                    "type": "string"
+                },
+                "recipients": {
+                    "description": "Получатели, каждый не больше одного раза",
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "uniqueItems": true,
+                    "items": {
+                        "$ref": "#/definitions/SplitTransferRecipient"
+                    }
+                },
+                "user_id_from": {
+                    "description": "UUID баланса отправителя",
                     "type": "string",
                     "example": "7a13445c-d6df-4111-abc0-abb12f610069"
                 }
             }
         },
-        "BalanceHistory": {
+        "TransferRequest": {
             "type": "object",
             "required": [
-                "order_by",
-                "order_field",
-                "user_id"
+                "amount",
+                "user_id_from",
+                "user_id_to"
             ],
             "properties": {
-                "limit": {
-                    "type": "integer",
-                    "minimum": 0
+                "amount": {
+                    "description": "Списание",
+                    "type": "number",
+                    "example": 100.5
                 },
-                "offset": {
-                    "type": "integer",
-                    "minimum": 0
+                "comment": {
+                    "description": "Коментарий",
+                    "type": "string"
                 },
-                "order_by": {
-                    "description": "Порядок сортировки",
+                "user_id_from": {
+                    "description": "UUID баланса отправителя",
                     "type": "string",
-                    "default": "desc",
-                    "enum": [
-                        "desc",
-                        "asc"
-                    ]
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
                 },
-                "order_field": {
-                    "description": "Поле для сортировки",
+                "user_id_to": {
+                    "description": "UUID баланса получателя",
                     "type": "string",
-                    "default": "create_date",
-                    "enum": [
-                        "create_date",
-                        "amount"
-                    ]
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610068"
+                }
+            }
+        },
+        "VersionMismatch": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "description": "Текущий баланс пользователя",
+                    "type": "number",
+                    "example": 120.5
                 },
                 "user_id": {
                     "description": "UUID баланса пользователя",
-                    "type": "string",
-                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Текущая версия баланса, 0 - баланса нет",
+                    "type": "integer",
+                    "example": 4
                 }
             }
         },
-        "HistoryRow": {
+        "dto.OrderCommitRequest": {
             "type": "object",
+            "required": [
+                "order_id"
+            ],
             "properties": {
-                "amount": {
-                    "description": "Сумма",
-                    "type": "number"
-                },
                 "comment": {
-                    "description": "Комментарий",
-                    "type": "string"
-                },
-                "create_at": {
-                    "description": "Время создания",
+                    "description": "Дополнительный комментарий",
                     "type": "string"
                 },
                 "order_id": {
                     "description": "UUID заказа",
-                    "type": "string"
-                },
-                "service_name": {
-                    "description": "Название услуги",
-                    "type": "string"
+                    "type": "string",
+                    "example": "983e8792-6736-41bd-9f1a-7c67f8501645"
                 },
-                "transaction_type": {
-                    "description": "Тип транзакции",
+                "user_id": {
+                    "description": "UUID баланса пользователя, уточняет поиск резервов заказа",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                }
+            }
+        },
+        "dto.OrderLine": {
+            "type": "object",
+            "required": [
+                "cost",
+                "service_id"
+            ],
+            "properties": {
+                "comment": {
+                    "description": "Комментарий резерва услуги, по умолчанию комментарий заказа",
                     "type": "string"
                 },
-                "user_id_from": {
-                    "description": "UUID отправителя",
-                    "type": "string"
+                "cost": {
+                    "description": "Стоимость услуги",
+                    "type": "number",
+                    "example": 150.5
                 },
-                "user_id_to": {
-                    "description": "UUID получателя",
-                    "type": "string"
+                "service_id": {
+                    "description": "UUID сервиса",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
                 }
             }
         },
-        "ReportRequest": {
+        "dto.OrderReservationRequest": {
             "type": "object",
             "required": [
-                "month",
-                "year"
+                "lines",
+                "order_id",
+                "user_id"
             ],
             "properties": {
-                "month": {
-                    "description": "UUID баланса пользователя",
-                    "type": "integer",
-                    "maximum": 12,
-                    "minimum": 1,
-                    "example": 11
+                "comment": {
+                    "description": "Комментарий заказа",
+                    "type": "string"
                 },
-                "year": {
-                    "description": "Баланс пользователя",
+                "lines": {
+                    "description": "Услуги заказа, каждая услуга не больше одного раза",
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "uniqueItems": true,
+                    "items": {
+                        "$ref": "#/definitions/dto.OrderLine"
+                    }
+                },
+                "order_id": {
+                    "description": "UUID заказа",
+                    "type": "string",
+                    "example": "983e8792-6736-41bd-9f1a-7c67f8501645"
+                },
+                "ttl": {
+                    "description": "Время жизни резервов в секундах, 0 - бессрочные резервы. По умолчанию RESERVATION_TTL",
                     "type": "integer",
-                    "minimum": 2000,
-                    "example": 2022
+                    "minimum": 0,
+                    "example": 3600
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
                 }
             }
         },
-        "ReportResponse": {
+        "dto.RefundRequest": {
             "type": "object",
-            "required": [
-                "file_url"
-            ],
             "properties": {
-                "file_url": {
-                    "description": "Ссылка на скачивание файла",
+                "amount": {
+                    "description": "Сумма возврата, не больше невозвращенной подтвержденной суммы. По умолчанию весь невозвращенный остаток",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 20
+                },
+                "comment": {
+                    "description": "Причина возврата",
                     "type": "string"
+                },
+                "order_id": {
+                    "description": "UUID заказа, обязателен без reservation_id",
+                    "type": "string",
+                    "example": "983e8792-6736-41bd-9f1a-7c67f8501645"
+                },
+                "reservation_id": {
+                    "description": "UUID резерва",
+                    "type": "string",
+                    "example": "5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d"
+                },
+                "service_id": {
+                    "description": "UUID сервиса, обязателен без reservation_id",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
                 }
             }
         },
-        "Reservation": {
+        "dto.ReservationAdjustRequest": {
             "type": "object",
             "required": [
-                "cost",
-                "order_id",
-                "service_id",
-                "user_id"
+                "cost"
             ],
             "properties": {
+                "comment": {
+                    "description": "Причина изменения",
+                    "type": "string"
+                },
+                "cost": {
+                    "description": "Новая сумма резерва",
+                    "type": "number",
+                    "example": 180
+                },
+                "order_id": {
+                    "description": "UUID заказа, обязателен без reservation_id",
+                    "type": "string",
+                    "example": "983e8792-6736-41bd-9f1a-7c67f8501645"
+                },
+                "reservation_id": {
+                    "description": "UUID резерва",
+                    "type": "string",
+                    "example": "5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d"
+                },
+                "service_id": {
+                    "description": "UUID сервиса, обязателен без reservation_id",
+                    "type": "string",
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                }
+            }
+        },
+        "dto.ReservationCommitRequest": {
+            "type": "object",
+            "properties": {
+                "capture": {
+                    "description": "Подтверждаемая сумма, не больше суммы резерва, учитывается только при подтверждении. По умолчанию вся сумма,\nостаток резерва возвращается на баланс пользователя",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 120
+                },
                 "comment": {
                     "description": "Дополнительный комментарий",
                     "type": "string"
                 },
                 "cost": {
-                    "description": "Стоимость услуги",
-                    "type": "number"
+                    "description": "Сумма резерва, уточняет поиск по заказу и услуге",
+                    "type": "number",
+                    "minimum": 0,
+                    "example": 150.5
                 },
                 "order_id": {
-                    "description": "UUID заказа",
+                    "description": "UUID заказа, обязателен без reservation_id",
                     "type": "string",
                     "example": "983e8792-6736-41bd-9f1a-7c67f8501645"
                 },
+                "reservation_id": {
+                    "description": "UUID резерва",
+                    "type": "string",
+                    "example": "5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d"
+                },
                 "service_id": {
-                    "description": "UUID сервиса",
+                    "description": "UUID сервиса, обязателен без reservation_id",
                     "type": "string",
                     "example": "34e16535-480c-43f8-95a9-b7a503499af0"
                 },
                 "user_id": {
-                    "description": "UUID баланса пользователя",
+                    "description": "UUID баланса пользователя, уточняет поиск по заказу и услуге",
                     "type": "string",
                     "example": "7a13445c-d6df-4111-abc0-abb12f610069"
                 }
             }
         },
-        "TransferRequest": {
+        "dto.ReservationListRequest": {
             "type": "object",
-            "required": [
-                "amount",
-                "user_id_from",
-                "user_id_to"
-            ],
             "properties": {
-                "amount": {
-                    "description": "Списание",
-                    "type": "number"
+                "limit": {
+                    "type": "integer",
+                    "minimum": 0
                 },
-                "comment": {
-                    "description": "Коментарий",
-                    "type": "string"
+                "offset": {
+                    "type": "integer",
+                    "minimum": 0
                 },
-                "user_id_from": {
-                    "description": "UUID баланса отправителя",
+                "older_than": {
+                    "description": "Только резервы старше заданного числа секунд",
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 86400
+                },
+                "order_id": {
+                    "description": "UUID заказа",
                     "type": "string",
-                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
+                    "example": "983e8792-6736-41bd-9f1a-7c67f8501645"
                 },
-                "user_id_to": {
-                    "description": "UUID баланса получателя",
+                "service_id": {
+                    "description": "UUID сервиса",
                     "type": "string",
-                    "example": "7a13445c-d6df-4111-abc0-abb12f610068"
+                    "example": "34e16535-480c-43f8-95a9-b7a503499af0"
+                },
+                "user_id": {
+                    "description": "UUID баланса пользователя",
+                    "type": "string",
+                    "example": "7a13445c-d6df-4111-abc0-abb12f610069"
                 }
             }
         }
@@ -8,12 +8,15 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// insertTestDataInServicesTable добавляет тестовые услуги при каждом запуске. Услуга пропускается, если ее
+// идентификатор или название уже заняты, в том числе услугой, созданной или переименованной через каталог
 func insertTestDataInServicesTable(pool *pgxpool.Pool, logger *logging.Logger) {
 	q := `
 		INSERT INTO service (service_id, name)
 		VALUES ('34e16535-480c-43f8-95a9-b7a503499af0', 'Курьерская доставка'),
 				('34e16535-480c-43f8-95a9-b7a503499af1', 'Бронирование'),
-				('34e16535-480c-43f8-95a9-b7a503499af2', 'Дополнительная гарантия для товара');
+				('34e16535-480c-43f8-95a9-b7a503499af2', 'Дополнительная гарантия для товара')
+		ON CONFLICT DO NOTHING;
 		`
 	logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
 	_, err := pool.Exec(context.Background(), q)
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/garet2gis/user_balance_service/internal/config"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+)
+
+// defaultLimits переводит общие лимиты из конфигурации в лимиты операций
+func defaultLimits(cfg config.LimitConfig) []model.Limit {
+	limit := func(op model.OperationType, period model.LimitPeriod, amount money.Amount) model.Limit {
+		return model.Limit{Operation: op, Period: period, Amount: amount}
+	}
+
+	return []model.Limit{
+		limit(model.ReduceOperation, model.SingleLimit, cfg.ReduceSingleLimit),
+		limit(model.ReduceOperation, model.DailyLimit, cfg.ReduceDailyLimit),
+		limit(model.ReduceOperation, model.WeeklyLimit, cfg.ReduceWeeklyLimit),
+		limit(model.ReduceOperation, model.MonthlyLimit, cfg.ReduceMonthlyLimit),
+		limit(model.TransferOperation, model.SingleLimit, cfg.TransferSingleLimit),
+		limit(model.TransferOperation, model.DailyLimit, cfg.TransferDailyLimit),
+		limit(model.TransferOperation, model.WeeklyLimit, cfg.TransferWeeklyLimit),
+		limit(model.TransferOperation, model.MonthlyLimit, cfg.TransferMonthlyLimit),
+		limit(model.ReserveOperation, model.SingleLimit, cfg.ReserveSingleLimit),
+		limit(model.ReserveOperation, model.DailyLimit, cfg.ReserveDailyLimit),
+		limit(model.ReserveOperation, model.WeeklyLimit, cfg.ReserveWeeklyLimit),
+		limit(model.ReserveOperation, model.MonthlyLimit, cfg.ReserveMonthlyLimit),
+	}
+}
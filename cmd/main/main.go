@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"github.com/garet2gis/user_balance_service/internal/config"
 	"github.com/garet2gis/user_balance_service/internal/csv"
 	"github.com/garet2gis/user_balance_service/internal/handler"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
 	"github.com/garet2gis/user_balance_service/internal/repository"
 	"github.com/garet2gis/user_balance_service/internal/service"
+	"github.com/garet2gis/user_balance_service/internal/worker"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
 	"github.com/garet2gis/user_balance_service/pkg/postgresql"
 	"github.com/julienschmidt/httprouter"
@@ -58,7 +62,46 @@ func run(ctx context.Context) error {
 	r := repository.NewRepository(client, logger)
 	c := csv.NewBuilder(logger)
 
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(cfg.PolicyDryRun, logger)
+	if cfg.PolicyFile != "" {
+		err = p.Load(cfg.PolicyFile)
+		if err != nil {
+			return err
+		}
+		// при ошибке в измененном файле продолжают действовать ранее загруженные правила
+		go worker.Run(ctx, "policy-reload", cfg.PolicyReloadInterval, func(ctx context.Context) error {
+			return p.Reload()
+		}, logger)
+	}
+
+	s := service.NewService(r, c, p, logger)
+
+	err = s.SetDefaultLimits(ctx, defaultLimits(cfg.LimitConfig))
+	if err != nil {
+		return err
+	}
+
+	s.SetReservationTTL(cfg.ReservationTTL)
+
+	s.SetRiskConfig(model.RiskConfig{
+		Window:            cfg.RiskWindow,
+		ReviewScore:       cfg.RiskReviewScore,
+		NewRecipientScore: cfg.RiskNewRecipientScore,
+		ReplenishOutScore: cfg.RiskReplenishOutScore,
+		VelocityScore:     cfg.RiskVelocityScore,
+	})
+
+	go worker.Run(ctx, "idempotency-cleanup", cfg.IdempotencyCleanupInterval, func(ctx context.Context) error {
+		return s.DeleteExpiredIdempotencyKeys(ctx, cfg.IdempotencyKeyTTL)
+	}, logger)
+
+	go worker.Run(ctx, "schedule-executor", cfg.SchedulePollInterval, func(ctx context.Context) error {
+		return s.RunDueSchedules(ctx, cfg.ScheduleBatchSize, cfg.ScheduleLease, cfg.ScheduleRetryDelay)
+	}, logger)
+
+	go worker.Run(ctx, "reservation-sweeper", cfg.ReservationSweepInterval, func(ctx context.Context) error {
+		return s.ExpireReservations(ctx)
+	}, logger)
 
 	router := httprouter.New()
 
@@ -74,9 +117,34 @@ func run(ctx context.Context) error {
 	reportHandler := handler.NewReportHandler(s, logger)
 	reportHandler.Register(router)
 
+	accountHandler := handler.NewAccountHandler(s, logger)
+	accountHandler.Register(router)
+
+	scheduleHandler := handler.NewScheduleHandler(s, logger)
+	scheduleHandler.Register(router)
+
+	catalogHandler := handler.NewCatalogHandler(s, logger)
+	catalogHandler.Register(router)
+
+	feeHandler := handler.NewFeeHandler(s, logger)
+	feeHandler.Register(router)
+
+	limitHandler := handler.NewLimitHandler(s, logger)
+	limitHandler.Register(router)
+
+	reviewHandler := handler.NewReviewHandler(s, logger)
+	reviewHandler.Register(router)
+
 	// serve csv reports
 	router.ServeFiles("/static/reports/*filepath", http.Dir("static/reports"))
 
+	// счетчики повторов транзакций и runtime публикуются только на служебном сервере
+	if cfg.HTTP.AdminPort != "" {
+		admin := http.NewServeMux()
+		admin.Handle("/debug/vars", expvar.Handler())
+		go startServer(ctx, admin, fmt.Sprintf("%s:%s", cfg.HTTP.AdminHost, cfg.HTTP.AdminPort))
+	}
+
 	host := fmt.Sprintf("%s:%s", cfg.HTTP.Host, cfg.HTTP.Port)
 	swaggerInit(router, host)
 	startServer(ctx, router, host)
@@ -91,7 +159,7 @@ func swaggerInit(router *httprouter.Router, host string) {
 	))
 }
 
-func startServer(ctx context.Context, router *httprouter.Router, host string) {
+func startServer(ctx context.Context, handler http.Handler, host string) {
 	logger := logging.GetLogger()
 
 	listener, listenErr := net.Listen("tcp", host)
@@ -100,7 +168,7 @@ func startServer(ctx context.Context, router *httprouter.Router, host string) {
 		logger.Fatal(listenErr)
 	}
 	server := &http.Server{
-		Handler:      router,
+		Handler:      handler,
 		WriteTimeout: 10 * time.Second,
 		ReadTimeout:  10 * time.Second,
 	}
@@ -6,6 +6,11 @@ import (
 
 var (
 	ErrNotFound = NewAppError(nil, "not found", "")
+	ErrConflict = NewAppError(nil, "conflict", "")
+	// ErrLimitExceeded операция превышает лимит пользователя
+	ErrLimitExceeded = NewAppError(nil, "limit exceeded", "")
+	// ErrTryAgain операция не выполнена из-за конкурентных изменений, запрос можно повторить
+	ErrTryAgain = NewAppError(nil, "try again", "")
 )
 
 type AppError struct {
@@ -28,6 +28,25 @@ func Middleware(h appHandler, l *logging.Logger) http.HandlerFunc {
 					return
 				}
 
+				if errors.Is(err, ErrConflict) {
+					w.WriteHeader(http.StatusConflict)
+					w.Write(appErr.Marshal())
+					return
+				}
+
+				if errors.Is(err, ErrLimitExceeded) {
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					w.Write(appErr.Marshal())
+					return
+				}
+
+				if errors.Is(err, ErrTryAgain) {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write(appErr.Marshal())
+					return
+				}
+
 				w.WriteHeader(http.StatusBadRequest)
 				w.Write(appErr.Marshal())
 				return
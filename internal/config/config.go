@@ -2,7 +2,9 @@ package config
 
 import (
 	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
 	"sync"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -10,6 +12,10 @@ import (
 type HTTP struct {
 	Port string `env:"PORT"  env-required:"true"`
 	Host string `env:"HOST"  env-required:"true"`
+	// Служебный сервер со счетчиками /debug/vars, по умолчанию доступен только локально.
+	// Пустой порт - служебный сервер не запускается
+	AdminPort string `env:"ADMIN_PORT" env-default:"8081"`
+	AdminHost string `env:"ADMIN_HOST" env-default:"127.0.0.1"`
 }
 
 type DBConfig struct {
@@ -21,9 +27,79 @@ type DBConfig struct {
 	AutoMigrate bool   `env:"AUTO_MIGRATE" env-default:"true"`
 }
 
+type IdempotencyConfig struct {
+	// Минимальное время жизни ключа идемпотентности
+	IdempotencyKeyTTL time.Duration `env:"IDEMPOTENCY_KEY_TTL" env-default:"24h"`
+	// Как часто удалять ключи старше IdempotencyKeyTTL
+	IdempotencyCleanupInterval time.Duration `env:"IDEMPOTENCY_CLEANUP_INTERVAL" env-default:"1h"`
+}
+
+type ScheduleConfig struct {
+	// Как часто проверять наступившие расписания
+	SchedulePollInterval time.Duration `env:"SCHEDULE_POLL_INTERVAL" env-default:"10s"`
+	// Сколько расписаний один экземпляр сервиса берет в работу за проверку
+	ScheduleBatchSize int `env:"SCHEDULE_BATCH_SIZE" env-default:"100"`
+	// Сколько расписание принадлежит взявшему его экземпляру сервиса
+	ScheduleLease time.Duration `env:"SCHEDULE_LEASE" env-default:"1m"`
+	// Задержка перед первым повтором неудачного выполнения, далее удваивается
+	ScheduleRetryDelay time.Duration `env:"SCHEDULE_RETRY_DELAY" env-default:"5m"`
+}
+
+type ReservationConfig struct {
+	// Время жизни резерва, если ttl не передан при резервировании. 0 - такие резервы бессрочные, как и до появления срока
+	ReservationTTL time.Duration `env:"RESERVATION_TTL" env-default:"0"`
+	// Как часто отменять просроченные резервы
+	ReservationSweepInterval time.Duration `env:"RESERVATION_SWEEP_INTERVAL" env-default:"1m"`
+}
+
+// LimitConfig общие лимиты расходных операций, действующие для пользователей без собственного лимита.
+// 0 - без ограничения
+type LimitConfig struct {
+	ReduceSingleLimit    money.Amount `env:"LIMIT_REDUCE_SINGLE" env-default:"0"`
+	ReduceDailyLimit     money.Amount `env:"LIMIT_REDUCE_DAILY" env-default:"0"`
+	ReduceWeeklyLimit    money.Amount `env:"LIMIT_REDUCE_WEEKLY" env-default:"0"`
+	ReduceMonthlyLimit   money.Amount `env:"LIMIT_REDUCE_MONTHLY" env-default:"0"`
+	TransferSingleLimit  money.Amount `env:"LIMIT_TRANSFER_SINGLE" env-default:"0"`
+	TransferDailyLimit   money.Amount `env:"LIMIT_TRANSFER_DAILY" env-default:"0"`
+	TransferWeeklyLimit  money.Amount `env:"LIMIT_TRANSFER_WEEKLY" env-default:"0"`
+	TransferMonthlyLimit money.Amount `env:"LIMIT_TRANSFER_MONTHLY" env-default:"0"`
+	ReserveSingleLimit   money.Amount `env:"LIMIT_RESERVE_SINGLE" env-default:"0"`
+	ReserveDailyLimit    money.Amount `env:"LIMIT_RESERVE_DAILY" env-default:"0"`
+	ReserveWeeklyLimit   money.Amount `env:"LIMIT_RESERVE_WEEKLY" env-default:"0"`
+	ReserveMonthlyLimit  money.Amount `env:"LIMIT_RESERVE_MONTHLY" env-default:"0"`
+}
+
+type PolicyConfig struct {
+	// Файл правил в формате YAML или JSON. Пусто - все операции разрешены
+	PolicyFile string `env:"POLICY_FILE" env-default:""`
+	// Как часто проверять изменение файла правил
+	PolicyReloadInterval time.Duration `env:"POLICY_RELOAD_INTERVAL" env-default:"10s"`
+	// Только записывать решения правил в лог, не отклоняя операции
+	PolicyDryRun bool `env:"POLICY_DRY_RUN" env-default:"false"`
+}
+
+// RiskConfig веса оценки риска списаний и переводов по активности пользователя за окно RiskWindow
+type RiskConfig struct {
+	RiskWindow time.Duration `env:"RISK_WINDOW" env-default:"10m"`
+	// Оценка, с которой операция отправляется на ручную проверку. 0 - оценка риска выключена
+	RiskReviewScore int `env:"RISK_REVIEW_SCORE" env-default:"0"`
+	// За каждого нового получателя переводов
+	RiskNewRecipientScore int `env:"RISK_NEW_RECIPIENT_SCORE" env-default:"30"`
+	// За вывод денег после пополнения
+	RiskReplenishOutScore int `env:"RISK_REPLENISH_OUT_SCORE" env-default:"40"`
+	// За каждую исходящую операцию
+	RiskVelocityScore int `env:"RISK_VELOCITY_SCORE" env-default:"10"`
+}
+
 type Config struct {
 	HTTP
 	DBConfig
+	IdempotencyConfig
+	ScheduleConfig
+	ReservationConfig
+	LimitConfig
+	PolicyConfig
+	RiskConfig
 	IsDebug bool `env:"IS_DEBUG" env-default:"false"`
 }
 
@@ -38,9 +38,9 @@ func (b *Builder) CreateReport(rows []model.ReportRow, year, month int) (string,
 	}
 	w := csv.NewWriter(csvFile)
 
-	data := [][]string{{"service_name", "total_revenue"}}
+	data := [][]string{{"service_name", "total_revenue", "total_fee"}}
 	for _, val := range rows {
-		row := []string{val.ServiceName, val.Cost}
+		row := []string{val.ServiceName, val.Cost.String(), val.Fee.String()}
 		data = append(data, row)
 	}
 
@@ -1,8 +1,13 @@
 package dto
 
+import (
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"time"
+)
+
 type BalanceChangeRequest struct {
 	// Баланс пользователя
-	Amount float64 `json:"amount" validate:"gt=0,required"`
+	Amount money.Amount `json:"amount" swaggertype:"number" example:"100.50" validate:"gt=0,required"`
 	// UUID баланса пользователя
 	UserID string `json:"user_id"  example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
 	// Коментарий
@@ -11,11 +16,13 @@ type BalanceChangeRequest struct {
 
 type BalanceGetRequest struct {
 	UserID string `json:"user_id"  example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+	// Момент времени, на который нужен баланс. По умолчанию - текущий баланс
+	AsOf *time.Time `json:"as_of,omitempty" example:"2022-11-01T00:00:00Z"`
 } // @name BalanceGetRequest
 
 type TransferRequest struct {
 	// Списание
-	Amount float64 `json:"amount" validate:"gt=0,required"`
+	Amount money.Amount `json:"amount" swaggertype:"number" example:"100.50" validate:"gt=0,required"`
 	// UUID баланса отправителя
 	UserIDFrom string `json:"user_id_from"  example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
 	// UUID баланса получателя
@@ -23,3 +30,35 @@ type TransferRequest struct {
 	// Коментарий
 	Comment string `json:"comment,omitempty"`
 } // @name TransferRequest
+
+type CreditLimitRequest struct {
+	// UUID баланса пользователя
+	UserID string `json:"user_id"  example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+	// Сумма, на которую баланс может уйти в минус
+	CreditLimit money.Amount `json:"credit_limit" swaggertype:"number" example:"10000" validate:"gte=0"`
+} // @name CreditLimitRequest
+
+type AccountStatusRequest struct {
+	// UUID баланса пользователя
+	UserID string `json:"user_id"  example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+	// Причина смены статуса
+	Comment string `json:"comment,omitempty"`
+} // @name AccountStatusRequest
+
+type SplitTransferRecipient struct {
+	// Сумма получателю
+	Amount money.Amount `json:"amount" swaggertype:"number" example:"100.50" validate:"gt=0,required"`
+	// UUID баланса получателя
+	UserIDTo string `json:"user_id_to"  example:"7a13445c-d6df-4111-abc0-abb12f610068" validate:"required,uuid"`
+	// Коментарий для получателя
+	Comment string `json:"comment,omitempty"`
+} // @name SplitTransferRecipient
+
+type SplitTransferRequest struct {
+	// UUID баланса отправителя
+	UserIDFrom string `json:"user_id_from"  example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+	// Получатели, каждый не больше одного раза
+	Recipients []SplitTransferRecipient `json:"recipients" validate:"required,min=1,max=100,unique=UserIDTo,dive"`
+	// Коментарий для отправителя
+	Comment string `json:"comment,omitempty"`
+} // @name SplitTransferRequest
@@ -0,0 +1,26 @@
+package dto
+
+import (
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+)
+
+type BatchItem struct {
+	// Тип операции
+	Type model.OperationType `json:"type" example:"reduce" validate:"required,oneof=replenish reduce transfer"`
+	// Сумма операции
+	Amount money.Amount `json:"amount" swaggertype:"number" example:"100.50" validate:"gt=0,required"`
+	// UUID баланса пользователя (отправителя для перевода)
+	UserID string `json:"user_id" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+	// UUID баланса получателя, обязателен для перевода и игнорируется для остальных операций
+	UserIDTo string `json:"user_id_to,omitempty" example:"7a13445c-d6df-4111-abc0-abb12f610068" validate:"required_if=Type transfer,omitempty,uuid,necsfield=UserID"`
+	// Коментарий
+	Comment string `json:"comment,omitempty"`
+} // @name BatchItem
+
+type BatchRequest struct {
+	// atomic - все или ничего, best_effort - применить все успешные операции
+	Mode model.BatchMode `json:"mode" example:"atomic" validate:"required,oneof=atomic best_effort"`
+	// Операции выполняются по порядку в одной транзакции
+	Items []BatchItem `json:"items" validate:"required,min=1,max=1000,dive"`
+} // @name BatchRequest
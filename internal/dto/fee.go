@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+)
+
+type FeeRuleRequest struct {
+	// Операция: transfer, reserve или confirm
+	Operation model.OperationType `json:"operation" example:"transfer" validate:"required,oneof=transfer reserve confirm"`
+	// UUID сервиса для reserve и confirm, без него правило действует для всех услуг
+	ServiceID string `json:"service_id,omitempty" example:"34e16535-480c-43f8-95a9-b7a503499af0" validate:"omitempty,uuid"`
+	// Фиксированная часть комиссии
+	Flat money.Amount `json:"flat,omitempty" swaggertype:"number" example:"10" validate:"gte=0"`
+	// Процент от суммы операции
+	Percent float64 `json:"percent,omitempty" example:"1.5" validate:"gte=0,lte=100"`
+	// Минимальная комиссия
+	MinFee money.Amount `json:"min_fee,omitempty" swaggertype:"number" example:"5" validate:"gte=0"`
+	// Максимальная комиссия, по умолчанию без ограничения
+	MaxFee money.Amount `json:"max_fee,omitempty" swaggertype:"number" example:"500" validate:"gte=0"`
+} // @name FeeRuleRequest
+
+type FeeRuleListRequest struct {
+	// Фильтр по операции
+	Operation model.OperationType `json:"operation,omitempty" example:"transfer" validate:"omitempty,oneof=transfer reserve confirm"`
+	// Фильтр по услуге
+	ServiceID string `json:"service_id,omitempty" example:"34e16535-480c-43f8-95a9-b7a503499af0" validate:"omitempty,uuid"`
+	// Только действующие (true) или только отключенные (false) правила, по умолчанию все
+	Active *bool `json:"active,omitempty" example:"true"`
+} // @name FeeRuleListRequest
+
+type FeeRuleIDRequest struct {
+	// UUID правила
+	FeeRuleID string `json:"fee_rule_id" example:"3fa85f64-5717-4562-b3fc-2c963f66afa6" validate:"required,uuid"`
+} // @name FeeRuleIDRequest
@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+)
+
+type LimitRequest struct {
+	// UUID баланса пользователя
+	UserID string `json:"user_id" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+	// Операция: reduce, transfer (исходящие переводы) или reserve
+	Operation model.OperationType `json:"operation" example:"transfer" validate:"required,oneof=reduce transfer reserve"`
+	// Период: single (одна операция), daily, weekly или monthly
+	Period model.LimitPeriod `json:"period" example:"daily" validate:"required,oneof=single daily weekly monthly"`
+	// Лимит
+	Amount money.Amount `json:"amount" swaggertype:"number" example:"10000" validate:"gt=0,required"`
+} // @name LimitRequest
+
+type LimitResetRequest struct {
+	// UUID баланса пользователя
+	UserID string `json:"user_id" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+	// Операция: reduce, transfer или reserve
+	Operation model.OperationType `json:"operation" example:"transfer" validate:"required,oneof=reduce transfer reserve"`
+	// Период: single, daily, weekly или monthly
+	Period model.LimitPeriod `json:"period" example:"daily" validate:"required,oneof=single daily weekly monthly"`
+} // @name LimitResetRequest
+
+type LimitUsageRequest struct {
+	// UUID баланса пользователя
+	UserID string `json:"user_id" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+} // @name LimitUsageRequest
@@ -1,13 +1,100 @@
 package dto
 
-import "github.com/jackc/pgx/v5/pgtype"
+import (
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/jackc/pgx/v5/pgtype"
+)
 
 type ReserveDB struct {
-	UserID        string  `json:"user_id"`
-	ReservationID string  `json:"reservation_id"`
-	ServiceID     string  `json:"service_id"`
-	OrderID       string  `json:"order_id"`
-	Cost          float64 `json:"cost"`
-	Comment       string  `json:"comment"`
+	UserID        string       `json:"user_id"`
+	ReservationID string       `json:"reservation_id"`
+	ServiceID     string       `json:"service_id"`
+	OrderID       string       `json:"order_id"`
+	Cost          money.Amount `json:"cost"`
+	Comment       string       `json:"comment"`
 	CreatedAt     pgtype.Timestamp
 }
+
+// ReservationRef определяет резерв по reservation_id либо по заказу и услуге
+type ReservationRef struct {
+	// UUID резерва
+	ReservationID string `json:"reservation_id,omitempty" example:"5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d" validate:"omitempty,uuid"`
+	// UUID заказа, обязателен без reservation_id
+	OrderID string `json:"order_id,omitempty" example:"983e8792-6736-41bd-9f1a-7c67f8501645" validate:"required_without=ReservationID,omitempty,uuid"`
+	// UUID сервиса, обязателен без reservation_id
+	ServiceID string `json:"service_id,omitempty" example:"34e16535-480c-43f8-95a9-b7a503499af0" validate:"required_without=ReservationID,omitempty,uuid"`
+}
+
+type ReservationCommitRequest struct {
+	ReservationRef
+	// UUID баланса пользователя, уточняет поиск по заказу и услуге
+	UserID string `json:"user_id,omitempty" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"omitempty,uuid"`
+	// Сумма резерва, уточняет поиск по заказу и услуге
+	Cost money.Amount `json:"cost,omitempty" swaggertype:"number" example:"150.50" validate:"gte=0"`
+	// Подтверждаемая сумма, не больше суммы резерва, учитывается только при подтверждении. По умолчанию вся сумма,
+	// остаток резерва возвращается на баланс пользователя
+	Capture money.Amount `json:"capture,omitempty" swaggertype:"number" example:"120.00" validate:"gte=0"`
+	// Дополнительный комментарий
+	Comment string `json:"comment,omitempty"`
+}
+
+type ReservationAdjustRequest struct {
+	ReservationRef
+	// Новая сумма резерва
+	Cost money.Amount `json:"cost" swaggertype:"number" example:"180.00" validate:"gt=0,required"`
+	// Причина изменения
+	Comment string `json:"comment,omitempty"`
+}
+
+type RefundRequest struct {
+	ReservationRef
+	// Сумма возврата, не больше невозвращенной подтвержденной суммы. По умолчанию весь невозвращенный остаток
+	Amount money.Amount `json:"amount,omitempty" swaggertype:"number" example:"20.00" validate:"gte=0"`
+	// Причина возврата
+	Comment string `json:"comment,omitempty"`
+}
+
+type ReservationListRequest struct {
+	// UUID баланса пользователя
+	UserID string `json:"user_id,omitempty" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"omitempty,uuid"`
+	// UUID заказа
+	OrderID string `json:"order_id,omitempty" example:"983e8792-6736-41bd-9f1a-7c67f8501645" validate:"omitempty,uuid"`
+	// UUID сервиса
+	ServiceID string `json:"service_id,omitempty" example:"34e16535-480c-43f8-95a9-b7a503499af0" validate:"omitempty,uuid"`
+	// Только резервы старше заданного числа секунд
+	OlderThan int   `json:"older_than,omitempty" example:"86400" validate:"gte=0"`
+	Limit     int64 `json:"limit,omitempty" validate:"gte=0"`
+	Offset    int64 `json:"offset,omitempty" validate:"gte=0"`
+}
+
+// OrderLine услуга в составе заказа
+type OrderLine struct {
+	// UUID сервиса
+	ServiceID string `json:"service_id" example:"34e16535-480c-43f8-95a9-b7a503499af0" validate:"required,uuid"`
+	// Стоимость услуги
+	Cost money.Amount `json:"cost" swaggertype:"number" example:"150.50" validate:"gt=0,required"`
+	// Комментарий резерва услуги, по умолчанию комментарий заказа
+	Comment string `json:"comment,omitempty"`
+}
+
+type OrderReservationRequest struct {
+	// UUID баланса пользователя
+	UserID string `json:"user_id" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+	// UUID заказа
+	OrderID string `json:"order_id" example:"983e8792-6736-41bd-9f1a-7c67f8501645" validate:"required,uuid"`
+	// Услуги заказа, каждая услуга не больше одного раза
+	Lines []OrderLine `json:"lines" validate:"required,min=1,max=100,unique=ServiceID,dive"`
+	// Комментарий заказа
+	Comment string `json:"comment,omitempty"`
+	// Время жизни резервов в секундах, 0 - бессрочные резервы. По умолчанию RESERVATION_TTL
+	TTL *int `json:"ttl,omitempty" example:"3600" validate:"omitempty,gte=0"`
+}
+
+type OrderCommitRequest struct {
+	// UUID заказа
+	OrderID string `json:"order_id" example:"983e8792-6736-41bd-9f1a-7c67f8501645" validate:"required,uuid"`
+	// UUID баланса пользователя, уточняет поиск резервов заказа
+	UserID string `json:"user_id,omitempty" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"omitempty,uuid"`
+	// Дополнительный комментарий
+	Comment string `json:"comment,omitempty"`
+}
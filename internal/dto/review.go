@@ -0,0 +1,19 @@
+package dto
+
+import "github.com/garet2gis/user_balance_service/internal/model"
+
+type ReviewListRequest struct {
+	// Фильтр по статусу
+	Status model.ReviewStatus `json:"status,omitempty" example:"pending" validate:"omitempty,oneof=pending approved rejected failed"`
+	// UUID баланса пользователя
+	UserID string `json:"user_id,omitempty" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"omitempty,uuid"`
+	Limit  int64  `json:"limit,omitempty" validate:"gte=0"`
+	Offset int64  `json:"offset,omitempty" validate:"gte=0"`
+} // @name ReviewListRequest
+
+type ReviewDecisionRequest struct {
+	// UUID заявки на проверку
+	ReviewID string `json:"review_id" example:"5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d" validate:"required,uuid"`
+	// Комментарий администратора
+	Comment string `json:"comment,omitempty"`
+} // @name ReviewDecisionRequest
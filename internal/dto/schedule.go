@@ -0,0 +1,43 @@
+package dto
+
+import (
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"time"
+)
+
+type ScheduleRequest struct {
+	// Операция: reduce или transfer
+	Operation model.OperationType `json:"operation" example:"transfer" validate:"required,oneof=reduce transfer"`
+	// Сумма операции
+	Amount money.Amount `json:"amount" swaggertype:"number" example:"100.50" validate:"gt=0,required"`
+	// UUID баланса пользователя (отправителя для перевода)
+	UserID string `json:"user_id" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+	// UUID баланса получателя, только для перевода
+	UserIDTo string `json:"user_id_to,omitempty" example:"7a13445c-d6df-4111-abc0-abb12f610068" validate:"required_if=Operation transfer,omitempty,uuid,necsfield=UserID"`
+	// Коментарий
+	Comment string `json:"comment,omitempty"`
+	// Время первого выполнения. Время в прошлом означает выполнение при ближайшем запуске исполнителя,
+	// пропущенные до текущего момента выполнения регулярного расписания не наверстываются
+	StartAt time.Time `json:"start_at" example:"2022-12-10T09:00:00Z" validate:"required"`
+	// Периодичность, по умолчанию once
+	Recurrence model.Recurrence `json:"recurrence,omitempty" example:"monthly" validate:"omitempty,oneof=once daily weekly monthly"`
+	// День месяца для monthly, по умолчанию день start_at
+	DayOfMonth int `json:"day_of_month,omitempty" example:"10" validate:"omitempty,min=1,max=31"`
+	// Сколько раз повторять неудачное выполнение, по умолчанию 3
+	MaxRetries *int `json:"max_retries,omitempty" example:"3" validate:"omitempty,gte=0,lte=10"`
+} // @name ScheduleRequest
+
+type ScheduleListRequest struct {
+	// UUID баланса пользователя
+	UserID string `json:"user_id" example:"7a13445c-d6df-4111-abc0-abb12f610069" validate:"required,uuid"`
+	// Фильтр по статусу
+	Status model.ScheduleStatus `json:"status,omitempty" example:"active" validate:"omitempty,oneof=active paused cancelled completed failed"`
+	Limit  int64                `json:"limit,omitempty" validate:"gte=0"`
+	Offset int64                `json:"offset,omitempty" validate:"gte=0"`
+} // @name ScheduleListRequest
+
+type ScheduleIDRequest struct {
+	// UUID расписания
+	ScheduleID string `json:"schedule_id" example:"5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d" validate:"required,uuid"`
+} // @name ScheduleIDRequest
@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"time"
+)
+
+type ServiceCreateRequest struct {
+	// UUID сервиса, по умолчанию генерируется
+	ServiceID string `json:"service_id,omitempty" example:"34e16535-480c-43f8-95a9-b7a503499af0" validate:"omitempty,uuid"`
+	// Название, уникальное в каталоге
+	Name string `json:"name" example:"Курьерская доставка" validate:"required,max=255"`
+} // @name ServiceCreateRequest
+
+type ServiceRenameRequest struct {
+	// UUID сервиса
+	ServiceID string `json:"service_id" example:"34e16535-480c-43f8-95a9-b7a503499af0" validate:"required,uuid"`
+	// Новое название, уникальное в каталоге
+	Name string `json:"name" example:"Экспресс-доставка" validate:"required,max=255"`
+} // @name ServiceRenameRequest
+
+type ServiceIDRequest struct {
+	// UUID сервиса
+	ServiceID string `json:"service_id" example:"34e16535-480c-43f8-95a9-b7a503499af0" validate:"required,uuid"`
+} // @name ServiceIDRequest
+
+type ServiceListRequest struct {
+	// Только активные (true) или только неактивные (false) услуги, по умолчанию все
+	Active *bool `json:"active,omitempty" example:"true"`
+	Limit  int64 `json:"limit,omitempty" validate:"gte=0"`
+	Offset int64 `json:"offset,omitempty" validate:"gte=0"`
+} // @name ServiceListRequest
+
+type ServicePriceRequest struct {
+	// UUID сервиса
+	ServiceID string `json:"service_id" example:"34e16535-480c-43f8-95a9-b7a503499af0" validate:"required,uuid"`
+	// Фиксированная цена, нельзя передавать вместе с min_price и max_price
+	Price money.Amount `json:"price,omitempty" swaggertype:"number" example:"100.50" validate:"gte=0"`
+	// Минимальная цена диапазона
+	MinPrice money.Amount `json:"min_price,omitempty" swaggertype:"number" example:"50" validate:"gte=0"`
+	// Максимальная цена диапазона
+	MaxPrice money.Amount `json:"max_price,omitempty" swaggertype:"number" example:"500" validate:"gte=0"`
+	// Начало действия цены, по умолчанию текущее время
+	ValidFrom *time.Time `json:"valid_from,omitempty" example:"2022-12-01T00:00:00Z"`
+	// Окончание действия цены
+	ValidTo *time.Time `json:"valid_to,omitempty" example:"2023-01-01T00:00:00Z"`
+} // @name ServicePriceRequest
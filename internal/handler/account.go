@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/precondition"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"path"
+)
+
+const (
+	BasePathAccount = "/admin/account/"
+	CreditLimit     = "/credit-limit/"
+	Freeze          = "/freeze/"
+	Unfreeze        = "/unfreeze/"
+	Close           = "/close/"
+)
+
+type AccountService interface {
+	SetCreditLimit(ctx context.Context, cl dto.CreditLimitRequest) (*model.Balance, error)
+	ChangeAccountStatus(ctx context.Context, sr dto.AccountStatusRequest, status model.AccountStatus) (*model.Balance, error)
+}
+
+type accountHandler struct {
+	logger   *logging.Logger
+	service  AccountService
+	validate *validator.Validate
+}
+
+func NewAccountHandler(s AccountService, l *logging.Logger) Handler {
+	return &accountHandler{
+		logger:   l,
+		service:  s,
+		validate: validator.New(),
+	}
+}
+
+func (h *accountHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathAccount, CreditLimit), apperror.Middleware(h.SetCreditLimit, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathAccount, Freeze), apperror.Middleware(h.FreezeAccount, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathAccount, Unfreeze), apperror.Middleware(h.UnfreezeAccount, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathAccount, Close), apperror.Middleware(h.CloseAccount, h.logger))
+}
+
+// SetCreditLimit godoc
+// @Summary     Установка кредитного лимита пользователя
+// @Description Баланс пользователя сможет уйти в минус не больше чем на credit_limit. Нельзя установить лимит меньше текущего долга
+// @ID          account-credit-limit
+// @Param       credit_limit body   dto.CreditLimitRequest true  "Credit limit"
+// @Param       If-Match     header string                 false "Balance version from ETag"
+// @Tags        Account
+// @Success     200 {object} model.Balance
+// @Header      200 {string} ETag "Balance version"
+// @Failure     400 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/account/credit-limit/ [post]
+func (h *accountHandler) SetCreditLimit(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var cl dto.CreditLimitRequest
+	err := utils.DecodeJSON(w, r, &cl)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(cl)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := withPrecondition(context.Background(), r)
+	if err != nil {
+		return err
+	}
+
+	b, err := h.service.SetCreditLimit(ctx, cl)
+	if err != nil {
+		return writeVersionMismatch(w, err)
+	}
+
+	w.Header().Set(precondition.ETagHeader, precondition.ETag(b.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal balance: %+v", b)
+	}
+
+	w.Write(response)
+
+	return nil
+}
+
+// FreezeAccount godoc
+// @Summary     Заморозка счета пользователя
+// @Description Замороженный счет принимает пополнения, переводы и отмены резервов, но не допускает списаний
+// @ID          account-freeze
+// @Param       status   body   dto.AccountStatusRequest true  "Account"
+// @Param       If-Match header string                   false "Balance version from ETag"
+// @Tags        Account
+// @Success     200 {object} model.Balance
+// @Header      200 {string} ETag "Balance version"
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/account/freeze/ [post]
+func (h *accountHandler) FreezeAccount(w http.ResponseWriter, r *http.Request) error {
+	return h.changeStatus(w, r, model.FrozenStatus)
+}
+
+// UnfreezeAccount godoc
+// @Summary     Разморозка счета пользователя
+// @Description Возвращает замороженный счет в статус active
+// @ID          account-unfreeze
+// @Param       status   body   dto.AccountStatusRequest true  "Account"
+// @Param       If-Match header string                   false "Balance version from ETag"
+// @Tags        Account
+// @Success     200 {object} model.Balance
+// @Header      200 {string} ETag "Balance version"
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/account/unfreeze/ [post]
+func (h *accountHandler) UnfreezeAccount(w http.ResponseWriter, r *http.Request) error {
+	return h.changeStatus(w, r, model.ActiveStatus)
+}
+
+// CloseAccount godoc
+// @Summary     Закрытие счета пользователя
+// @Description Закрыть можно только счет с нулевым балансом и без открытых резерваций. Закрытый счет не принимает операций
+// @ID          account-close
+// @Param       status   body   dto.AccountStatusRequest true  "Account"
+// @Param       If-Match header string                   false "Balance version from ETag"
+// @Tags        Account
+// @Success     200 {object} model.Balance
+// @Header      200 {string} ETag "Balance version"
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/account/close/ [post]
+func (h *accountHandler) CloseAccount(w http.ResponseWriter, r *http.Request) error {
+	return h.changeStatus(w, r, model.ClosedStatus)
+}
+
+func (h *accountHandler) changeStatus(w http.ResponseWriter, r *http.Request, status model.AccountStatus) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var sr dto.AccountStatusRequest
+	err := utils.DecodeJSON(w, r, &sr)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(sr)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := withPrecondition(context.Background(), r)
+	if err != nil {
+		return err
+	}
+
+	b, err := h.service.ChangeAccountStatus(ctx, sr, status)
+	if err != nil {
+		return writeVersionMismatch(w, err)
+	}
+
+	w.Header().Set(precondition.ETagHeader, precondition.ETag(b.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal balance: %+v", b)
+	}
+
+	w.Write(response)
+
+	return nil
+}
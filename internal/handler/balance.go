@@ -7,12 +7,14 @@ import (
 	"github.com/garet2gis/user_balance_service/internal/apperror"
 	"github.com/garet2gis/user_balance_service/internal/dto"
 	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/precondition"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
 	"github.com/garet2gis/user_balance_service/pkg/utils"
 	"github.com/go-playground/validator/v10"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
 	"path"
+	"time"
 )
 
 const (
@@ -20,12 +22,16 @@ const (
 	Replenish       = "/replenish/"
 	Reduce          = "/reduce/"
 	Transfer        = "/transfer/"
+	Batch           = "/batch/"
+	SplitTransfer   = "/transfer/split/"
 )
 
 type BalanceService interface {
 	ChangeUserBalance(ctx context.Context, b dto.BalanceChangeRequest, depositType model.DepositType) (bm *dto.BalanceChangeRequest, err error)
-	GetBalanceByUserID(ctx context.Context, id string) (*model.Balance, error)
+	GetBalanceByUserID(ctx context.Context, id string, asOf *time.Time) (*model.Balance, error)
 	TransferMoney(ctx context.Context, transfer dto.TransferRequest) (err error)
+	ExecuteBatch(ctx context.Context, batch dto.BatchRequest) (*model.BatchResult, error)
+	SplitTransfer(ctx context.Context, transfer dto.SplitTransferRequest) (*model.SplitTransfer, error)
 }
 
 type balanceHandler struct {
@@ -47,18 +53,22 @@ func (h *balanceHandler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodPost, path.Join(BasePathBalance, Replenish), apperror.Middleware(h.ReplenishBalance, h.logger))
 	router.HandlerFunc(http.MethodPost, path.Join(BasePathBalance, Reduce), apperror.Middleware(h.ReduceBalance, h.logger))
 	router.HandlerFunc(http.MethodPost, path.Join(BasePathBalance, Transfer), apperror.Middleware(h.TransferBalance, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathBalance, SplitTransfer), apperror.Middleware(h.SplitTransfer, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathBalance, Batch), apperror.Middleware(h.ExecuteBatch, h.logger))
 }
 
 // GetBalance godoc
-// @Summary Получение баланса пользователя
-// @ID      get-balance
-// @Param   user_id body dto.BalanceGetRequest true "User ID"
-// @Tags    Balance
-// @Success 200 {object} model.Balance
-// @Failure 400 {object} apperror.AppError
-// @Failure 404 {object} apperror.AppError
-// @Failure 418 {object} apperror.AppError
-// @Router  /balance/ [get]
+// @Summary     Получение баланса пользователя
+// @Description Если передан as_of, доступный и зарезервированный остатки считаются на этот момент по истории операций
+// @ID          get-balance
+// @Param       user_id body dto.BalanceGetRequest true "User ID"
+// @Tags        Balance
+// @Success     200 {object} model.Balance
+// @Header      200 {string} ETag "Balance version"
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /balance/ [get]
 func (h *balanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) error {
 	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
 	w = utils.LogWriter{ResponseWriter: w}
@@ -75,12 +85,20 @@ func (h *balanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) erro
 		return err
 	}
 
-	b, err := h.service.GetBalanceByUserID(context.Background(), uID.UserID)
+	if uID.AsOf != nil && uID.AsOf.After(time.Now()) {
+		return toValidateError(fmt.Errorf("as_of must not be in the future"))
+	}
+
+	b, err := h.service.GetBalanceByUserID(context.Background(), uID.UserID, uID.AsOf)
 	if err != nil {
 		return err
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	// исторический баланс не относится к текущей версии
+	if b.AsOf == nil {
+		w.Header().Set(precondition.ETagHeader, precondition.ETag(b.Version))
+	}
 	w.WriteHeader(http.StatusOK)
 
 	response, err := json.Marshal(b)
@@ -97,10 +115,15 @@ func (h *balanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) erro
 // @Summary     Пополняет баланс пользователя
 // @Description В случае пополнения баланса ранее не упомянутого пользователя, он создается в БД
 // @ID          replenish-balance
-// @Param       balance body dto.BalanceChangeRequest true "User balance"
+// @Param       balance         body   dto.BalanceChangeRequest true  "User balance"
+// @Param       If-Match        header string                   false "Balance version from ETag"
+// @Param       Idempotency-Key header string                   false "Idempotency key"
 // @Tags        Balance
 // @Success     200 {object} dto.BalanceChangeRequest
+// @Header      200 {string} ETag "Balance version after the operation"
 // @Failure     400 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
 // @Failure     418 {object} apperror.AppError
 // @Router      /balance/replenish/ [post]
 func (h *balanceHandler) ReplenishBalance(w http.ResponseWriter, r *http.Request) error {
@@ -109,12 +132,19 @@ func (h *balanceHandler) ReplenishBalance(w http.ResponseWriter, r *http.Request
 
 // ReduceBalance godoc
 // @Summary     Уменьшает баланс пользователя
-// @Description В случае уменьшения баланса ранее не упомянутого пользователя, он НЕ создается в БД (возвращается 404)
+// @Description В случае уменьшения баланса ранее не упомянутого пользователя, он НЕ создается в БД (возвращается 404).
+// @Description Списание с высокой оценкой риска не выполняется, а отправляется на ручную проверку (возвращается 202)
 // @ID          reduce-balance
-// @Param       balance body dto.BalanceChangeRequest true "User balance"
+// @Param       balance         body   dto.BalanceChangeRequest true  "User balance"
+// @Param       If-Match        header string                   false "Balance version from ETag"
+// @Param       Idempotency-Key header string                   false "Idempotency key"
 // @Tags        Balance
 // @Success     200 {object} dto.BalanceChangeRequest
+// @Header      200 {string} ETag "Balance version after the operation"
+// @Success     202 {object} model.Review
 // @Failure     400 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
 // @Failure     418 {object} apperror.AppError
 // @Router      /balance/reduce/ [post]
 func (h *balanceHandler) ReduceBalance(w http.ResponseWriter, r *http.Request) error {
@@ -137,11 +167,23 @@ func (h *balanceHandler) changeBalance(w http.ResponseWriter, r *http.Request, d
 		return err
 	}
 
-	newBalance, err := h.service.ChangeUserBalance(context.Background(), b, depositType)
+	ctx, err := withIdempotencyKey(context.Background(), r, string(depositType), b)
 	if err != nil {
 		return err
 	}
 
+	ctx, err = withPrecondition(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	ctx, version := precondition.WithResult(ctx)
+	newBalance, err := h.service.ChangeUserBalance(ctx, b, depositType)
+	if err != nil {
+		return writeNotExecuted(w, err)
+	}
+
+	setETag(w, version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
@@ -156,14 +198,22 @@ func (h *balanceHandler) changeBalance(w http.ResponseWriter, r *http.Request, d
 }
 
 // TransferBalance godoc
-// @Summary Переводит деньги с одного счета на другой
-// @ID      transfer-balance
-// @Param   balance body dto.TransferRequest true "Transfer money"
-// @Tags    Balance
-// @Success 204
-// @Failure 400 {object} apperror.AppError
-// @Failure 418 {object} apperror.AppError
-// @Router  /balance/transfer/ [post]
+// @Summary     Переводит деньги с одного счета на другой
+// @Description С отправителя дополнительно списывается комиссия за перевод, если она настроена.
+// @Description Перевод с высокой оценкой риска не выполняется, а отправляется на ручную проверку (возвращается 202)
+// @ID          transfer-balance
+// @Param       balance         body   dto.TransferRequest true  "Transfer money"
+// @Param       If-Match        header string              false "Balance version from ETag"
+// @Param       Idempotency-Key header string              false "Idempotency key"
+// @Tags        Balance
+// @Success     202 {object} model.Review
+// @Success     204
+// @Header      204 {string} ETag "Sender balance version after the operation"
+// @Failure     400 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /balance/transfer/ [post]
 func (h *balanceHandler) TransferBalance(w http.ResponseWriter, r *http.Request) error {
 	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
 	w = utils.LogWriter{ResponseWriter: w}
@@ -180,12 +230,148 @@ func (h *balanceHandler) TransferBalance(w http.ResponseWriter, r *http.Request)
 		return err
 	}
 
-	err = h.service.TransferMoney(context.Background(), b)
+	ctx, err := withIdempotencyKey(context.Background(), r, "transfer", b)
+	if err != nil {
+		return err
+	}
+
+	ctx, err = withPrecondition(ctx, r)
 	if err != nil {
 		return err
 	}
 
+	ctx, version := precondition.WithResult(ctx)
+	err = h.service.TransferMoney(ctx, b)
+	if err != nil {
+		return writeNotExecuted(w, err)
+	}
+
+	setETag(w, version)
 	w.WriteHeader(http.StatusNoContent)
 
 	return nil
 }
+
+// SplitTransfer godoc
+// @Summary     Переводит деньги от одного отправителя нескольким получателям
+// @Description Все зачисления выполняются в одной транзакции. В истории отправителя перевод отображается одной строкой,
+// @Description у каждого получателя - своей строкой с тем же group_id. Перевод с высокой оценкой риска не выполняется,
+// @Description а отправляется на проверку (202)
+// @ID          split-transfer-balance
+// @Param       transfer        body   dto.SplitTransferRequest true  "Split transfer"
+// @Param       If-Match        header string                   false "Balance version from ETag"
+// @Param       Idempotency-Key header string                   false "Idempotency key"
+// @Tags        Balance
+// @Success     200 {object} model.SplitTransfer
+// @Header      200 {string} ETag "Sender balance version after the operation"
+// @Success     202 {object} model.Review
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /balance/transfer/split/ [post]
+func (h *balanceHandler) SplitTransfer(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var b dto.SplitTransferRequest
+	err := utils.DecodeJSON(w, r, &b)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(b)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := withIdempotencyKey(context.Background(), r, "split_transfer", b)
+	if err != nil {
+		return err
+	}
+
+	ctx, err = withPrecondition(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	ctx, version := precondition.WithResult(ctx)
+	st, err := h.service.SplitTransfer(ctx, b)
+	if err != nil {
+		return writeNotExecuted(w, err)
+	}
+
+	setETag(w, version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to marshal split transfer: %+v", st)
+	}
+
+	w.Write(response)
+
+	return nil
+}
+
+// ExecuteBatch godoc
+// @Summary     Выполняет пакет пополнений, списаний и переводов
+// @Description Операции выполняются по порядку в одной транзакции. В режиме atomic ошибка любой операции отменяет весь пакет,
+// @Description в режиме best_effort применяются все успешные операции. Для каждой операции возвращается новый баланс или ошибка.
+// @Description Списания и переводы с высокой оценкой риска не выполняются и получают статус pending_review с review_id заявки.
+// @Description Заголовок If-Match не поддерживается (возвращается 400)
+// @ID          batch-balance
+// @Param       batch           body   dto.BatchRequest true  "Batch"
+// @Param       Idempotency-Key header string           false "Idempotency key"
+// @Tags        Balance
+// @Success     200 {object} model.BatchResult
+// @Failure     400 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /balance/batch/ [post]
+func (h *balanceHandler) ExecuteBatch(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var b dto.BatchRequest
+	err := utils.DecodeJSON(w, r, &b)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(b)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	// операции пакета затрагивают балансы разных пользователей, одна версия из If-Match к ним не применима
+	if r.Header.Get(precondition.Header) != "" {
+		return toValidateError(fmt.Errorf("%s is not supported for batch operations", precondition.Header))
+	}
+
+	ctx, err := withIdempotencyKey(context.Background(), r, "batch", b)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.service.ExecuteBatch(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response, err := json.Marshal(res)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch result: %+v", res)
+	}
+
+	w.Write(response)
+
+	return nil
+}
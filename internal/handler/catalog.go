@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"path"
+)
+
+const (
+	BasePathCatalog   = "/admin/service/"
+	RenameService     = "/rename/"
+	DeactivateService = "/deactivate/"
+	ActivateService   = "/activate/"
+	ServicePrice      = "/price/"
+)
+
+type CatalogService interface {
+	CreateService(ctx context.Context, sc dto.ServiceCreateRequest) (*model.Service, error)
+	RenameService(ctx context.Context, sr dto.ServiceRenameRequest) (*model.Service, error)
+	DeactivateService(ctx context.Context, id string) (*model.Service, error)
+	ActivateService(ctx context.Context, id string) (*model.Service, error)
+	GetServices(ctx context.Context, sl dto.ServiceListRequest) ([]model.Service, error)
+	CreateServicePrice(ctx context.Context, pr dto.ServicePriceRequest) (*model.ServicePrice, error)
+	GetServicePrices(ctx context.Context, serviceID string) ([]model.ServicePrice, error)
+}
+
+type catalogHandler struct {
+	logger   *logging.Logger
+	service  CatalogService
+	validate *validator.Validate
+}
+
+func NewCatalogHandler(s CatalogService, l *logging.Logger) Handler {
+	return &catalogHandler{
+		logger:   l,
+		service:  s,
+		validate: validator.New(),
+	}
+}
+
+func (h *catalogHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodPost, BasePathCatalog, apperror.Middleware(h.CreateService, h.logger))
+	router.HandlerFunc(http.MethodGet, BasePathCatalog, apperror.Middleware(h.GetServices, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathCatalog, RenameService), apperror.Middleware(h.RenameService, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathCatalog, DeactivateService), apperror.Middleware(h.DeactivateService, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathCatalog, ActivateService), apperror.Middleware(h.ActivateService, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathCatalog, ServicePrice), apperror.Middleware(h.CreateServicePrice, h.logger))
+	router.HandlerFunc(http.MethodGet, path.Join(BasePathCatalog, ServicePrice), apperror.Middleware(h.GetServicePrices, h.logger))
+}
+
+// CreateService godoc
+// @Summary     Добавление услуги в каталог
+// @Description Если service_id не передан, он генерируется. Название услуги должно быть уникальным
+// @ID          create-service
+// @Param       service body dto.ServiceCreateRequest true "Service"
+// @Tags        Catalog
+// @Success     201 {object} model.Service
+// @Failure     400 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/service/ [post]
+func (h *catalogHandler) CreateService(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var sc dto.ServiceCreateRequest
+	err := utils.DecodeJSON(w, r, &sc)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(sc)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	s, err := h.service.CreateService(context.Background(), sc)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, s)
+}
+
+// GetServices godoc
+// @Summary     Список услуг каталога
+// @Description Есть необязательная пагинация (limit, offset) и фильтр по активности, сортировка по названию
+// @ID          get-services
+// @Param       services body dto.ServiceListRequest true "Services filter"
+// @Tags        Catalog
+// @Success     200 {array}  model.Service
+// @Failure     400 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/service/ [get]
+func (h *catalogHandler) GetServices(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var sl dto.ServiceListRequest
+	err := utils.DecodeJSON(w, r, &sl)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(sl)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	services, err := h.service.GetServices(context.Background(), sl)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, services)
+}
+
+// RenameService godoc
+// @Summary     Переименование услуги
+// @Description История и отчеты показывают новое название, в том числе для прошлых операций
+// @ID          rename-service
+// @Param       service body dto.ServiceRenameRequest true "Service"
+// @Tags        Catalog
+// @Success     200 {object} model.Service
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/service/rename/ [post]
+func (h *catalogHandler) RenameService(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var sr dto.ServiceRenameRequest
+	err := utils.DecodeJSON(w, r, &sr)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(sr)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	s, err := h.service.RenameService(context.Background(), sr)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, s)
+}
+
+// DeactivateService godoc
+// @Summary     Отключение услуги
+// @Description Новые резервы на отключенную услугу отклоняются. Открытые резервы можно подтвердить или отменить,
+// @Description история и отчеты по услуге сохраняются
+// @ID          deactivate-service
+// @Param       service_id body dto.ServiceIDRequest true "Service ID"
+// @Tags        Catalog
+// @Success     200 {object} model.Service
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/service/deactivate/ [post]
+func (h *catalogHandler) DeactivateService(w http.ResponseWriter, r *http.Request) error {
+	return h.changeActive(w, r, h.service.DeactivateService)
+}
+
+// ActivateService godoc
+// @Summary Повторное включение отключенной услуги
+// @ID      activate-service
+// @Param   service_id body dto.ServiceIDRequest true "Service ID"
+// @Tags    Catalog
+// @Success 200 {object} model.Service
+// @Failure 400 {object} apperror.AppError
+// @Failure 404 {object} apperror.AppError
+// @Failure 418 {object} apperror.AppError
+// @Router  /admin/service/activate/ [post]
+func (h *catalogHandler) ActivateService(w http.ResponseWriter, r *http.Request) error {
+	return h.changeActive(w, r, h.service.ActivateService)
+}
+
+// CreateServicePrice godoc
+// @Summary     Добавление цены услуги в прайс-лист
+// @Description Цена задается фиксированной (price) либо диапазоном (min_price, max_price, одна из границ необязательна).
+// @Description При резервировании действует цена с наибольшим valid_from среди периодов, в которые попадает время резервирования.
+// @Description Если у услуги нет действующей цены, стоимость резерва не проверяется
+// @ID          create-service-price
+// @Param       price body dto.ServicePriceRequest true "Service price"
+// @Tags        Catalog
+// @Success     201 {object} model.ServicePrice
+// @Failure     400 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/service/price/ [post]
+func (h *catalogHandler) CreateServicePrice(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var pr dto.ServicePriceRequest
+	err := utils.DecodeJSON(w, r, &pr)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(pr)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case pr.Price != 0 && (pr.MinPrice != 0 || pr.MaxPrice != 0):
+		return toValidateError(fmt.Errorf("price is not allowed together with min_price and max_price"))
+	case pr.Price == 0 && pr.MinPrice == 0 && pr.MaxPrice == 0:
+		return toValidateError(fmt.Errorf("price or min_price and max_price are required"))
+	case pr.MaxPrice != 0 && pr.MinPrice > pr.MaxPrice:
+		return toValidateError(fmt.Errorf("min_price must not exceed max_price"))
+	case pr.ValidTo != nil && pr.ValidFrom != nil && !pr.ValidTo.After(*pr.ValidFrom):
+		return toValidateError(fmt.Errorf("valid_to must be after valid_from"))
+	}
+
+	p, err := h.service.CreateServicePrice(context.Background(), pr)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, p)
+}
+
+// GetServicePrices godoc
+// @Summary     Прайс-лист услуги
+// @Description Все цены услуги, включая прошедшие и будущие, сортировка по valid_from в desc
+// @ID          get-service-prices
+// @Param       service_id body dto.ServiceIDRequest true "Service ID"
+// @Tags        Catalog
+// @Success     200 {array}  model.ServicePrice
+// @Failure     400 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/service/price/ [get]
+func (h *catalogHandler) GetServicePrices(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var id dto.ServiceIDRequest
+	err := utils.DecodeJSON(w, r, &id)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(id)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	prices, err := h.service.GetServicePrices(context.Background(), id.ServiceID)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, prices)
+}
+
+func (h *catalogHandler) changeActive(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, id string) (*model.Service, error)) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var id dto.ServiceIDRequest
+	err := utils.DecodeJSON(w, r, &id)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(id)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	s, err := change(context.Background(), id.ServiceID)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, s)
+}
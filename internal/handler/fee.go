@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"path"
+)
+
+const (
+	BasePathFee   = "/admin/fee/"
+	DeactivateFee = "/deactivate/"
+)
+
+type FeeService interface {
+	CreateFeeRule(ctx context.Context, fr dto.FeeRuleRequest) (*model.FeeRule, error)
+	GetFeeRules(ctx context.Context, fl dto.FeeRuleListRequest) ([]model.FeeRule, error)
+	DeactivateFeeRule(ctx context.Context, id string) (*model.FeeRule, error)
+}
+
+type feeHandler struct {
+	logger   *logging.Logger
+	service  FeeService
+	validate *validator.Validate
+}
+
+func NewFeeHandler(s FeeService, l *logging.Logger) Handler {
+	return &feeHandler{
+		logger:   l,
+		service:  s,
+		validate: validator.New(),
+	}
+}
+
+func (h *feeHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodPost, BasePathFee, apperror.Middleware(h.CreateFeeRule, h.logger))
+	router.HandlerFunc(http.MethodGet, BasePathFee, apperror.Middleware(h.GetFeeRules, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathFee, DeactivateFee), apperror.Middleware(h.DeactivateFeeRule, h.logger))
+}
+
+// CreateFeeRule godoc
+// @Summary     Установка комиссии за операцию
+// @Description Комиссия равна flat + percent% от суммы операции, но не меньше min_fee и не больше max_fee.
+// @Description За перевод комиссия списывается с отправителя, за резервирование и подтверждение - с пользователя резерва
+// @Description (за подтверждение - от подтвержденной суммы). Правило услуги важнее общего правила операции.
+// @Description Новое правило заменяет действовавшее правило той же операции и услуги
+// @ID          create-fee-rule
+// @Param       fee_rule body dto.FeeRuleRequest true "Fee rule"
+// @Tags        Fee
+// @Success     201 {object} model.FeeRule
+// @Failure     400 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/fee/ [post]
+func (h *feeHandler) CreateFeeRule(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var fr dto.FeeRuleRequest
+	err := utils.DecodeJSON(w, r, &fr)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(fr)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case fr.Flat == 0 && fr.Percent == 0 && fr.MinFee == 0:
+		return toValidateError(fmt.Errorf("flat, percent or min_fee is required"))
+	case fr.MaxFee != 0 && fr.MinFee > fr.MaxFee:
+		return toValidateError(fmt.Errorf("min_fee must not exceed max_fee"))
+	case fr.ServiceID != "" && fr.Operation == model.TransferOperation:
+		return toValidateError(fmt.Errorf("service_id is not allowed for transfer"))
+	}
+
+	f, err := h.service.CreateFeeRule(context.Background(), fr)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, f)
+}
+
+// GetFeeRules godoc
+// @Summary     Список правил комиссий
+// @Description Есть необязательные фильтры по операции, услуге и активности
+// @ID          get-fee-rules
+// @Param       fee_rules body dto.FeeRuleListRequest true "Fee rules filter"
+// @Tags        Fee
+// @Success     200 {array}  model.FeeRule
+// @Failure     400 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/fee/ [get]
+func (h *feeHandler) GetFeeRules(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var fl dto.FeeRuleListRequest
+	err := utils.DecodeJSON(w, r, &fl)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(fl)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	rules, err := h.service.GetFeeRules(context.Background(), fl)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, rules)
+}
+
+// DeactivateFeeRule godoc
+// @Summary Отключение правила комиссии
+// @ID      deactivate-fee-rule
+// @Param   fee_rule_id body dto.FeeRuleIDRequest true "Fee rule ID"
+// @Tags    Fee
+// @Success 200 {object} model.FeeRule
+// @Failure 400 {object} apperror.AppError
+// @Failure 404 {object} apperror.AppError
+// @Failure 418 {object} apperror.AppError
+// @Router  /admin/fee/deactivate/ [post]
+func (h *feeHandler) DeactivateFeeRule(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var id dto.FeeRuleIDRequest
+	err := utils.DecodeJSON(w, r, &id)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(id)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	f, err := h.service.DeactivateFeeRule(context.Background(), id.FeeRuleID)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, f)
+}
@@ -1,17 +1,31 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/precondition"
+	"github.com/garet2gis/user_balance_service/pkg/money"
 	"github.com/go-playground/validator/v10"
 	"github.com/julienschmidt/httprouter"
+	"net/http"
 )
 
+const maxIdempotencyKeyLength = 255
+
 type Handler interface {
 	Register(router *httprouter.Router)
 }
 
 func toJSONDecodeError(err error) error {
+	// некорректная сумма - ошибка валидации, а не формата JSON
+	if errors.Is(err, money.ErrInvalidAmount) {
+		return toValidateError(err)
+	}
 	return apperror.NewAppError(err, "JSON Decode Error", err.Error())
 }
 
@@ -29,3 +43,97 @@ func validate(err error) error {
 	}
 	return nil
 }
+
+// withIdempotencyKey добавляет в контекст ключ идемпотентности из заголовка запроса, если он передан
+func withIdempotencyKey(ctx context.Context, r *http.Request, operation string, request interface{}) (context.Context, error) {
+	value := r.Header.Get(idempotency.Header)
+	if value == "" {
+		return ctx, nil
+	}
+	if len(value) > maxIdempotencyKeyLength {
+		return nil, toValidateError(fmt.Errorf("%s must not be longer than %d characters", idempotency.Header, maxIdempotencyKeyLength))
+	}
+
+	key, err := idempotency.NewKey(value, operation, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return idempotency.WithKey(ctx, key), nil
+}
+
+// withPrecondition добавляет в контекст версии баланса из заголовка If-Match. Без заголовка и для *
+// операция выполняется при любой версии
+func withPrecondition(ctx context.Context, r *http.Request) (context.Context, error) {
+	value := r.Header.Get(precondition.Header)
+	if value == "" {
+		return ctx, nil
+	}
+
+	versions, err := precondition.Parse(value)
+	if err != nil {
+		return nil, toValidateError(err)
+	}
+	if versions == nil {
+		return ctx, nil
+	}
+
+	return precondition.WithVersions(ctx, versions), nil
+}
+
+// setETag отвечает заголовком ETag с версией баланса, которую операция записала в контекст precondition.WithResult.
+// Повтор по ключу идемпотентности версию не записывает, и заголовок не добавляется
+func setETag(w http.ResponseWriter, version *int64) {
+	if *version > 0 {
+		w.Header().Set(precondition.ETagHeader, precondition.ETag(*version))
+	}
+}
+
+// writeVersionMismatch отвечает 412 с текущей версией и балансом, если версия баланса не совпала с If-Match.
+// Остальные ошибки возвращаются без изменений
+func writeVersionMismatch(w http.ResponseWriter, err error) error {
+	var mismatch *model.VersionMismatch
+	if !errors.As(err, &mismatch) {
+		return err
+	}
+
+	if mismatch.Version > 0 {
+		w.Header().Set(precondition.ETagHeader, precondition.ETag(mismatch.Version))
+	}
+	return writeJSON(w, http.StatusPreconditionFailed, mismatch)
+}
+
+// writeNotExecuted отвечает на операцию, которую сервис не выполнил: 412 при несовпадении версии баланса
+// или 202 с заявкой на проверку. Остальные ошибки возвращаются без изменений
+func writeNotExecuted(w http.ResponseWriter, err error) error {
+	var mismatch *model.VersionMismatch
+	if errors.As(err, &mismatch) {
+		return writeVersionMismatch(w, err)
+	}
+	return writeReview(w, err)
+}
+
+// writeReview отвечает 202 с заявкой на проверку, если операция не выполнена, а отправлена на ручную проверку.
+// Остальные ошибки возвращаются без изменений
+func writeReview(w http.ResponseWriter, err error) error {
+	var required *model.ReviewRequired
+	if !errors.As(err, &required) {
+		return err
+	}
+
+	return writeJSON(w, http.StatusAccepted, required.Review)
+}
+
+// writeJSON отвечает статусом status с телом v в формате JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	response, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %+v", v)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+
+	return nil
+}
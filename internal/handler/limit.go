@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"path"
+)
+
+const (
+	BasePathLimit = "/admin/limit/"
+	ResetLimit    = "/reset/"
+)
+
+type LimitService interface {
+	SetLimit(ctx context.Context, lr dto.LimitRequest) ([]model.LimitUsage, error)
+	ResetLimit(ctx context.Context, lr dto.LimitResetRequest) ([]model.LimitUsage, error)
+	GetLimitUsage(ctx context.Context, userID string) ([]model.LimitUsage, error)
+}
+
+type limitHandler struct {
+	logger   *logging.Logger
+	service  LimitService
+	validate *validator.Validate
+}
+
+func NewLimitHandler(s LimitService, l *logging.Logger) Handler {
+	return &limitHandler{
+		logger:   l,
+		service:  s,
+		validate: validator.New(),
+	}
+}
+
+func (h *limitHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodPost, BasePathLimit, apperror.Middleware(h.SetLimit, h.logger))
+	router.HandlerFunc(http.MethodGet, BasePathLimit, apperror.Middleware(h.GetLimitUsage, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathLimit, ResetLimit), apperror.Middleware(h.ResetLimit, h.logger))
+}
+
+// SetLimit godoc
+// @Summary     Установка лимита пользователя
+// @Description Лимит суммы списаний (reduce), исходящих переводов (transfer) или резервирования (reserve) за календарный
+// @Description день, неделю или месяц в UTC либо максимальная сумма одной операции (single). Лимит пользователя заменяет
+// @Description общий лимит из конфигурации. Операция сверх лимита отклоняется с кодом 422
+// @ID          set-limit
+// @Param       limit body dto.LimitRequest true "Limit"
+// @Tags        Limit
+// @Success     200 {array}  model.LimitUsage
+// @Failure     400 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/limit/ [post]
+func (h *limitHandler) SetLimit(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var lr dto.LimitRequest
+	err := utils.DecodeJSON(w, r, &lr)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(lr)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	usage, err := h.service.SetLimit(context.Background(), lr)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, usage)
+}
+
+// ResetLimit godoc
+// @Summary     Удаление лимита пользователя
+// @Description После удаления для пользователя действует общий лимит из конфигурации, если он задан
+// @ID          reset-limit
+// @Param       limit body dto.LimitResetRequest true "Limit"
+// @Tags        Limit
+// @Success     200 {array}  model.LimitUsage
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/limit/reset/ [post]
+func (h *limitHandler) ResetLimit(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var lr dto.LimitResetRequest
+	err := utils.DecodeJSON(w, r, &lr)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(lr)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	usage, err := h.service.ResetLimit(context.Background(), lr)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, usage)
+}
+
+// GetLimitUsage godoc
+// @Summary     Остаток лимитов пользователя
+// @Description Действующие лимиты пользователя, использованная в текущем периоде сумма, остаток и время обновления лимита
+// @ID          get-limit-usage
+// @Param       user_id body dto.LimitUsageRequest true "User ID"
+// @Tags        Limit
+// @Success     200 {array}  model.LimitUsage
+// @Failure     400 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/limit/ [get]
+func (h *limitHandler) GetLimitUsage(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var lr dto.LimitUsageRequest
+	err := utils.DecodeJSON(w, r, &lr)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(lr)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	usage, err := h.service.GetLimitUsage(context.Background(), lr.UserID)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, usage)
+}
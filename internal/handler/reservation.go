@@ -2,8 +2,11 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
 	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/precondition"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
 	"github.com/garet2gis/user_balance_service/pkg/utils"
 	"github.com/go-playground/validator/v10"
@@ -17,11 +20,21 @@ const (
 	Reserve             = "/reserve/"
 	Confirm             = "/confirm/"
 	Cancel              = "/cancel/"
+	Adjust              = "/adjust/"
+	Refund              = "/refund/"
+	ReservationByID     = "/:id"
+	Order               = "/order/"
 )
 
 type ReservationService interface {
-	ReserveMoney(ctx context.Context, rm model.Reservation) error
-	CommitReservation(ctx context.Context, rm model.Reservation, status model.ReservationStatus) error
+	ReserveMoney(ctx context.Context, rm model.Reservation) (*model.ReservationState, error)
+	CommitReservation(ctx context.Context, rc dto.ReservationCommitRequest, status model.ReservationStatus) error
+	AdjustReservation(ctx context.Context, ra dto.ReservationAdjustRequest) (*model.ReservationAdjustment, error)
+	RefundReservation(ctx context.Context, rr dto.RefundRequest) (*model.ReservationState, error)
+	ReserveOrder(ctx context.Context, or dto.OrderReservationRequest) (*model.OrderReservation, error)
+	CommitOrder(ctx context.Context, oc dto.OrderCommitRequest, status model.ReservationStatus) error
+	GetReservation(ctx context.Context, id string) (*model.ReservationState, error)
+	GetOpenReservations(ctx context.Context, rl dto.ReservationListRequest) (*model.ReservationList, error)
 }
 
 type reservationHandler struct {
@@ -42,17 +55,30 @@ func (h *reservationHandler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodPost, path.Join(BasePathReservation, Reserve), apperror.Middleware(h.Reserve, h.logger))
 	router.HandlerFunc(http.MethodPost, path.Join(BasePathReservation, Confirm), apperror.Middleware(h.ConfirmReservation, h.logger))
 	router.HandlerFunc(http.MethodPost, path.Join(BasePathReservation, Cancel), apperror.Middleware(h.CancelReservation, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathReservation, Adjust), apperror.Middleware(h.AdjustReservation, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathReservation, Order, Reserve), apperror.Middleware(h.ReserveOrder, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathReservation, Order, Confirm), apperror.Middleware(h.ConfirmOrder, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathReservation, Order, Cancel), apperror.Middleware(h.CancelOrder, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathReservation, Refund), apperror.Middleware(h.RefundReservation, h.logger))
+	router.HandlerFunc(http.MethodGet, BasePathReservation, apperror.Middleware(h.GetOpenReservations, h.logger))
+	router.HandlerFunc(http.MethodGet, path.Join(BasePathReservation, ReservationByID), apperror.Middleware(h.GetReservation, h.logger))
 }
 
 // Reserve godoc
-// @Summary Резервация денег на услугу
-// @ID      reservation-reserve
-// @Param   reservation body model.Reservation true "Reservation"
-// @Tags    Reservation
-// @Success 204
-// @Failure 400 {object} apperror.AppError
-// @Failure 418 {object} apperror.AppError
-// @Router  /reservation/reserve/ [post]
+// @Summary     Резервация денег на услугу
+// @Description Услуга должна быть активна, а стоимость - совпадать с ценой услуги, действующей в момент резервирования
+// @ID          reservation-reserve
+// @Param       reservation     body   model.Reservation true  "Reservation"
+// @Param       If-Match        header string            false "Balance version from ETag"
+// @Param       Idempotency-Key header string            false "Idempotency key"
+// @Tags        Reservation
+// @Success     201 {object} model.ReservationState
+// @Header      201 {string} ETag "Balance version after the operation"
+// @Failure     400 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /reservation/reserve/ [post]
 func (h *reservationHandler) Reserve(w http.ResponseWriter, r *http.Request) error {
 	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
 	w = utils.LogWriter{ResponseWriter: w}
@@ -69,66 +95,375 @@ func (h *reservationHandler) Reserve(w http.ResponseWriter, r *http.Request) err
 		return err
 	}
 
-	err = h.service.ReserveMoney(context.Background(), reservation)
+	ctx, err := withIdempotencyKey(context.Background(), r, "reserve", reservation)
 	if err != nil {
 		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusNoContent)
+	ctx, err = withPrecondition(ctx, r)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	ctx, version := precondition.WithResult(ctx)
+	state, err := h.service.ReserveMoney(ctx, reservation)
+	if err != nil {
+		return writeVersionMismatch(w, err)
+	}
+
+	setETag(w, version)
+
+	return writeJSON(w, http.StatusCreated, state)
 }
 
 // ConfirmReservation godoc
-// @Summary Подтверждение списывания денег за услугу
-// @ID      reservation-confirm
-// @Param   reservation body model.Reservation true "Reservation"
+// @Summary     Подтверждение списывания денег за услугу
+// @Description Резерв указывается по reservation_id либо по order_id и service_id. Необязательное поле capture позволяет
+// @Description списать часть резерва, остаток в той же транзакции возвращается на баланс и отображается в истории с типом release
+// @ID          reservation-confirm
+// @Param       reservation body   dto.ReservationCommitRequest true  "Reservation"
+// @Param       If-Match    header string                       false "Balance version from ETag"
+// @Tags        Reservation
+// @Success     204
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /reservation/confirm/ [post]
+func (h *reservationHandler) ConfirmReservation(w http.ResponseWriter, r *http.Request) error {
+	return h.commitReservation(w, r, model.Confirm)
+}
+
+// CancelReservation godoc
+// @Summary     Отмена резервации денег за услугу
+// @Description Резерв указывается по reservation_id либо по order_id и service_id
+// @ID          reservation-cancel
+// @Param       reservation body   dto.ReservationCommitRequest true  "Reservation"
+// @Param       If-Match    header string                       false "Balance version from ETag"
+// @Tags        Reservation
+// @Success     204
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /reservation/cancel/ [post]
+func (h *reservationHandler) CancelReservation(w http.ResponseWriter, r *http.Request) error {
+	return h.commitReservation(w, r, model.Cancel)
+}
+
+// AdjustReservation godoc
+// @Summary     Изменение суммы действующего резерва
+// @Description Резерв указывается по reservation_id либо по order_id и service_id. Разница между новой и текущей суммой списывается с баланса
+// @Description или возвращается на него, каждое изменение сохраняется и отображается в истории с типом adjust
+// @ID          reservation-adjust
+// @Param       adjustment      body   dto.ReservationAdjustRequest true  "Reservation adjustment"
+// @Param       If-Match        header string                       false "Balance version from ETag"
+// @Param       Idempotency-Key header string                       false "Idempotency key"
+// @Tags        Reservation
+// @Success     200 {object} model.ReservationAdjustment
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /reservation/adjust/ [post]
+func (h *reservationHandler) AdjustReservation(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var ra dto.ReservationAdjustRequest
+	err := utils.DecodeJSON(w, r, &ra)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(ra)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := withIdempotencyKey(context.Background(), r, "adjust", ra)
+	if err != nil {
+		return err
+	}
+
+	ctx, err = withPrecondition(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	adjustment, err := h.service.AdjustReservation(ctx, ra)
+	if err != nil {
+		return writeVersionMismatch(w, err)
+	}
+
+	return writeJSON(w, http.StatusOK, adjustment)
+}
+
+// ReserveOrder godoc
+// @Summary     Резервация денег на все услуги заказа
+// @Description Для каждой услуги создается отдельный резерв, все резервы создаются в одной транзакции.
+// @Description Отдельную услугу заказа можно подтвердить или отменить по order_id и service_id
+// @ID          reservation-order-reserve
+// @Param       order           body   dto.OrderReservationRequest true  "Order"
+// @Param       If-Match        header string                      false "Balance version from ETag"
+// @Param       Idempotency-Key header string                      false "Idempotency key"
+// @Tags        Reservation
+// @Success     201 {object} model.OrderReservation
+// @Header      201 {string} ETag "Balance version after the operation"
+// @Failure     400 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /reservation/order/reserve/ [post]
+func (h *reservationHandler) ReserveOrder(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var or dto.OrderReservationRequest
+	err := utils.DecodeJSON(w, r, &or)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(or)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := withIdempotencyKey(context.Background(), r, "reserve_order", or)
+	if err != nil {
+		return err
+	}
+
+	ctx, err = withPrecondition(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	ctx, version := precondition.WithResult(ctx)
+	order, err := h.service.ReserveOrder(ctx, or)
+	if err != nil {
+		return writeVersionMismatch(w, err)
+	}
+
+	setETag(w, version)
+
+	return writeJSON(w, http.StatusCreated, order)
+}
+
+// ConfirmOrder godoc
+// @Summary Подтверждение всех действующих резервов заказа
+// @ID      reservation-order-confirm
+// @Param   order    body   dto.OrderCommitRequest true  "Order"
+// @Param   If-Match header string                 false "Balance version from ETag of each user of the order"
 // @Tags    Reservation
 // @Success 204
 // @Failure 400 {object} apperror.AppError
 // @Failure 404 {object} apperror.AppError
+// @Failure 412 {object} model.VersionMismatch
 // @Failure 418 {object} apperror.AppError
-// @Router  /reservation/confirm/ [post]
-func (h *reservationHandler) ConfirmReservation(w http.ResponseWriter, r *http.Request) error {
-	return h.commitReservation(w, r, model.Confirm)
+// @Router  /reservation/order/confirm/ [post]
+func (h *reservationHandler) ConfirmOrder(w http.ResponseWriter, r *http.Request) error {
+	return h.commitOrder(w, r, model.Confirm)
 }
 
-// CancelReservation godoc
-// @Summary Отмена резервации денег за услугу
-// @ID      reservation-cancel
-// @Param   reservation body model.Reservation true "Reservation"
+// CancelOrder godoc
+// @Summary Отмена всех действующих резервов заказа
+// @ID      reservation-order-cancel
+// @Param   order    body   dto.OrderCommitRequest true  "Order"
+// @Param   If-Match header string                 false "Balance version from ETag of each user of the order"
 // @Tags    Reservation
 // @Success 204
 // @Failure 400 {object} apperror.AppError
 // @Failure 404 {object} apperror.AppError
+// @Failure 412 {object} model.VersionMismatch
 // @Failure 418 {object} apperror.AppError
-// @Router  /reservation/cancel/ [post]
-func (h *reservationHandler) CancelReservation(w http.ResponseWriter, r *http.Request) error {
-	return h.commitReservation(w, r, model.Cancel)
+// @Router  /reservation/order/cancel/ [post]
+func (h *reservationHandler) CancelOrder(w http.ResponseWriter, r *http.Request) error {
+	return h.commitOrder(w, r, model.Cancel)
+}
+
+// RefundReservation godoc
+// @Summary     Возврат денег по подтвержденному резерву
+// @Description Резерв указывается по reservation_id либо по order_id и service_id. Без amount возвращается весь
+// @Description невозвращенный остаток подтвержденной суммы. Возврат отображается в истории с типом refund
+// @Description и уменьшает выручку услуги в отчете за месяц возврата
+// @ID          reservation-refund
+// @Param       refund          body   dto.RefundRequest true  "Refund"
+// @Param       If-Match        header string            false "Balance version from ETag"
+// @Param       Idempotency-Key header string            false "Idempotency key"
+// @Tags        Reservation
+// @Success     200 {object} model.ReservationState
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     412 {object} model.VersionMismatch
+// @Failure     418 {object} apperror.AppError
+// @Router      /reservation/refund/ [post]
+func (h *reservationHandler) RefundReservation(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var rr dto.RefundRequest
+	err := utils.DecodeJSON(w, r, &rr)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(rr)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := withIdempotencyKey(context.Background(), r, "refund", rr)
+	if err != nil {
+		return err
+	}
+
+	ctx, err = withPrecondition(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	state, err := h.service.RefundReservation(ctx, rr)
+	if err != nil {
+		return writeVersionMismatch(w, err)
+	}
+
+	return writeJSON(w, http.StatusOK, state)
+}
+
+// GetReservation godoc
+// @Summary Текущее состояние резерва
+// @ID      get-reservation
+// @Param   id path string true "Reservation ID"
+// @Tags    Reservation
+// @Success 200 {object} model.ReservationState
+// @Failure 400 {object} apperror.AppError
+// @Failure 404 {object} apperror.AppError
+// @Failure 418 {object} apperror.AppError
+// @Router  /reservation/{id} [get]
+func (h *reservationHandler) GetReservation(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	id := httprouter.ParamsFromContext(r.Context()).ByName("id")
+	err := h.validate.Var(id, "required,uuid")
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	state, err := h.service.GetReservation(context.Background(), id)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, state)
+}
+
+// GetOpenReservations godoc
+// @Summary     Список действующих резервов
+// @Description Фильтры по user_id, order_id, service_id и возрасту резерва (older_than, в секундах), необязательная пагинация
+// @Description (limit, offset), сортировка по дате создания в asc. Итоги total и held считаются по всем резервам под фильтром
+// @ID          get-open-reservations
+// @Param       filter body dto.ReservationListRequest true "Reservations filter"
+// @Tags        Reservation
+// @Success     200 {object} model.ReservationList
+// @Failure     400 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /reservation/ [get]
+func (h *reservationHandler) GetOpenReservations(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var rl dto.ReservationListRequest
+	err := utils.DecodeJSON(w, r, &rl)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(rl)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	list, err := h.service.GetOpenReservations(context.Background(), rl)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, list)
 }
 
 func (h *reservationHandler) commitReservation(w http.ResponseWriter, r *http.Request, status model.ReservationStatus) error {
 	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
 	w = utils.LogWriter{ResponseWriter: w}
 
-	var reservation model.Reservation
-	err := utils.DecodeJSON(w, r, &reservation)
+	var rc dto.ReservationCommitRequest
+	err := utils.DecodeJSON(w, r, &rc)
 	if err != nil {
 		return toJSONDecodeError(err)
 	}
 
-	err = h.validate.Struct(reservation)
+	err = h.validate.Struct(rc)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	if rc.Capture != 0 && status != model.Confirm {
+		return toValidateError(fmt.Errorf("capture is allowed only for confirm"))
+	}
+
+	ctx, err := withPrecondition(context.Background(), r)
+	if err != nil {
+		return err
+	}
+
+	err = h.service.CommitReservation(ctx, rc, status)
+	if err != nil {
+		return writeVersionMismatch(w, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *reservationHandler) commitOrder(w http.ResponseWriter, r *http.Request, status model.ReservationStatus) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var oc dto.OrderCommitRequest
+	err := utils.DecodeJSON(w, r, &oc)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(oc)
 	err = validate(err)
 	if err != nil {
 		return err
 	}
 
-	err = h.service.CommitReservation(context.Background(), reservation, status)
+	ctx, err := withPrecondition(context.Background(), r)
 	if err != nil {
 		return err
 	}
 
+	err = h.service.CommitOrder(ctx, oc, status)
+	if err != nil {
+		return writeVersionMismatch(w, err)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 
 	return nil
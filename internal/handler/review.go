@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"path"
+)
+
+const (
+	BasePathReview = "/admin/review/"
+	ApproveReview  = "/approve/"
+	RejectReview   = "/reject/"
+)
+
+type ReviewService interface {
+	GetReviews(ctx context.Context, rl dto.ReviewListRequest) ([]model.Review, error)
+	ApproveReview(ctx context.Context, rd dto.ReviewDecisionRequest) (*model.Review, error)
+	RejectReview(ctx context.Context, rd dto.ReviewDecisionRequest) (*model.Review, error)
+}
+
+type reviewHandler struct {
+	logger   *logging.Logger
+	service  ReviewService
+	validate *validator.Validate
+}
+
+func NewReviewHandler(s ReviewService, l *logging.Logger) Handler {
+	return &reviewHandler{
+		logger:   l,
+		service:  s,
+		validate: validator.New(),
+	}
+}
+
+func (h *reviewHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, BasePathReview, apperror.Middleware(h.GetReviews, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathReview, ApproveReview), apperror.Middleware(h.ApproveReview, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathReview, RejectReview), apperror.Middleware(h.RejectReview, h.logger))
+}
+
+// GetReviews godoc
+// @Summary     Очередь операций на ручной проверке
+// @Description Списания и переводы с высокой оценкой риска. Есть необязательная пагинация (limit, offset) и фильтры
+// @Description по статусу и пользователю, сортировка по дате создания
+// @ID          get-reviews
+// @Param       reviews body dto.ReviewListRequest true "Reviews filter"
+// @Tags        Review
+// @Success     200 {array}  model.Review
+// @Failure     400 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/review/ [get]
+func (h *reviewHandler) GetReviews(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var rl dto.ReviewListRequest
+	err := utils.DecodeJSON(w, r, &rl)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(rl)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	reviews, err := h.service.GetReviews(context.Background(), rl)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, reviews)
+}
+
+// ApproveReview godoc
+// @Summary     Одобрение отложенной операции
+// @Description Исходный запрос выполняется с его ключом идемпотентности. Если операция не выполнилась (например, не хватило
+// @Description денег), заявка получает статус failed с описанием ошибки и может быть одобрена повторно
+// @ID          approve-review
+// @Param       review body dto.ReviewDecisionRequest true "Review decision"
+// @Tags        Review
+// @Success     200 {object} model.Review
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /admin/review/approve/ [post]
+func (h *reviewHandler) ApproveReview(w http.ResponseWriter, r *http.Request) error {
+	return h.decide(w, r, h.service.ApproveReview)
+}
+
+// RejectReview godoc
+// @Summary Отклонение отложенной операции
+// @ID      reject-review
+// @Param   review body dto.ReviewDecisionRequest true "Review decision"
+// @Tags    Review
+// @Success 200 {object} model.Review
+// @Failure 400 {object} apperror.AppError
+// @Failure 404 {object} apperror.AppError
+// @Failure 409 {object} apperror.AppError
+// @Failure 418 {object} apperror.AppError
+// @Router  /admin/review/reject/ [post]
+func (h *reviewHandler) RejectReview(w http.ResponseWriter, r *http.Request) error {
+	return h.decide(w, r, h.service.RejectReview)
+}
+
+func (h *reviewHandler) decide(w http.ResponseWriter, r *http.Request,
+	decide func(ctx context.Context, rd dto.ReviewDecisionRequest) (*model.Review, error)) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var rd dto.ReviewDecisionRequest
+	err := utils.DecodeJSON(w, r, &rd)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(rd)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	review, err := decide(context.Background(), rd)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, review)
+}
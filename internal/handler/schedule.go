@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"path"
+)
+
+const (
+	BasePathSchedule = "/schedule/"
+	PauseSchedule    = "/pause/"
+	ResumeSchedule   = "/resume/"
+	CancelSchedule   = "/cancel/"
+	ScheduleRuns     = "/runs/"
+)
+
+type ScheduleService interface {
+	CreateSchedule(ctx context.Context, sr dto.ScheduleRequest) (*model.Schedule, error)
+	GetSchedules(ctx context.Context, sl dto.ScheduleListRequest) ([]model.Schedule, error)
+	PauseSchedule(ctx context.Context, id string) (*model.Schedule, error)
+	ResumeSchedule(ctx context.Context, id string) (*model.Schedule, error)
+	CancelSchedule(ctx context.Context, id string) (*model.Schedule, error)
+	GetScheduleRuns(ctx context.Context, id string) ([]model.ScheduleRun, error)
+}
+
+type scheduleHandler struct {
+	logger   *logging.Logger
+	service  ScheduleService
+	validate *validator.Validate
+}
+
+func NewScheduleHandler(s ScheduleService, l *logging.Logger) Handler {
+	return &scheduleHandler{
+		logger:   l,
+		service:  s,
+		validate: validator.New(),
+	}
+}
+
+func (h *scheduleHandler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodPost, BasePathSchedule, apperror.Middleware(h.CreateSchedule, h.logger))
+	router.HandlerFunc(http.MethodGet, BasePathSchedule, apperror.Middleware(h.GetSchedules, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathSchedule, PauseSchedule), apperror.Middleware(h.PauseSchedule, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathSchedule, ResumeSchedule), apperror.Middleware(h.ResumeSchedule, h.logger))
+	router.HandlerFunc(http.MethodPost, path.Join(BasePathSchedule, CancelSchedule), apperror.Middleware(h.CancelSchedule, h.logger))
+	router.HandlerFunc(http.MethodGet, path.Join(BasePathSchedule, ScheduleRuns), apperror.Middleware(h.GetScheduleRuns, h.logger))
+}
+
+// CreateSchedule godoc
+// @Summary     Создание отложенного или регулярного списания либо перевода
+// @Description Операция выполняется фоновым исполнителем начиная со start_at. Для monthly выполнение происходит в day_of_month,
+// @Description в коротких месяцах - в последний день месяца. Неудачное выполнение повторяется до max_retries раз
+// @ID          create-schedule
+// @Param       schedule body dto.ScheduleRequest true "Schedule"
+// @Tags        Schedule
+// @Success     201 {object} model.Schedule
+// @Failure     400 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /schedule/ [post]
+func (h *scheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var sr dto.ScheduleRequest
+	err := utils.DecodeJSON(w, r, &sr)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(sr)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	if sr.DayOfMonth != 0 && sr.Recurrence != model.Monthly {
+		return toValidateError(fmt.Errorf("day_of_month is allowed only for monthly recurrence"))
+	}
+
+	s, err := h.service.CreateSchedule(context.Background(), sr)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, s)
+}
+
+// GetSchedules godoc
+// @Summary     Список расписаний пользователя
+// @Description Есть необязательная пагинация (limit, offset) и фильтр по статусу, сортировка по дате создания в desc
+// @ID          get-schedules
+// @Param       schedules body dto.ScheduleListRequest true "Schedules filter"
+// @Tags        Schedule
+// @Success     200 {array}  model.Schedule
+// @Failure     400 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /schedule/ [get]
+func (h *scheduleHandler) GetSchedules(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var sl dto.ScheduleListRequest
+	err := utils.DecodeJSON(w, r, &sl)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(sl)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	schedules, err := h.service.GetSchedules(context.Background(), sl)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, schedules)
+}
+
+// PauseSchedule godoc
+// @Summary Приостановка активного расписания
+// @ID      pause-schedule
+// @Param   schedule_id body dto.ScheduleIDRequest true "Schedule ID"
+// @Tags    Schedule
+// @Success 200 {object} model.Schedule
+// @Failure 400 {object} apperror.AppError
+// @Failure 404 {object} apperror.AppError
+// @Failure 409 {object} apperror.AppError
+// @Failure 418 {object} apperror.AppError
+// @Router  /schedule/pause/ [post]
+func (h *scheduleHandler) PauseSchedule(w http.ResponseWriter, r *http.Request) error {
+	return h.changeStatus(w, r, h.service.PauseSchedule)
+}
+
+// ResumeSchedule godoc
+// @Summary     Возобновление приостановленного расписания
+// @Description Выполнение, пропущенное на паузе, выполняется один раз сразу после возобновления
+// @ID          resume-schedule
+// @Param       schedule_id body dto.ScheduleIDRequest true "Schedule ID"
+// @Tags        Schedule
+// @Success     200 {object} model.Schedule
+// @Failure     400 {object} apperror.AppError
+// @Failure     404 {object} apperror.AppError
+// @Failure     409 {object} apperror.AppError
+// @Failure     418 {object} apperror.AppError
+// @Router      /schedule/resume/ [post]
+func (h *scheduleHandler) ResumeSchedule(w http.ResponseWriter, r *http.Request) error {
+	return h.changeStatus(w, r, h.service.ResumeSchedule)
+}
+
+// CancelSchedule godoc
+// @Summary Отмена расписания
+// @ID      cancel-schedule
+// @Param   schedule_id body dto.ScheduleIDRequest true "Schedule ID"
+// @Tags    Schedule
+// @Success 200 {object} model.Schedule
+// @Failure 400 {object} apperror.AppError
+// @Failure 404 {object} apperror.AppError
+// @Failure 409 {object} apperror.AppError
+// @Failure 418 {object} apperror.AppError
+// @Router  /schedule/cancel/ [post]
+func (h *scheduleHandler) CancelSchedule(w http.ResponseWriter, r *http.Request) error {
+	return h.changeStatus(w, r, h.service.CancelSchedule)
+}
+
+// GetScheduleRuns godoc
+// @Summary Попытки выполнения расписания, включая неудачные
+// @ID      get-schedule-runs
+// @Param   schedule_id body dto.ScheduleIDRequest true "Schedule ID"
+// @Tags    Schedule
+// @Success 200 {array}  model.ScheduleRun
+// @Failure 400 {object} apperror.AppError
+// @Failure 418 {object} apperror.AppError
+// @Router  /schedule/runs/ [get]
+func (h *scheduleHandler) GetScheduleRuns(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var id dto.ScheduleIDRequest
+	err := utils.DecodeJSON(w, r, &id)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(id)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	runs, err := h.service.GetScheduleRuns(context.Background(), id.ScheduleID)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, runs)
+}
+
+func (h *scheduleHandler) changeStatus(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, id string) (*model.Schedule, error)) error {
+	h.logger.Tracef("url:%s host:%s", r.URL, r.Host)
+	w = utils.LogWriter{ResponseWriter: w}
+
+	var id dto.ScheduleIDRequest
+	err := utils.DecodeJSON(w, r, &id)
+	if err != nil {
+		return toJSONDecodeError(err)
+	}
+
+	err = h.validate.Struct(id)
+	err = validate(err)
+	if err != nil {
+		return err
+	}
+
+	s, err := change(context.Background(), id.ScheduleID)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, s)
+}
@@ -0,0 +1,50 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Header заголовок, в котором клиент передает ключ идемпотентности
+const Header = "Idempotency-Key"
+
+type Key struct {
+	// Значение заголовка Idempotency-Key
+	Value string
+	// Операция, для которой был передан ключ (replenish, reduce, transfer, reserve)
+	Operation string
+	// sha256 от операции и тела запроса
+	RequestHash string
+}
+
+type ctxKey struct{}
+
+// NewKey создает ключ, привязанный к операции и содержимому запроса
+func NewKey(value, operation string, request interface{}) (Key, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return Key{}, err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(operation))
+	h.Write([]byte{'\n'})
+	h.Write(body)
+
+	return Key{
+		Value:       value,
+		Operation:   operation,
+		RequestHash: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+func WithKey(ctx context.Context, k Key) context.Context {
+	return context.WithValue(ctx, ctxKey{}, k)
+}
+
+func FromContext(ctx context.Context) (Key, bool) {
+	k, ok := ctx.Value(ctxKey{}).(Key)
+	return k, ok
+}
@@ -0,0 +1,142 @@
+package integration_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/garet2gis/user_balance_service/internal/csv"
+	h "github.com/garet2gis/user_balance_service/internal/handler"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
+	"github.com/garet2gis/user_balance_service/internal/repository"
+	"github.com/garet2gis/user_balance_service/internal/service"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+)
+
+func TestCreditLimit(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	h.NewBalanceHandler(s, logger).Register(router)
+	h.NewAccountHandler(s, logger).Register(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, path.Join(h.BasePathAccount, h.CreditLimit), `
+	{
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610071",
+		"credit_limit": 100
+	}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to set credit limit")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Reduce), `
+	{
+		"amount": 60,
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610071"
+	}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to reduce balance within credit limit")
+
+	rr = do(http.MethodGet, h.BasePathBalance, `{"user_id": "7a13445c-d6df-4111-abc0-abb12f610071"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to get balance")
+
+	var balance model.Balance
+	err = json.NewDecoder(rr.Body).Decode(&balance)
+	require.NoError(t, err, "Failed to decode response")
+	require.Equal(t, money.MustParse("-60"), balance.Balance, "Wrong balance")
+	require.Equal(t, money.MustParse("100"), balance.CreditLimit, "Wrong credit limit")
+	require.Equal(t, money.MustParse("40"), balance.AvailableCredit, "Wrong available credit")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Reduce), `
+	{
+		"amount": 50,
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610071"
+	}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Reduce over credit limit must fail")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathAccount, h.CreditLimit), `
+	{
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610071",
+		"credit_limit": 50
+	}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Credit limit below debt must fail")
+}
+
+func TestAccountStatus(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	h.NewBalanceHandler(s, logger).Register(router)
+	h.NewAccountHandler(s, logger).Register(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	const user = `{"user_id": "7a13445c-d6df-4111-abc0-abb12f610072", "comment": "check"}`
+	const amount = `{"amount": 10, "user_id": "7a13445c-d6df-4111-abc0-abb12f610072"}`
+
+	rr := do(http.MethodPost, path.Join(h.BasePathBalance, h.Replenish), amount)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to replenish balance")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathAccount, h.Freeze), user)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to freeze account")
+
+	var balance model.Balance
+	err = json.NewDecoder(rr.Body).Decode(&balance)
+	require.NoError(t, err, "Failed to decode response")
+	require.Equal(t, model.FrozenStatus, balance.Status, "Wrong account status")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathAccount, h.Freeze), user)
+	require.Equal(t, http.StatusConflict, rr.Code, "Freeze of frozen account must fail")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Reduce), amount)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Reduce of frozen account must fail")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Replenish), amount)
+	require.Equal(t, http.StatusOK, rr.Code, "Frozen account must accept replenish")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathAccount, h.Unfreeze), user)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to unfreeze account")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathAccount, h.Close), user)
+	require.Equal(t, http.StatusConflict, rr.Code, "Close of non-empty account must fail")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Reduce), `{"amount": 20, "user_id": "7a13445c-d6df-4111-abc0-abb12f610072"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to reduce balance")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathAccount, h.Close), user)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to close account")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Replenish), amount)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Closed account must reject replenish")
+}
@@ -4,20 +4,32 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
 	"github.com/garet2gis/user_balance_service/internal/csv"
 	"github.com/garet2gis/user_balance_service/internal/dto"
 	h "github.com/garet2gis/user_balance_service/internal/handler"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
 	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
+	"github.com/garet2gis/user_balance_service/internal/precondition"
 	"github.com/garet2gis/user_balance_service/internal/repository"
 	"github.com/garet2gis/user_balance_service/internal/service"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/julienschmidt/httprouter"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestMain(m *testing.M) {
@@ -36,7 +48,8 @@ func TestGetBalance(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	balanceHandler := h.NewBalanceHandler(s, logger)
 	balanceHandler.Register(router)
 
@@ -54,14 +67,17 @@ func TestGetBalance(t *testing.T) {
 	router.ServeHTTP(rr, req)
 
 	require.Equal(t, http.StatusOK, rr.Code, "Failed to get balance")
+	require.Equal(t, `"1"`, rr.Header().Get(precondition.ETagHeader), "Wrong balance ETag")
 
 	var balance model.Balance
 	err = json.NewDecoder(rr.Body).Decode(&balance)
 	require.NoError(t, err, "Failed to decode response")
 
 	var expectedBalance = model.Balance{
-		Balance: 32.32,
+		Balance: money.MustParse("32.32"),
+		Status:  model.ActiveStatus,
 		UserID:  "7a13445c-d6df-4111-abc0-abb12f610062",
+		Version: 1,
 	}
 
 	require.Equal(t, expectedBalance, balance, "Failed to get correct balance")
@@ -77,7 +93,8 @@ func TestGetNonexistentBalance(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	balanceHandler := h.NewBalanceHandler(s, logger)
 	balanceHandler.Register(router)
 
@@ -97,6 +114,47 @@ func TestGetNonexistentBalance(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, rr.Code, "Failed to not found")
 }
 
+func TestGetBalanceAsOf(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	balanceHandler := h.NewBalanceHandler(s, logger)
+	balanceHandler.Register(router)
+
+	getBalance := func(body string) model.Balance {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, h.BasePathBalance, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, "Failed to get balance")
+
+		var balance model.Balance
+		err = json.NewDecoder(rr.Body).Decode(&balance)
+		require.NoError(t, err, "Failed to decode response")
+		return balance
+	}
+
+	current := getBalance(`{"user_id": "7a13445c-d6df-4111-abc0-abb12f610068"}`)
+	require.Equal(t, money.MustParse("121"), current.Balance, "Wrong current balance")
+	require.Equal(t, money.MustParse("50"), current.Reserved, "Wrong current reserved")
+
+	now := getBalance(fmt.Sprintf(`{"user_id": "7a13445c-d6df-4111-abc0-abb12f610068", "as_of": %q}`, time.Now().UTC().Format(time.RFC3339Nano)))
+	require.Equal(t, current.Balance, now.Balance, "Ledger balance differs from current balance")
+	require.Equal(t, current.Reserved, now.Reserved, "Ledger reserved differs from current reserved")
+
+	past := getBalance(`{"user_id": "7a13445c-d6df-4111-abc0-abb12f610068", "as_of": "2000-01-01T00:00:00Z"}`)
+	require.Equal(t, money.Amount(0), past.Balance, "Balance before first operation must be zero")
+	require.Equal(t, money.Amount(0), past.Reserved, "Reserved before first operation must be zero")
+}
+
 func TestCreateReplenishBalance(t *testing.T) {
 	logger := logging.GetLogger()
 	client, err := initTestDB()
@@ -107,7 +165,8 @@ func TestCreateReplenishBalance(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	balanceHandler := h.NewBalanceHandler(s, logger)
 	balanceHandler.Register(router)
 
@@ -127,7 +186,7 @@ func TestCreateReplenishBalance(t *testing.T) {
 	require.Equal(t, http.StatusOK, rr.Code, "Failed to get balance")
 
 	expectedBalance := dto.BalanceChangeRequest{
-		Amount:  20.25,
+		Amount:  money.MustParse("20.25"),
 		UserID:  "7a13445c-d6df-4111-abc0-abb12f610060",
 		Comment: "+20.25",
 	}
@@ -149,7 +208,8 @@ func TestFailedReplenishBalance(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	balanceHandler := h.NewBalanceHandler(s, logger)
 	balanceHandler.Register(router)
 
@@ -170,7 +230,42 @@ func TestFailedReplenishBalance(t *testing.T) {
 
 	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610062")
 	require.NoError(t, err, "Failed to get existing balance")
-	require.Equal(t, 32.32, balance, "Balance wrong replenish")
+	require.Equal(t, money.MustParse("32.32"), balance, "Balance wrong replenish")
+}
+
+func TestTooPreciseReplenishBalance(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	rr := httptest.NewRecorder()
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	balanceHandler := h.NewBalanceHandler(s, logger)
+	balanceHandler.Register(router)
+
+	var data = []byte(`
+	{
+		"amount": 0.005,
+  		"comment": "+0.005",
+  		"user_id": "7a13445c-d6df-4111-abc0-abb12f610062"
+	}`)
+
+	b := bytes.NewBuffer(data)
+	req, err := http.NewRequest(http.MethodPost, path.Join(h.BasePathBalance, h.Replenish), b)
+	require.NoError(t, err, "Failed to create request")
+
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Wrong status code")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610062")
+	require.NoError(t, err, "Failed to get existing balance")
+	require.Equal(t, money.MustParse("32.32"), balance, "Balance wrong replenish")
 }
 
 func TestReduceBalance(t *testing.T) {
@@ -183,7 +278,8 @@ func TestReduceBalance(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	balanceHandler := h.NewBalanceHandler(s, logger)
 	balanceHandler.Register(router)
 
@@ -203,7 +299,7 @@ func TestReduceBalance(t *testing.T) {
 	require.Equal(t, http.StatusOK, rr.Code, "Failed to get balance")
 
 	expectedBalance := dto.BalanceChangeRequest{
-		Amount:  400.34,
+		Amount:  money.MustParse("400.34"),
 		UserID:  "7a13445c-d6df-4111-abc0-abb12f610069",
 		Comment: "-100",
 	}
@@ -225,7 +321,8 @@ func TestFailedReduceBalance(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	balanceHandler := h.NewBalanceHandler(s, logger)
 	balanceHandler.Register(router)
 
@@ -247,7 +344,7 @@ func TestFailedReduceBalance(t *testing.T) {
 	// check what in db
 	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610069")
 	require.NoError(t, err, "Failed to get existing balance")
-	require.Equal(t, 400.34, balance, "Balance wrong replenish")
+	require.Equal(t, money.MustParse("400.34"), balance, "Balance wrong replenish")
 
 }
 
@@ -261,7 +358,8 @@ func TestTransferMoney(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	balanceHandler := h.NewBalanceHandler(s, logger)
 	balanceHandler.Register(router)
 
@@ -285,12 +383,12 @@ func TestTransferMoney(t *testing.T) {
 	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610069")
 	require.NoError(t, err, "Failed to get existing balance")
 
-	require.Equal(t, 300.34, balance, "Balance wrong transfer reduce")
+	require.Equal(t, money.MustParse("300.34"), balance, "Balance wrong transfer reduce")
 
 	balance, err = r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610060")
 	require.NoError(t, err, "Failed to get existing balance")
 
-	require.Equal(t, 120.25, balance, "Balance wrong transfer replenish")
+	require.Equal(t, money.MustParse("120.25"), balance, "Balance wrong transfer replenish")
 }
 
 func TestFailedTransferMoney(t *testing.T) {
@@ -303,7 +401,8 @@ func TestFailedTransferMoney(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	balanceHandler := h.NewBalanceHandler(s, logger)
 	balanceHandler.Register(router)
 
@@ -327,10 +426,571 @@ func TestFailedTransferMoney(t *testing.T) {
 	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610069")
 	require.NoError(t, err, "Failed to get existing balance")
 
-	require.Equal(t, 300.34, balance, "Balance wrong transfer reduce")
+	require.Equal(t, money.MustParse("300.34"), balance, "Balance wrong transfer reduce")
 
 	balance, err = r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610060")
 	require.NoError(t, err, "Failed to get existing balance")
 
-	require.Equal(t, 120.25, balance, "Balance wrong transfer replenish")
+	require.Equal(t, money.MustParse("120.25"), balance, "Balance wrong transfer replenish")
+}
+
+func TestIdempotentReplenishBalance(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	balanceHandler := h.NewBalanceHandler(s, logger)
+	balanceHandler.Register(router)
+
+	var data = `
+	{
+		"amount": %s,
+  		"comment": "idempotent",
+  		"user_id": "7a13445c-d6df-4111-abc0-abb12f610070"
+	}`
+
+	replenish := func(amount string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		b := bytes.NewBufferString(fmt.Sprintf(data, amount))
+		req, err := http.NewRequest(http.MethodPost, path.Join(h.BasePathBalance, h.Replenish), b)
+		require.NoError(t, err, "Failed to create request")
+		req.Header.Set(idempotency.Header, "f3a9c1de-replenish-610070")
+
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	expectedBalance := dto.BalanceChangeRequest{
+		Amount:  money.MustParse("10.50"),
+		UserID:  "7a13445c-d6df-4111-abc0-abb12f610070",
+		Comment: "idempotent",
+	}
+
+	for i := 0; i < 2; i++ {
+		rr := replenish("10.50")
+		require.Equal(t, http.StatusOK, rr.Code, "Wrong status code")
+
+		var balance dto.BalanceChangeRequest
+		err = json.NewDecoder(rr.Body).Decode(&balance)
+		require.NoError(t, err, "Failed to decode response")
+		require.Equal(t, expectedBalance, balance, "Failed to replay replenish")
+	}
+
+	rr := replenish("11")
+	require.Equal(t, http.StatusConflict, rr.Code, "Key reuse with another payload must conflict")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610070")
+	require.NoError(t, err, "Failed to get existing balance")
+	require.Equal(t, money.MustParse("10.50"), balance, "Balance replenished more than once")
+}
+
+func TestBatchBalance(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	balanceHandler := h.NewBalanceHandler(s, logger)
+	balanceHandler.Register(router)
+
+	batch := func(body string) model.BatchResult {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, path.Join(h.BasePathBalance, h.Batch), bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, "Failed to execute batch")
+
+		var res model.BatchResult
+		err = json.NewDecoder(rr.Body).Decode(&res)
+		require.NoError(t, err, "Failed to decode response")
+		return res
+	}
+
+	res := batch(`
+	{
+		"mode": "best_effort",
+		"items": [
+			{"type": "replenish", "amount": 100, "user_id": "7a13445c-d6df-4111-abc0-abb12f610073"},
+			{"type": "reduce", "amount": 500, "user_id": "7a13445c-d6df-4111-abc0-abb12f610073"},
+			{"type": "replenish", "amount": 1, "user_id": "7a13445c-d6df-4111-abc0-abb12f610074"},
+			{"type": "transfer", "amount": 40, "user_id": "7a13445c-d6df-4111-abc0-abb12f610073",
+			 "user_id_to": "7a13445c-d6df-4111-abc0-abb12f610074"}
+		]
+	}`)
+	require.True(t, res.Committed, "Best effort batch must be committed")
+	require.Equal(t, model.AppliedItem, res.Items[0].Status)
+	require.Equal(t, money.MustParse("100"), *res.Items[0].Balance)
+	require.Equal(t, model.FailedItem, res.Items[1].Status)
+	require.NotNil(t, res.Items[1].Error, "Failed item must contain error")
+	require.Equal(t, model.AppliedItem, res.Items[3].Status)
+	require.Equal(t, money.MustParse("60"), *res.Items[3].Balance)
+	require.Equal(t, money.MustParse("41"), *res.Items[3].BalanceTo)
+
+	res = batch(`
+	{
+		"mode": "atomic",
+		"items": [
+			{"type": "reduce", "amount": 10, "user_id": "7a13445c-d6df-4111-abc0-abb12f610073"},
+			{"type": "reduce", "amount": 500, "user_id": "7a13445c-d6df-4111-abc0-abb12f610073"},
+			{"type": "reduce", "amount": 10, "user_id": "7a13445c-d6df-4111-abc0-abb12f610073"}
+		]
+	}`)
+	require.False(t, res.Committed, "Atomic batch with failed item must not be committed")
+	require.Equal(t, model.RolledBackItem, res.Items[0].Status)
+	require.Equal(t, model.FailedItem, res.Items[1].Status)
+	require.Equal(t, model.SkippedItem, res.Items[2].Status)
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610073")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("60"), balance, "Atomic batch must not change balance")
+}
+
+func TestTransferFee(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	h.NewFeeHandler(s, logger).Register(router)
+	h.NewBalanceHandler(s, logger).Register(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, h.BasePathFee, `{"operation": "transfer", "flat": 1, "percent": 2.5, "max_fee": 3}`)
+	require.Equal(t, http.StatusCreated, rr.Code, "Failed to create fee rule")
+
+	var rule model.FeeRule
+	err = json.NewDecoder(rr.Body).Decode(&rule)
+	require.NoError(t, err, "Failed to decode response")
+	// правило перевода действует для всех тестов, поэтому отключается сразу после проверки
+	defer r.DeactivateFeeRule(context.Background(), rule.FeeRuleID)
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("200"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610088",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("10"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610089",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Transfer), `
+	{
+		"amount": 40,
+		"user_id_from": "7a13445c-d6df-4111-abc0-abb12f610088",
+		"user_id_to": "7a13445c-d6df-4111-abc0-abb12f610089"
+	}`)
+	require.Equal(t, http.StatusNoContent, rr.Code, "Failed to transfer money")
+
+	// 1 + 2.5% от 40 = 2
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610088")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("158"), balance, "Sender must pay transfer fee")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Transfer), `
+	{
+		"amount": 100,
+		"user_id_from": "7a13445c-d6df-4111-abc0-abb12f610088",
+		"user_id_to": "7a13445c-d6df-4111-abc0-abb12f610089"
+	}`)
+	require.Equal(t, http.StatusNoContent, rr.Code, "Failed to transfer money")
+
+	balance, err = r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610088")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("55"), balance, "Transfer fee must be capped by max_fee")
+
+	balance, err = r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610089")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("150"), balance, "Recipient must receive full amount")
+
+	history, err := r.GetUserBalanceHistory(context.Background(), dto.BalanceHistory{
+		UserID:     "7a13445c-d6df-4111-abc0-abb12f610088",
+		OrderBy:    "desc",
+		OrderField: "create_date",
+	})
+	require.NoError(t, err, "Failed to get history")
+
+	var fees []money.Amount
+	for _, row := range history {
+		if row.TransactionType == string(model.FeeOperation) {
+			fees = append(fees, row.Amount)
+		}
+	}
+	require.ElementsMatch(t, []money.Amount{money.MustParse("-2"), money.MustParse("-3")}, fees,
+		"Fees must be separate history lines")
+
+	year, month, _ := time.Now().UTC().Date()
+	report, err := r.GetReport(context.Background(), year, int(month))
+	require.NoError(t, err, "Failed to get report")
+	require.Equal(t, model.ReportRow{ServiceName: model.TransfersReportName, Fee: money.MustParse("5")},
+		report[len(report)-1], "Report must show transfer fees")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.SplitTransfer), `
+	{
+		"user_id_from": "7a13445c-d6df-4111-abc0-abb12f610088",
+		"recipients": [{"amount": 40, "user_id_to": "7a13445c-d6df-4111-abc0-abb12f610089"}]
+	}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to split transfer")
+
+	var st model.SplitTransfer
+	err = json.NewDecoder(rr.Body).Decode(&st)
+	require.NoError(t, err, "Failed to decode response")
+	require.Equal(t, money.MustParse("13"), st.Balance, "Split transfer must pay the same fee as a plain transfer")
+}
+
+func TestConcurrentTransfers(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	balanceHandler := h.NewBalanceHandler(s, logger)
+	balanceHandler.Register(router)
+
+	const first, second = "7a13445c-d6df-4111-abc0-abb12f610098", "7a13445c-d6df-4111-abc0-abb12f610099"
+	for _, userID := range []string{first, second} {
+		_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+			Amount: money.MustParse("100"),
+			UserID: userID,
+		}, model.Replenish)
+		require.NoError(t, err, "Failed to replenish")
+	}
+
+	// встречные переводы конфликтуют, прерванные транзакции должны выполниться повторно
+	const transfers = 10
+	codes := make(chan int, 2*transfers)
+	var wg sync.WaitGroup
+	for i := 0; i < transfers; i++ {
+		for _, users := range [][2]string{{first, second}, {second, first}} {
+			wg.Add(1)
+			go func(from, to string) {
+				defer wg.Done()
+				rr := httptest.NewRecorder()
+				req, err := http.NewRequest(http.MethodPost, path.Join(h.BasePathBalance, h.Transfer), bytes.NewBufferString(`
+				{
+					"amount": 5,
+					"user_id_from": "`+from+`",
+					"user_id_to": "`+to+`"
+				}`))
+				if err != nil {
+					codes <- 0
+					return
+				}
+				router.ServeHTTP(rr, req)
+				codes <- rr.Code
+			}(users[0], users[1])
+		}
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		require.Equal(t, http.StatusNoContent, code, "Concurrent transfer must succeed")
+	}
+
+	for _, userID := range []string{first, second} {
+		balance, err := r.GetBalanceByUserID(context.Background(), userID)
+		require.NoError(t, err, "Failed to get existing balance")
+		require.Equal(t, money.MustParse("100"), balance, "Concurrent transfers must net out")
+	}
+}
+
+func TestUnitOfWork(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	ctx := context.Background()
+
+	const userID = "7a13445c-d6df-4111-abc0-abb12f610100"
+	change := func(ctx context.Context, amount string, depositType model.DepositType) error {
+		_, err := r.ChangeUserBalance(ctx, dto.BalanceChangeRequest{
+			Amount: money.MustParse(amount),
+			UserID: userID,
+		}, depositType)
+		return err
+	}
+
+	// ошибка единицы работы откатывает все вызовы в ней
+	failed := errors.New("failed")
+	err = r.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		err := change(ctx, "100", model.Replenish)
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+
+	_, err = r.GetBalanceByUserID(ctx, userID)
+	require.ErrorIs(t, err, apperror.ErrNotFound, "Balance must not be created")
+
+	// ошибка вложенного вызова откатывает только его изменения
+	var reduceErr error
+	var inside money.Amount
+	err = r.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		err := change(ctx, "100", model.Replenish)
+		if err != nil {
+			return err
+		}
+		reduceErr = change(ctx, "500", model.Reduce)
+
+		inside, err = r.GetBalanceByUserID(ctx, userID)
+		return err
+	})
+	require.NoError(t, err, "Failed to commit unit of work")
+	require.Error(t, reduceErr, "Reduce must fail without money")
+	require.Equal(t, money.MustParse("100"), inside, "Reads must see changes of the unit of work")
+
+	balance, err := r.GetBalanceByUserID(ctx, userID)
+	require.NoError(t, err, "Failed to get existing balance")
+	require.Equal(t, money.MustParse("100"), balance, "Failed call must not roll back the unit of work")
+
+	// проверки при фиксации и конфликт сериализации во вложенном вызове вызываются триггерами по комментарию журнала
+	_, err = client.Exec(ctx, `
+		CREATE SEQUENCE uow_test_attempt;
+
+		CREATE FUNCTION uow_test_nested() RETURNS trigger AS $$
+		BEGIN
+			IF NEW.comment = 'serialization failure' AND nextval('uow_test_attempt') = 1 THEN
+				RAISE EXCEPTION 'forced serialization failure' USING ERRCODE = 'serialization_failure';
+			END IF;
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql;
+
+		CREATE FUNCTION uow_test_commit() RETURNS trigger AS $$
+		BEGIN
+			IF NEW.comment = 'check on commit' THEN
+				RAISE EXCEPTION 'forced check violation' USING ERRCODE = 'check_violation';
+			END IF;
+			IF NEW.comment = 'conflict on commit' THEN
+				RAISE EXCEPTION 'forced serialization failure' USING ERRCODE = 'serialization_failure';
+			END IF;
+			RETURN NULL;
+		END
+		$$ LANGUAGE plpgsql;
+
+		CREATE TRIGGER uow_test_nested BEFORE INSERT ON journal
+			FOR EACH ROW EXECUTE FUNCTION uow_test_nested();
+		CREATE CONSTRAINT TRIGGER uow_test_commit AFTER INSERT ON journal
+			DEFERRABLE INITIALLY DEFERRED
+			FOR EACH ROW EXECUTE FUNCTION uow_test_commit();
+		`)
+	require.NoError(t, err, "Failed to create test triggers")
+	defer func() {
+		_, err := client.Exec(ctx, `
+			DROP TRIGGER uow_test_commit ON journal;
+			DROP TRIGGER uow_test_nested ON journal;
+			DROP FUNCTION uow_test_commit();
+			DROP FUNCTION uow_test_nested();
+			DROP SEQUENCE uow_test_attempt;
+			`)
+		require.NoError(t, err, "Failed to drop test triggers")
+	}()
+
+	replenish := func(ctx context.Context, amount, comment string) error {
+		_, err := r.ChangeUserBalance(ctx, dto.BalanceChangeRequest{
+			Amount:  money.MustParse(amount),
+			UserID:  userID,
+			Comment: comment,
+		}, model.Replenish)
+		return err
+	}
+
+	// ошибка фиксации возвращается вызывающему коду, а закрытая ею транзакция не откатывается повторно
+	hook := logtest.NewLocal(logger.Logger)
+	err = r.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		return replenish(ctx, "10", "check on commit")
+	})
+	var pgErr *pgconn.PgError
+	require.True(t, errors.As(err, &pgErr), "Commit error must be returned, got %v", err)
+	require.Equal(t, "23514", pgErr.Code)
+
+	// конфликт сериализации при фиксации повторяется, а после всех попыток возвращается ErrTryAgain
+	attempts := 0
+	err = r.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		attempts++
+		return replenish(ctx, "10", "conflict on commit")
+	})
+	require.ErrorIs(t, err, apperror.ErrTryAgain)
+	require.Equal(t, 5, attempts, "Unit of work must be retried on serialization failure at commit")
+
+	for _, entry := range hook.AllEntries() {
+		require.NotContains(t, entry.Message, "rollback failed", "Failed commit must not be rolled back")
+	}
+
+	// конфликт сериализации во вложенном вызове прерывает единицу работы, даже если fn не вернула ошибку,
+	// и она выполняется заново целиком
+	attempts = 0
+	var nestedErr error
+	err = r.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		attempts++
+		err := replenish(ctx, "10", "")
+		if err != nil {
+			return err
+		}
+		nestedErr = replenish(ctx, "5", "serialization failure")
+		return nil
+	})
+	require.NoError(t, err, "Retried unit of work must be committed")
+	require.Equal(t, 2, attempts, "Unit of work must be retried as a whole")
+	require.NoError(t, nestedErr, "Nested call must succeed on retry")
+
+	balance, err = r.GetBalanceByUserID(ctx, userID)
+	require.NoError(t, err, "Failed to get existing balance")
+	require.Equal(t, money.MustParse("115"), balance, "Failed commits must not change balance, retried unit must apply once")
+}
+
+func TestBalanceVersion(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	h.NewBalanceHandler(s, logger).Register(router)
+	h.NewAccountHandler(s, logger).Register(router)
+	h.NewReservationHandler(s, logger).Register(router)
+
+	const userID = "7a13445c-d6df-4111-abc0-abb12f610101"
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: userID,
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	do := func(method, url, body, ifMatch string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		if ifMatch != "" {
+			req.Header.Set(precondition.Header, ifMatch)
+		}
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	reduce := func(ifMatch string) *httptest.ResponseRecorder {
+		return do(http.MethodPost, path.Join(h.BasePathBalance, h.Reduce), `{"amount": 30, "user_id": "`+userID+`"}`, ifMatch)
+	}
+
+	rr := do(http.MethodGet, h.BasePathBalance, `{"user_id": "`+userID+`"}`, "")
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to get balance")
+	etag := rr.Header().Get(precondition.ETagHeader)
+	require.NotEmpty(t, etag, "Balance must have ETag")
+
+	rr = reduce(etag)
+	require.Equal(t, http.StatusOK, rr.Code, "Reduce with current version must succeed")
+	next := rr.Header().Get(precondition.ETagHeader)
+	require.NotEmpty(t, next, "Reduce must return new version")
+	require.NotEqual(t, etag, next, "Version must change with balance")
+
+	rr = reduce(etag)
+	require.Equal(t, http.StatusPreconditionFailed, rr.Code, "Reduce with stale version must fail")
+	var mismatch model.VersionMismatch
+	err = json.NewDecoder(rr.Body).Decode(&mismatch)
+	require.NoError(t, err, "Failed to decode version mismatch")
+	require.Equal(t, money.MustParse("70"), mismatch.Balance)
+	require.Equal(t, precondition.ETag(mismatch.Version), rr.Header().Get(precondition.ETagHeader))
+	require.NotEqual(t, etag, rr.Header().Get(precondition.ETagHeader), "Version must change with balance")
+	require.Equal(t, next, rr.Header().Get(precondition.ETagHeader), "Mismatch must return version from reduce")
+
+	rr = reduce("stale")
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Malformed If-Match must be rejected")
+
+	rr = reduce("*")
+	require.Equal(t, http.StatusOK, rr.Code, "Reduce with any version must succeed")
+	current := rr.Header().Get(precondition.ETagHeader)
+
+	rr = reduce("W/" + current)
+	require.Equal(t, http.StatusPreconditionFailed, rr.Code, "Weak ETag must not match")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), userID)
+	require.NoError(t, err, "Failed to get existing balance")
+	require.Equal(t, money.MustParse("40"), balance, "Stale reduce must not change balance")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Batch),
+		`{"mode": "atomic", "items": [{"type": "reduce", "amount": 10, "user_id": "`+userID+`"}]}`, current)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Batch must reject If-Match")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathAccount, h.CreditLimit), `{"user_id": "`+userID+`", "credit_limit": 10}`, etag)
+	require.Equal(t, http.StatusPreconditionFailed, rr.Code, "Credit limit with stale version must fail")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathAccount, h.Freeze), `{"user_id": "`+userID+`"}`, etag)
+	require.Equal(t, http.StatusPreconditionFailed, rr.Code, "Freeze with stale version must fail")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathAccount, h.CreditLimit), `{"user_id": "`+userID+`", "credit_limit": 10}`, current)
+	require.Equal(t, http.StatusOK, rr.Code, "Credit limit with current version must succeed")
+	require.NotEqual(t, current, rr.Header().Get(precondition.ETagHeader), "Credit limit must change version")
+
+	_, err = r.ReserveMoney(context.Background(), model.Reservation{
+		UserID:    userID,
+		ServiceID: "34e16535-480c-43f8-95a9-b7a503499af1",
+		OrderID:   "34e16535-480c-43f8-95a9-b7a503499a94",
+		Cost:      money.MustParse("30"),
+	})
+	require.NoError(t, err, "Failed to reserve")
+
+	cancel := `{"order_id": "34e16535-480c-43f8-95a9-b7a503499a94", "service_id": "34e16535-480c-43f8-95a9-b7a503499af1"}`
+	rr = do(http.MethodPost, path.Join(h.BasePathReservation, h.Cancel), cancel, current)
+	require.Equal(t, http.StatusPreconditionFailed, rr.Code, "Cancel with stale version must fail")
+	current = rr.Header().Get(precondition.ETagHeader)
+
+	rr = do(http.MethodPost, path.Join(h.BasePathReservation, h.Cancel), cancel, current)
+	require.Equal(t, http.StatusNoContent, rr.Code, "Cancel with current version must succeed")
+
+	rr = do(http.MethodGet, h.BasePathBalance, `{"user_id": "`+userID+`"}`, "")
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to get balance")
+	current = rr.Header().Get(precondition.ETagHeader)
+
+	// версия сверяется до оценки риска: устаревший запрос не попадает на проверку
+	s.SetRiskConfig(model.RiskConfig{
+		Window:            10 * time.Minute,
+		ReviewScore:       100,
+		NewRecipientScore: 100,
+	})
+	defer s.SetRiskConfig(model.RiskConfig{})
+
+	transfer := `{"amount": 10, "user_id_from": "` + userID + `", "user_id_to": "7a13445c-d6df-4111-abc0-abb12f610112"}`
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Transfer), transfer, etag)
+	require.Equal(t, http.StatusPreconditionFailed, rr.Code, "Risky transfer with stale version must fail before review")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Transfer), transfer, current)
+	require.Equal(t, http.StatusAccepted, rr.Code, "Risky transfer with current version must be sent to review")
 }
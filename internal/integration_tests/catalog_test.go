@@ -0,0 +1,198 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/garet2gis/user_balance_service/internal/csv"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	h "github.com/garet2gis/user_balance_service/internal/handler"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
+	"github.com/garet2gis/user_balance_service/internal/repository"
+	"github.com/garet2gis/user_balance_service/internal/service"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestServiceCatalog(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	h.NewCatalogHandler(s, logger).Register(router)
+	h.NewReservationHandler(s, logger).Register(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, h.BasePathCatalog, `{"name": "Подъем на этаж"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, "Failed to create service")
+
+	var created model.Service
+	err = json.NewDecoder(rr.Body).Decode(&created)
+	require.NoError(t, err, "Failed to decode response")
+	require.True(t, created.Active, "New service must be active")
+
+	rr = do(http.MethodPost, h.BasePathCatalog, `{"name": "Курьерская доставка"}`)
+	require.Equal(t, http.StatusConflict, rr.Code, "Service name must be unique")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathCatalog, h.RenameService), `
+	{
+		"service_id": "`+created.ServiceID+`",
+		"name": "Подъем на этаж без лифта"
+	}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to rename service")
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610086",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathCatalog, h.DeactivateService), `{"service_id": "`+created.ServiceID+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to deactivate service")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathReservation, h.Reserve), `
+	{
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610086",
+		"service_id": "`+created.ServiceID+`",
+		"order_id": "a0b1c2d3-0000-4000-8000-000000000086",
+		"cost": 10
+	}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Reservation of inactive service must be rejected")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610086")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("100"), balance, "Rejected reservation must not change balance")
+
+	rr = do(http.MethodGet, h.BasePathCatalog, `{"active": false}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to get services")
+
+	var inactive []model.Service
+	err = json.NewDecoder(rr.Body).Decode(&inactive)
+	require.NoError(t, err, "Failed to decode response")
+
+	var found *model.Service
+	for i := range inactive {
+		if inactive[i].ServiceID == created.ServiceID {
+			found = &inactive[i]
+		}
+	}
+	require.NotNil(t, found, "Deactivated service must stay in catalog")
+	require.Equal(t, "Подъем на этаж без лифта", found.Name)
+	require.False(t, found.Active)
+
+	rr = do(http.MethodPost, path.Join(h.BasePathCatalog, h.ActivateService), `{"service_id": "`+created.ServiceID+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to activate service")
+}
+
+func TestServicePrice(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	h.NewCatalogHandler(s, logger).Register(router)
+	h.NewReservationHandler(s, logger).Register(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	created, err := r.CreateService(context.Background(), dto.ServiceCreateRequest{Name: "Сборка мебели"})
+	require.NoError(t, err, "Failed to create service")
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("1000"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610087",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	rr := do(http.MethodPost, path.Join(h.BasePathCatalog, h.ServicePrice), `
+	{
+		"service_id": "`+created.ServiceID+`",
+		"price": 50,
+		"min_price": 10
+	}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Fixed price and range must not be combined")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathCatalog, h.ServicePrice), `
+	{
+		"service_id": "`+created.ServiceID+`",
+		"price": 50
+	}`)
+	require.Equal(t, http.StatusCreated, rr.Code, "Failed to create price")
+
+	// будущая цена еще не действует
+	rr = do(http.MethodPost, path.Join(h.BasePathCatalog, h.ServicePrice), `
+	{
+		"service_id": "`+created.ServiceID+`",
+		"min_price": 100,
+		"max_price": 200,
+		"valid_from": "`+time.Now().Add(time.Hour).UTC().Format(time.RFC3339)+`"
+	}`)
+	require.Equal(t, http.StatusCreated, rr.Code, "Failed to create price")
+
+	reserve := func(cost string) *httptest.ResponseRecorder {
+		return do(http.MethodPost, path.Join(h.BasePathReservation, h.Reserve), `
+		{
+			"user_id": "7a13445c-d6df-4111-abc0-abb12f610087",
+			"service_id": "`+created.ServiceID+`",
+			"order_id": "a0b1c2d3-0000-4000-8000-000000000087",
+			"cost": `+cost+`
+		}`)
+	}
+
+	rr = reserve("150")
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Cost must match effective price")
+	require.Contains(t, rr.Body.String(), repository.PriceMismatch.Error())
+
+	rr = reserve("50")
+	require.Equal(t, http.StatusCreated, rr.Code, "Failed to reserve money")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathReservation, h.Adjust), `
+	{
+		"service_id": "`+created.ServiceID+`",
+		"order_id": "a0b1c2d3-0000-4000-8000-000000000087",
+		"cost": 60
+	}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Adjusted cost must match effective price")
+	require.Contains(t, rr.Body.String(), repository.PriceMismatch.Error())
+
+	rr = do(http.MethodGet, path.Join(h.BasePathCatalog, h.ServicePrice), `{"service_id": "`+created.ServiceID+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to get prices")
+
+	var prices []model.ServicePrice
+	err = json.NewDecoder(rr.Body).Decode(&prices)
+	require.NoError(t, err, "Failed to decode response")
+	require.Len(t, prices, 2)
+	require.Equal(t, money.MustParse("100"), prices[0].MinPrice, "Prices must be sorted by valid_from desc")
+}
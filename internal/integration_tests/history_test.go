@@ -8,9 +8,11 @@ import (
 	"github.com/garet2gis/user_balance_service/internal/dto"
 	h "github.com/garet2gis/user_balance_service/internal/handler"
 	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
 	"github.com/garet2gis/user_balance_service/internal/repository"
 	"github.com/garet2gis/user_balance_service/internal/service"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/require"
 	"net/http"
@@ -28,12 +30,13 @@ func TestHistoryBalance(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	historyHandler := h.NewHistoryHandler(s, logger)
 	historyHandler.Register(router)
 
 	var bc = dto.BalanceChangeRequest{
-		Amount:  120.22,
+		Amount:  money.MustParse("120.22"),
 		UserID:  "7a13445c-d6df-4111-abc0-abb12f610063",
 		Comment: "+120.12",
 	}
@@ -42,7 +45,7 @@ func TestHistoryBalance(t *testing.T) {
 	require.NoError(t, err, "Failed to replenish")
 
 	var tr = dto.TransferRequest{
-		Amount:     20.11,
+		Amount:     money.MustParse("20.11"),
 		UserIDFrom: "7a13445c-d6df-4111-abc0-abb12f610063",
 		UserIDTo:   "7a13445c-d6df-4111-abc0-abb12f610064",
 		Comment:    "transfer",
@@ -55,11 +58,11 @@ func TestHistoryBalance(t *testing.T) {
 		UserID:    "7a13445c-d6df-4111-abc0-abb12f610063",
 		ServiceID: "34e16535-480c-43f8-95a9-b7a503499af1",
 		OrderID:   "34e16535-480c-43f8-95a9-b7a503499a00",
-		Cost:      30.11,
+		Cost:      money.MustParse("30.11"),
 		Comment:   "reserve",
 	}
 
-	err = r.ReserveMoney(context.Background(), res)
+	_, err = r.ReserveMoney(context.Background(), res)
 	require.NoError(t, err, "Failed to reserve")
 
 	var data = []byte(`
@@ -98,7 +101,7 @@ func expectedHistory() []HistoryRowTest {
 		ServiceName:     "Бронирование",
 		UserIDFrom:      "",
 		UserIDTo:        "",
-		Amount:          30.11,
+		Amount:          -30.11,
 		TransactionType: "reserve",
 		Comment:         "reserve",
 	}, {
@@ -107,7 +110,7 @@ func expectedHistory() []HistoryRowTest {
 		UserIDFrom:      "",
 		UserIDTo:        "7a13445c-d6df-4111-abc0-abb12f610064",
 		Amount:          -20.11,
-		TransactionType: "balance_change",
+		TransactionType: "transfer",
 		Comment:         "transfer",
 	}, {
 		OrderID:         "",
@@ -115,8 +118,83 @@ func expectedHistory() []HistoryRowTest {
 		UserIDFrom:      "",
 		UserIDTo:        "",
 		Amount:          120.22,
-		TransactionType: "balance_change",
+		TransactionType: "replenish",
 		Comment:         "+120.12",
 	},
 	}
 }
+
+func TestSplitTransferHistory(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+
+	for _, id := range []string{
+		"7a13445c-d6df-4111-abc0-abb12f610075",
+		"7a13445c-d6df-4111-abc0-abb12f610076",
+		"7a13445c-d6df-4111-abc0-abb12f610077",
+	} {
+		_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{Amount: money.MustParse("100"), UserID: id}, model.Replenish)
+		require.NoError(t, err, "Failed to replenish")
+	}
+
+	st, err := r.SplitTransfer(context.Background(), dto.SplitTransferRequest{
+		UserIDFrom: "7a13445c-d6df-4111-abc0-abb12f610075",
+		Comment:    "payout",
+		Recipients: []dto.SplitTransferRecipient{
+			{Amount: money.MustParse("30"), UserIDTo: "7a13445c-d6df-4111-abc0-abb12f610076", Comment: "share 1"},
+			{Amount: money.MustParse("20.50"), UserIDTo: "7a13445c-d6df-4111-abc0-abb12f610077", Comment: "share 2"},
+		},
+	})
+	require.NoError(t, err, "Failed to split transfer")
+	require.Equal(t, money.MustParse("49.50"), st.Balance, "Wrong sender balance")
+
+	history := func(userID string) []model.HistoryRow {
+		rows, err := r.GetUserBalanceHistory(context.Background(), dto.BalanceHistory{
+			UserID:     userID,
+			OrderBy:    "desc",
+			OrderField: "create_date",
+		})
+		require.NoError(t, err, "Failed to get history")
+		return rows
+	}
+
+	sender := history("7a13445c-d6df-4111-abc0-abb12f610075")
+	require.Len(t, sender, 2, "Split transfer must be one row in sender history")
+	require.Equal(t, money.MustParse("-50.50"), sender[0].Amount)
+	require.Equal(t, "split_transfer", sender[0].TransactionType)
+	require.Equal(t, "payout", sender[0].Comment)
+	require.Equal(t, st.GroupID, sender[0].GroupID)
+	require.Empty(t, sender[0].UserIDTo, "Sender row has several recipients")
+
+	recipient := history("7a13445c-d6df-4111-abc0-abb12f610077")
+	require.Equal(t, money.MustParse("20.50"), recipient[0].Amount)
+	require.Equal(t, "share 2", recipient[0].Comment)
+	require.Equal(t, st.GroupID, recipient[0].GroupID)
+	require.Equal(t, "7a13445c-d6df-4111-abc0-abb12f610075", recipient[0].UserIDFrom)
+
+	s := service.NewService(r, csv.NewBuilder(logger), policy.NewEngine(false, logger), logger)
+	for name, recipients := range map[string][]dto.SplitTransferRecipient{
+		"self": {
+			{Amount: money.MustParse("10"), UserIDTo: "7a13445c-d6df-4111-abc0-abb12f610076"},
+			{Amount: money.MustParse("10"), UserIDTo: "7a13445c-d6df-4111-abc0-abb12f610075"},
+		},
+		"duplicate": {
+			{Amount: money.MustParse("10"), UserIDTo: "7a13445c-d6df-4111-abc0-abb12f610076"},
+			{Amount: money.MustParse("10"), UserIDTo: "7a13445c-d6df-4111-abc0-abb12f610076"},
+		},
+	} {
+		_, err = s.SplitTransfer(context.Background(), dto.SplitTransferRequest{
+			UserIDFrom: "7a13445c-d6df-4111-abc0-abb12f610075",
+			Recipients: recipients,
+		})
+		require.Error(t, err, "Split transfer with %s recipient must fail", name)
+	}
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610075")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("49.50"), balance, "Rejected split transfers must not change balance")
+}
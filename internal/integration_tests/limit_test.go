@@ -0,0 +1,186 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/csv"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	h "github.com/garet2gis/user_balance_service/internal/handler"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
+	"github.com/garet2gis/user_balance_service/internal/repository"
+	"github.com/garet2gis/user_balance_service/internal/service"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+)
+
+func TestTransferLimit(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	h.NewLimitHandler(s, logger).Register(router)
+	h.NewBalanceHandler(s, logger).Register(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	transfer := func(amount string) *httptest.ResponseRecorder {
+		return do(http.MethodPost, path.Join(h.BasePathBalance, h.Transfer), `
+		{
+			"amount": `+amount+`,
+			"user_id_from": "7a13445c-d6df-4111-abc0-abb12f610091",
+			"user_id_to": "7a13445c-d6df-4111-abc0-abb12f610092"
+		}`)
+	}
+
+	for _, userID := range []string{"7a13445c-d6df-4111-abc0-abb12f610091", "7a13445c-d6df-4111-abc0-abb12f610092"} {
+		_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+			Amount: money.MustParse("1000"),
+			UserID: userID,
+		}, model.Replenish)
+		require.NoError(t, err, "Failed to replenish")
+	}
+
+	rr := do(http.MethodPost, h.BasePathLimit, `
+	{
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610091",
+		"operation": "transfer",
+		"period": "daily",
+		"amount": 100
+	}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to set limit")
+
+	rr = do(http.MethodPost, h.BasePathLimit, `
+	{
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610091",
+		"operation": "transfer",
+		"period": "single",
+		"amount": 80
+	}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to set limit")
+
+	rr = transfer("90")
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code, "Single operation limit must be enforced")
+
+	rr = transfer("60")
+	require.Equal(t, http.StatusNoContent, rr.Code, "Failed to transfer money")
+
+	rr = transfer("50")
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code, "Daily limit must be enforced")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610091")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("940"), balance, "Rejected transfers must not change balance")
+
+	rr = do(http.MethodGet, h.BasePathLimit, `{"user_id": "7a13445c-d6df-4111-abc0-abb12f610091"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to get limit usage")
+
+	var usage []model.LimitUsage
+	err = json.NewDecoder(rr.Body).Decode(&usage)
+	require.NoError(t, err, "Failed to decode response")
+
+	remaining := make(map[model.LimitPeriod]money.Amount)
+	for _, u := range usage {
+		if u.Operation == model.TransferOperation {
+			remaining[u.Period] = u.Remaining
+		}
+	}
+	require.Equal(t, money.MustParse("40"), remaining[model.DailyLimit])
+	require.Equal(t, money.MustParse("80"), remaining[model.SingleLimit])
+
+	rr = do(http.MethodPost, path.Join(h.BasePathLimit, h.ResetLimit), `
+	{
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610091",
+		"operation": "transfer",
+		"period": "daily"
+	}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to reset limit")
+
+	rr = transfer("50")
+	require.Equal(t, http.StatusNoContent, rr.Code, "Reset limit must not be enforced")
+}
+
+func TestReserveLimit(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+
+	const userID = "7a13445c-d6df-4111-abc0-abb12f610103"
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("1000"),
+		UserID: userID,
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	err = r.SetLimit(context.Background(), model.Limit{
+		UserID:    userID,
+		Operation: model.ReserveOperation,
+		Period:    model.DailyLimit,
+		Amount:    money.MustParse("100"),
+	})
+	require.NoError(t, err, "Failed to set limit")
+
+	reserve := func(orderID, cost string) (*model.ReservationState, error) {
+		return r.ReserveMoney(context.Background(), model.Reservation{
+			UserID:    userID,
+			ServiceID: "34e16535-480c-43f8-95a9-b7a503499af1",
+			OrderID:   orderID,
+			Cost:      money.MustParse(cost),
+		})
+	}
+
+	cancelled, err := reserve("34e16535-480c-43f8-95a9-b7a503499a92", "50")
+	require.NoError(t, err, "Failed to reserve")
+
+	_, err = r.AdjustReservation(context.Background(), dto.ReservationAdjustRequest{
+		ReservationRef: dto.ReservationRef{ReservationID: cancelled.ReservationID},
+		Cost:           money.MustParse("70"),
+	})
+	require.NoError(t, err, "Failed to adjust reservation")
+
+	_, err = reserve("34e16535-480c-43f8-95a9-b7a503499a93", "40")
+	require.ErrorIs(t, err, apperror.ErrLimitExceeded, "Adjusted reservation must use the limit")
+
+	err = r.CommitReservation(context.Background(), dto.ReservationCommitRequest{
+		ReservationRef: dto.ReservationRef{ReservationID: cancelled.ReservationID},
+	}, model.Cancel)
+	require.NoError(t, err, "Failed to cancel reservation")
+
+	// отмененный резерв не расходует лимит
+	confirmed, err := reserve("34e16535-480c-43f8-95a9-b7a503499a93", "40")
+	require.NoError(t, err, "Cancelled reservation must not use the limit")
+
+	err = r.CommitReservation(context.Background(), dto.ReservationCommitRequest{
+		ReservationRef: dto.ReservationRef{ReservationID: confirmed.ReservationID},
+	}, model.Confirm)
+	require.NoError(t, err, "Failed to confirm reservation")
+
+	usage, err := r.GetLimitUsage(context.Background(), userID)
+	require.NoError(t, err, "Failed to get limit usage")
+	require.Len(t, usage, 1)
+	require.Equal(t, money.MustParse("40"), usage[0].Used, "Confirmed reservation must use the limit")
+}
@@ -0,0 +1,189 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/garet2gis/user_balance_service/internal/csv"
+	h "github.com/garet2gis/user_balance_service/internal/handler"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
+	"github.com/garet2gis/user_balance_service/internal/repository"
+	"github.com/garet2gis/user_balance_service/internal/service"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPolicy(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	err = os.WriteFile(file, []byte(`
+rules:
+  - name: replenish-max
+    operations: [replenish]
+    amount_gt: 100000
+    action: deny
+    message: replenish over 100000 is not allowed
+  - name: warranty-requires-comment
+    operations: [reserve]
+    service_ids: [34e16535-480c-43f8-95a9-b7a503499af2]
+    comment_empty: true
+    action: deny
+  - name: large-replenish
+    operations: [replenish]
+    amount_gte: 50000
+    action: flag
+`), 0600)
+	require.NoError(t, err, "Failed to write policy file")
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	require.NoError(t, p.Load(file), "Failed to load policy file")
+	s := service.NewService(r, c, p, logger)
+	h.NewBalanceHandler(s, logger).Register(router)
+	h.NewReservationHandler(s, logger).Register(router)
+
+	doWithKey := func(url, key, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		if key != "" {
+			req.Header.Set(idempotency.Header, key)
+		}
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	do := func(url, body string) *httptest.ResponseRecorder {
+		return doWithKey(url, "", body)
+	}
+
+	replenish := func(amount string) *httptest.ResponseRecorder {
+		return do(path.Join(h.BasePathBalance, h.Replenish), `
+		{
+			"amount": `+amount+`,
+			"user_id": "7a13445c-d6df-4111-abc0-abb12f610093"
+		}`)
+	}
+
+	rr := replenish("100000.01")
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Denied operation must be rejected")
+	require.Contains(t, rr.Body.String(), "replenish over 100000 is not allowed")
+
+	rr = replenish("100000")
+	require.Equal(t, http.StatusOK, rr.Code, "Flagged operation must be executed")
+
+	reserve := func(comment string) *httptest.ResponseRecorder {
+		return do(path.Join(h.BasePathReservation, h.Reserve), `
+		{
+			"user_id": "7a13445c-d6df-4111-abc0-abb12f610093",
+			"service_id": "34e16535-480c-43f8-95a9-b7a503499af2",
+			"order_id": "983e8792-6736-41bd-9f1a-7c67f8501650",
+			"cost": 100,
+			"comment": "`+comment+`"
+		}`)
+	}
+
+	rr = reserve("")
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Reservation without comment must be rejected")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610093")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("100000"), balance, "Denied operations must not change balance")
+
+	// в режиме best_effort отклоняется только операция, запрещенная правилом
+	rr = do(path.Join(h.BasePathBalance, h.Batch), `
+	{
+		"mode": "best_effort",
+		"items": [
+			{"type": "replenish", "amount": 100000.01, "user_id": "7a13445c-d6df-4111-abc0-abb12f610104"},
+			{"type": "replenish", "amount": 10, "user_id": "7a13445c-d6df-4111-abc0-abb12f610104"}
+		]
+	}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to execute batch")
+
+	var batch model.BatchResult
+	err = json.NewDecoder(rr.Body).Decode(&batch)
+	require.NoError(t, err, "Failed to decode response")
+	require.True(t, batch.Committed)
+	require.Equal(t, model.FailedItem, batch.Items[0].Status, "Denied item must fail")
+	require.Equal(t, "replenish over 100000 is not allowed", batch.Items[0].Error.Message)
+	require.Equal(t, model.AppliedItem, batch.Items[1].Status, "Allowed item must be applied")
+
+	replenishWithKey := func(amount string) *httptest.ResponseRecorder {
+		return doWithKey(path.Join(h.BasePathBalance, h.Replenish), "policy-replay-610104", `
+		{
+			"amount": `+amount+`,
+			"user_id": "7a13445c-d6df-4111-abc0-abb12f610104"
+		}`)
+	}
+
+	rr = replenishWithKey("20")
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to replenish")
+	replayed := rr.Body.String()
+
+	// измененный файл подхватывается при следующей проверке
+	err = os.WriteFile(file, []byte(`{"rules": [{"name": "replenish-max", "operations": ["replenish"], "amount_gt": 200000, "action": "deny"}]}`), 0600)
+	require.NoError(t, err, "Failed to write policy file")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(file, later, later))
+	require.NoError(t, p.Reload(), "Failed to reload policy file")
+
+	rr = replenish("100000.01")
+	require.Equal(t, http.StatusOK, rr.Code, "Reloaded rules must be applied")
+
+	// файл с ошибкой не заменяет действующие правила
+	err = os.WriteFile(file, []byte(`{"rules": [{"name": "broken", "action": "block"}]}`), 0600)
+	require.NoError(t, err, "Failed to write policy file")
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(file, later, later))
+	require.Error(t, p.Reload(), "Invalid policy file must be rejected")
+
+	rr = replenish("200000.01")
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Previous rules must stay in effect")
+
+	// в режиме dry run решения только записываются в лог
+	err = os.WriteFile(file, []byte(`{"rules": [{"name": "replenish-max", "operations": ["replenish"], "amount_gt": 200000, "action": "deny"}]}`), 0600)
+	require.NoError(t, err, "Failed to write policy file")
+	dryRun := policy.NewEngine(true, logger)
+	require.NoError(t, dryRun.Load(file), "Failed to load policy file")
+
+	op := policy.Operation{
+		Type:   model.ReplenishOperation,
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610093",
+		Amount: money.MustParse("200000.01"),
+	}
+	require.Error(t, p.Check(op), "Operation must be denied")
+	require.NoError(t, dryRun.Check(op), "Dry run must not deny operations")
+
+	// повтор выполненного запроса не проверяется правилами, загруженными после него
+	err = os.WriteFile(file, []byte(`{"rules": [{"name": "replenish-max", "operations": ["replenish"], "amount_gt": 5, "action": "deny"}]}`), 0600)
+	require.NoError(t, err, "Failed to write policy file")
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(file, later, later))
+	require.NoError(t, p.Reload(), "Failed to reload policy file")
+
+	rr = replenishWithKey("20")
+	require.Equal(t, http.StatusOK, rr.Code, "Replayed request must not be denied by new rules")
+	require.Equal(t, replayed, rr.Body.String(), "Replayed request must return saved response")
+
+	balance, err = r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610104")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("30"), balance, "Replayed request must not be executed twice")
+}
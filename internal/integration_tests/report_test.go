@@ -9,9 +9,11 @@ import (
 	"github.com/garet2gis/user_balance_service/internal/dto"
 	h "github.com/garet2gis/user_balance_service/internal/handler"
 	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
 	"github.com/garet2gis/user_balance_service/internal/repository"
 	"github.com/garet2gis/user_balance_service/internal/service"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/require"
 	"net/http"
@@ -31,7 +33,8 @@ func TestReport(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	reportHandler := h.NewReportHandler(s, logger)
 	reportHandler.Register(router)
 
@@ -71,15 +74,20 @@ func expectedReportRows() []model.ReportRow {
 	return []model.ReportRow{
 		{
 			ServiceName: "Бронирование",
-			Cost:        "57.00",
+			Cost:        money.MustParse("57.00"),
 		},
 		{
 			ServiceName: "Дополнительная гарантия для товара",
-			Cost:        "70.74",
+			Cost:        money.MustParse("70.74"),
 		},
 		{
 			ServiceName: "Курьерская доставка",
-			Cost:        "120.78",
+			Cost:        money.MustParse("120.78"),
+		},
+		// комиссии за переводы и разделенный перевод из TestTransferFee
+		{
+			ServiceName: model.TransfersReportName,
+			Fee:         money.MustParse("7"),
 		},
 	}
 }
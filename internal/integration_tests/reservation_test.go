@@ -3,17 +3,23 @@ package integration_tests
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"github.com/garet2gis/user_balance_service/internal/csv"
+	"github.com/garet2gis/user_balance_service/internal/dto"
 	h "github.com/garet2gis/user_balance_service/internal/handler"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
 	"github.com/garet2gis/user_balance_service/internal/repository"
 	"github.com/garet2gis/user_balance_service/internal/service"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
 	"path"
 	"testing"
+	"time"
 )
 
 func TestCreateReservation(t *testing.T) {
@@ -26,7 +32,8 @@ func TestCreateReservation(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	reservationHandler := h.NewReservationHandler(s, logger)
 	reservationHandler.Register(router)
 
@@ -47,12 +54,18 @@ func TestCreateReservation(t *testing.T) {
 	req.Header.Add("Content-Type", "application/json")
 	router.ServeHTTP(rr, req)
 
-	require.Equal(t, http.StatusNoContent, rr.Code, "Wrong status")
+	require.Equal(t, http.StatusCreated, rr.Code, "Wrong status")
+
+	var state model.ReservationState
+	err = json.NewDecoder(rr.Body).Decode(&state)
+	require.NoError(t, err, "Failed to decode response")
+	require.NotEmpty(t, state.ReservationID, "Reserve must return reservation_id")
+	require.Equal(t, model.ActiveReservation, state.Status)
 
 	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610068")
 	require.NoError(t, err, "Failed to get existing balance")
 
-	require.Equal(t, 100.0, balance, "Balance wrong reserve")
+	require.Equal(t, money.MustParse("100"), balance, "Balance wrong reserve")
 }
 
 func TestFailedCreateReservation(t *testing.T) {
@@ -65,7 +78,8 @@ func TestFailedCreateReservation(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	reservationHandler := h.NewReservationHandler(s, logger)
 	reservationHandler.Register(router)
 
@@ -91,7 +105,7 @@ func TestFailedCreateReservation(t *testing.T) {
 	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610068")
 	require.NoError(t, err, "Failed to get existing balance")
 
-	require.Equal(t, 100.0, balance, "Balance wrong reserve")
+	require.Equal(t, money.MustParse("100"), balance, "Balance wrong reserve")
 }
 
 func TestConfirmReservation(t *testing.T) {
@@ -104,7 +118,8 @@ func TestConfirmReservation(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	reservationHandler := h.NewReservationHandler(s, logger)
 	reservationHandler.Register(router)
 
@@ -130,7 +145,7 @@ func TestConfirmReservation(t *testing.T) {
 	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610068")
 	require.NoError(t, err, "Failed to get existing balance")
 
-	require.Equal(t, 100.0, balance, "Balance wrong confirm")
+	require.Equal(t, money.MustParse("100"), balance, "Balance wrong confirm")
 }
 
 func TestCancelReservation(t *testing.T) {
@@ -143,7 +158,8 @@ func TestCancelReservation(t *testing.T) {
 	rr := httptest.NewRecorder()
 	router := httprouter.New()
 	c := csv.NewBuilder(logger)
-	s := service.NewService(r, c, logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
 	reservationHandler := h.NewReservationHandler(s, logger)
 	reservationHandler.Register(router)
 
@@ -169,5 +185,735 @@ func TestCancelReservation(t *testing.T) {
 	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610068")
 	require.NoError(t, err, "Failed to get existing balance")
 
-	require.Equal(t, 150.0, balance, "Balance wrong cancel")
+	require.Equal(t, money.MustParse("150"), balance, "Balance wrong cancel")
+}
+
+func TestExpireReservation(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("50"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610079",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	ttl := 1
+	_, err = r.ReserveMoney(context.Background(), model.Reservation{
+		UserID:    "7a13445c-d6df-4111-abc0-abb12f610079",
+		ServiceID: "34e16535-480c-43f8-95a9-b7a503499af1",
+		OrderID:   "34e16535-480c-43f8-95a9-b7a503499a79",
+		Cost:      money.MustParse("20"),
+		TTL:       &ttl,
+	})
+	require.NoError(t, err, "Failed to reserve")
+
+	time.Sleep(1100 * time.Millisecond)
+
+	err = s.ExpireReservations(context.Background())
+	require.NoError(t, err, "Failed to expire reservations")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610079")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("50"), balance, "Expired reservation must be returned")
+
+	history, err := r.GetUserBalanceHistory(context.Background(), dto.BalanceHistory{
+		UserID:     "7a13445c-d6df-4111-abc0-abb12f610079",
+		OrderBy:    "desc",
+		OrderField: "create_date",
+	})
+	require.NoError(t, err, "Failed to get history")
+	require.Equal(t, "expired", history[0].TransactionType, "History must show expiry")
+}
+
+func TestReservationTTL(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	s.SetReservationTTL(time.Hour)
+	reservationHandler := h.NewReservationHandler(s, logger)
+	reservationHandler.Register(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	reserve := func(ttl string) model.ReservationState {
+		rr := do(http.MethodPost, path.Join(h.BasePathReservation, h.Reserve), `
+		{
+			"cost": 10,
+			"order_id": "34e16535-480c-43f8-95a9-b7a503499a91",
+			"service_id": "34e16535-480c-43f8-95a9-b7a503499af1",
+			"user_id": "7a13445c-d6df-4111-abc0-abb12f610102"`+ttl+`
+		}`)
+		require.Equal(t, http.StatusCreated, rr.Code, "Failed to reserve")
+
+		var state model.ReservationState
+		err := json.NewDecoder(rr.Body).Decode(&state)
+		require.NoError(t, err, "Failed to decode response")
+		return state
+	}
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610102",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	byDefault := reserve("")
+	require.NotNil(t, byDefault.ExpiresAt, "Reservation without ttl must expire after RESERVATION_TTL")
+	require.WithinDuration(t, byDefault.CreatedAt.Add(time.Hour), *byDefault.ExpiresAt, time.Minute)
+
+	unlimited := reserve(`, "ttl": 0`)
+	require.Nil(t, unlimited.ExpiresAt, "Reservation with zero ttl must not expire")
+
+	short := reserve(`, "ttl": 1`)
+	require.NotNil(t, short.ExpiresAt)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	// срок истек, но фоновая задача еще не отменила резерв
+	rr := do(http.MethodPost, path.Join(h.BasePathReservation, h.Confirm), `{"reservation_id": "`+short.ReservationID+`"}`)
+	require.Equal(t, http.StatusConflict, rr.Code, "Expired reservation must not be confirmed")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathReservation, h.Cancel), `{"reservation_id": "`+short.ReservationID+`"}`)
+	require.Equal(t, http.StatusConflict, rr.Code, "Expired reservation must not be cancelled")
+
+	err = s.ExpireReservations(context.Background())
+	require.NoError(t, err, "Failed to expire reservations")
+
+	for id, status := range map[string]model.ReservationStatus{
+		short.ReservationID:     model.Expired,
+		unlimited.ReservationID: model.ActiveReservation,
+		byDefault.ReservationID: model.ActiveReservation,
+	} {
+		state, err := r.GetReservation(context.Background(), id)
+		require.NoError(t, err, "Failed to get reservation")
+		require.Equal(t, status, state.Status)
+	}
+
+	s.SetReservationTTL(0)
+	withoutTTL := reserve("")
+	require.Nil(t, withoutTTL.ExpiresAt, "Reservation without ttl and RESERVATION_TTL must not expire")
+}
+
+func TestPartialConfirmReservation(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	rr := httptest.NewRecorder()
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	reservationHandler := h.NewReservationHandler(s, logger)
+	reservationHandler.Register(router)
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610080",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	_, err = r.ReserveMoney(context.Background(), model.Reservation{
+		UserID:    "7a13445c-d6df-4111-abc0-abb12f610080",
+		ServiceID: "34e16535-480c-43f8-95a9-b7a503499af1",
+		OrderID:   "34e16535-480c-43f8-95a9-b7a503499a80",
+		Cost:      money.MustParse("40"),
+	})
+	require.NoError(t, err, "Failed to reserve")
+
+	var data = []byte(`
+	{
+		"cost": 40,
+		"capture": 25,
+  		"order_id": "34e16535-480c-43f8-95a9-b7a503499a80",
+  		"service_id": "34e16535-480c-43f8-95a9-b7a503499af1",
+  		"user_id": "7a13445c-d6df-4111-abc0-abb12f610080"
+	}`)
+
+	req, err := http.NewRequest(http.MethodPost, path.Join(h.BasePathReservation, h.Confirm), bytes.NewBuffer(data))
+	require.NoError(t, err, "Failed to create request")
+
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusNoContent, rr.Code, "Wrong status")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610080")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("75"), balance, "Uncaptured part must be returned")
+
+	history, err := r.GetUserBalanceHistory(context.Background(), dto.BalanceHistory{
+		UserID:     "7a13445c-d6df-4111-abc0-abb12f610080",
+		OrderBy:    "desc",
+		OrderField: "create_date",
+	})
+	require.NoError(t, err, "Failed to get history")
+
+	amounts := make(map[string]money.Amount)
+	for _, row := range history {
+		amounts[row.TransactionType] = row.Amount
+	}
+	require.Equal(t, money.MustParse("-25"), amounts["confirm"], "History must show captured part")
+	require.Equal(t, money.MustParse("15"), amounts["release"], "History must show released part")
+}
+
+func TestAdjustReservation(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	reservationHandler := h.NewReservationHandler(s, logger)
+	reservationHandler.Register(router)
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610081",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	reserved, err := r.ReserveMoney(context.Background(), model.Reservation{
+		UserID:    "7a13445c-d6df-4111-abc0-abb12f610081",
+		ServiceID: "34e16535-480c-43f8-95a9-b7a503499af1",
+		OrderID:   "34e16535-480c-43f8-95a9-b7a503499a81",
+		Cost:      money.MustParse("30"),
+	})
+	require.NoError(t, err, "Failed to reserve")
+
+	adjust := func(cost string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, path.Join(h.BasePathReservation, h.Adjust), bytes.NewBufferString(`
+		{
+			"cost": `+cost+`,
+			"order_id": "34e16535-480c-43f8-95a9-b7a503499a81",
+			"service_id": "34e16535-480c-43f8-95a9-b7a503499af1"
+		}`))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := adjust("50")
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to increase reservation")
+
+	var adjustment model.ReservationAdjustment
+	err = json.NewDecoder(rr.Body).Decode(&adjustment)
+	require.NoError(t, err, "Failed to decode response")
+	require.Equal(t, money.MustParse("30"), adjustment.OldCost)
+	require.Equal(t, money.MustParse("50"), adjustment.NewCost)
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610081")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("50"), balance, "Only the difference must be debited")
+
+	rr = adjust("120")
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Increase above balance must fail")
+
+	rr = adjust("20")
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to decrease reservation")
+
+	balance, err = r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610081")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("80"), balance, "Only the difference must be returned")
+
+	err = r.CommitReservation(context.Background(), dto.ReservationCommitRequest{
+		ReservationRef: dto.ReservationRef{ReservationID: reserved.ReservationID},
+	}, model.Cancel)
+	require.NoError(t, err, "Failed to cancel adjusted reservation")
+
+	history, err := r.GetUserBalanceHistory(context.Background(), dto.BalanceHistory{
+		UserID:     "7a13445c-d6df-4111-abc0-abb12f610081",
+		OrderBy:    "desc",
+		OrderField: "create_date",
+	})
+	require.NoError(t, err, "Failed to get history")
+
+	var adjustments []money.Amount
+	for _, row := range history {
+		if row.TransactionType == "adjust" {
+			adjustments = append(adjustments, row.Amount)
+		}
+	}
+	require.Equal(t, []money.Amount{money.MustParse("30"), money.MustParse("-20")}, adjustments, "History must show each adjustment")
+}
+
+func TestReservationByID(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	reservationHandler := h.NewReservationHandler(s, logger)
+	reservationHandler.Register(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610082",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	// два резерва одного заказа на одну услугу
+	var ids []string
+	for _, cost := range []string{"10", "15"} {
+		rr := do(http.MethodPost, path.Join(h.BasePathReservation, h.Reserve), `
+		{
+			"cost": `+cost+`,
+			"order_id": "34e16535-480c-43f8-95a9-b7a503499a82",
+			"service_id": "34e16535-480c-43f8-95a9-b7a503499af1",
+			"user_id": "7a13445c-d6df-4111-abc0-abb12f610082"
+		}`)
+		require.Equal(t, http.StatusCreated, rr.Code, "Failed to reserve")
+
+		var state model.ReservationState
+		err = json.NewDecoder(rr.Body).Decode(&state)
+		require.NoError(t, err, "Failed to decode response")
+		ids = append(ids, state.ReservationID)
+	}
+
+	rr := do(http.MethodPost, path.Join(h.BasePathReservation, h.Confirm), `
+	{
+		"order_id": "34e16535-480c-43f8-95a9-b7a503499a82",
+		"service_id": "34e16535-480c-43f8-95a9-b7a503499af1"
+	}`)
+	require.Equal(t, http.StatusConflict, rr.Code, "Ambiguous reservation must not be confirmed")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathReservation, h.Confirm), `{"reservation_id": "`+ids[0]+`"}`)
+	require.Equal(t, http.StatusNoContent, rr.Code, "Failed to confirm by reservation_id")
+
+	// второй резерв теперь единственный действующий
+	rr = do(http.MethodPost, path.Join(h.BasePathReservation, h.Cancel), `
+	{
+		"order_id": "34e16535-480c-43f8-95a9-b7a503499a82",
+		"service_id": "34e16535-480c-43f8-95a9-b7a503499af1"
+	}`)
+	require.Equal(t, http.StatusNoContent, rr.Code, "Failed to cancel by order and service")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathReservation, h.Cancel), `{"reservation_id": "`+ids[0]+`"}`)
+	require.Equal(t, http.StatusConflict, rr.Code, "Confirmed reservation must not be cancelled")
+
+	rr = do(http.MethodGet, path.Join(h.BasePathReservation, ids[0]), "")
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to get reservation")
+
+	var state model.ReservationState
+	err = json.NewDecoder(rr.Body).Decode(&state)
+	require.NoError(t, err, "Failed to decode response")
+	require.Equal(t, model.Confirm, state.Status)
+	require.Equal(t, money.MustParse("10"), state.Captured)
+	require.NotNil(t, state.ClosedAt)
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610082")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("90"), balance, "Only confirmed reservation must be charged")
+}
+
+func TestOpenReservations(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	reservationHandler := h.NewReservationHandler(s, logger)
+	reservationHandler.Register(router)
+
+	list := func(body string) model.ReservationList {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, h.BasePathReservation, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, "Failed to list reservations")
+
+		var l model.ReservationList
+		err = json.NewDecoder(rr.Body).Decode(&l)
+		require.NoError(t, err, "Failed to decode response")
+		return l
+	}
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610083",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	for _, order := range []string{"34e16535-480c-43f8-95a9-b7a503499a83", "34e16535-480c-43f8-95a9-b7a503499a84"} {
+		_, err = r.ReserveMoney(context.Background(), model.Reservation{
+			UserID:    "7a13445c-d6df-4111-abc0-abb12f610083",
+			ServiceID: "34e16535-480c-43f8-95a9-b7a503499af1",
+			OrderID:   order,
+			Cost:      money.MustParse("20"),
+		})
+		require.NoError(t, err, "Failed to reserve")
+	}
+
+	l := list(`{"user_id": "7a13445c-d6df-4111-abc0-abb12f610083", "limit": 1}`)
+	require.Equal(t, int64(2), l.Total, "Total must count all matching reservations")
+	require.Equal(t, money.MustParse("40"), l.Held)
+	require.Len(t, l.Reservations, 1, "Limit must be applied")
+	require.Equal(t, "34e16535-480c-43f8-95a9-b7a503499a83", l.Reservations[0].OrderID, "Oldest reservation must be first")
+	require.Equal(t, money.MustParse("40"), l.Reservations[0].UserHeld)
+
+	l = list(`{"order_id": "34e16535-480c-43f8-95a9-b7a503499a84", "service_id": "34e16535-480c-43f8-95a9-b7a503499af1"}`)
+	require.Equal(t, int64(1), l.Total)
+	require.Equal(t, money.MustParse("40"), l.Reservations[0].UserHeld, "User total must not depend on filter")
+
+	l = list(`{"user_id": "7a13445c-d6df-4111-abc0-abb12f610083", "older_than": 3600}`)
+	require.Equal(t, int64(0), l.Total, "Fresh reservations must be filtered out by age")
+	require.Empty(t, l.Reservations)
+}
+
+func TestRefundReservation(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	reservationHandler := h.NewReservationHandler(s, logger)
+	reservationHandler.Register(router)
+
+	refund := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, path.Join(h.BasePathReservation, h.Refund), bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	revenue := func() money.Amount {
+		year, month, _ := time.Now().UTC().Date()
+		report, err := r.GetReport(context.Background(), year, int(month))
+		require.NoError(t, err, "Failed to get report")
+		for _, row := range report {
+			if row.ServiceName == "Бронирование" {
+				return row.Cost
+			}
+		}
+		return 0
+	}
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610084",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	reserved, err := r.ReserveMoney(context.Background(), model.Reservation{
+		UserID:    "7a13445c-d6df-4111-abc0-abb12f610084",
+		ServiceID: "34e16535-480c-43f8-95a9-b7a503499af1",
+		OrderID:   "34e16535-480c-43f8-95a9-b7a503499a85",
+		Cost:      money.MustParse("40"),
+	})
+	require.NoError(t, err, "Failed to reserve")
+
+	rr := refund(`{"reservation_id": "` + reserved.ReservationID + `"}`)
+	require.Equal(t, http.StatusConflict, rr.Code, "Active reservation must not be refunded")
+
+	err = r.CommitReservation(context.Background(), dto.ReservationCommitRequest{
+		ReservationRef: dto.ReservationRef{ReservationID: reserved.ReservationID},
+		Capture:        money.MustParse("30"),
+	}, model.Confirm)
+	require.NoError(t, err, "Failed to confirm")
+
+	before := revenue()
+
+	rr = refund(`
+	{
+		"order_id": "34e16535-480c-43f8-95a9-b7a503499a85",
+		"service_id": "34e16535-480c-43f8-95a9-b7a503499af1",
+		"amount": 10
+	}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to refund part")
+
+	var state model.ReservationState
+	err = json.NewDecoder(rr.Body).Decode(&state)
+	require.NoError(t, err, "Failed to decode response")
+	require.Equal(t, money.MustParse("10"), state.Refunded)
+
+	rr = refund(`{"reservation_id": "` + reserved.ReservationID + `", "amount": 25}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Refund must not exceed captured amount")
+
+	rr = refund(`{"reservation_id": "` + reserved.ReservationID + `"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to refund the rest")
+
+	rr = refund(`{"reservation_id": "` + reserved.ReservationID + `"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Fully refunded reservation must not be refunded again")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610084")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("100"), balance, "Captured amount must be refunded")
+
+	require.Equal(t, before-money.MustParse("30"), revenue(), "Refunds must be netted out of the report")
+
+	history, err := r.GetUserBalanceHistory(context.Background(), dto.BalanceHistory{
+		UserID:     "7a13445c-d6df-4111-abc0-abb12f610084",
+		OrderBy:    "desc",
+		OrderField: "create_date",
+	})
+	require.NoError(t, err, "Failed to get history")
+	require.Equal(t, "refund", history[0].TransactionType, "History must show refund")
+}
+
+func TestOrderReservation(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	reservationHandler := h.NewReservationHandler(s, logger)
+	reservationHandler.Register(router)
+
+	do := func(url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	balance := func() money.Amount {
+		b, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610085")
+		require.NoError(t, err, "Failed to get balance")
+		return b
+	}
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610085",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	rr := do(path.Join(h.BasePathReservation, h.Order, h.Reserve), `
+	{
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610085",
+		"order_id": "34e16535-480c-43f8-95a9-b7a503499a86",
+		"lines": [
+			{"service_id": "34e16535-480c-43f8-95a9-b7a503499af0", "cost": 30},
+			{"service_id": "34e16535-480c-43f8-95a9-b7a503499af1", "cost": 50},
+			{"service_id": "34e16535-480c-43f8-95a9-b7a503499af2", "cost": 40}
+		]
+	}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code, "Order above balance must fail")
+	require.Equal(t, money.MustParse("100"), balance(), "Failed order must not leave partial holds")
+
+	rr = do(path.Join(h.BasePathReservation, h.Order, h.Reserve), `
+	{
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610085",
+		"order_id": "34e16535-480c-43f8-95a9-b7a503499a86",
+		"lines": [
+			{"service_id": "34e16535-480c-43f8-95a9-b7a503499af0", "cost": 30},
+			{"service_id": "34e16535-480c-43f8-95a9-b7a503499af1", "cost": 50}
+		]
+	}`)
+	require.Equal(t, http.StatusCreated, rr.Code, "Failed to reserve order")
+
+	var order model.OrderReservation
+	err = json.NewDecoder(rr.Body).Decode(&order)
+	require.NoError(t, err, "Failed to decode response")
+	require.Equal(t, money.MustParse("80"), order.Total)
+	require.Len(t, order.Lines, 2)
+	require.Equal(t, money.MustParse("20"), balance())
+
+	// отмена одной услуги заказа
+	rr = do(path.Join(h.BasePathReservation, h.Cancel), `
+	{
+		"order_id": "34e16535-480c-43f8-95a9-b7a503499a86",
+		"service_id": "34e16535-480c-43f8-95a9-b7a503499af0"
+	}`)
+	require.Equal(t, http.StatusNoContent, rr.Code, "Failed to cancel order line")
+	require.Equal(t, money.MustParse("50"), balance())
+
+	rr = do(path.Join(h.BasePathReservation, h.Order, h.Confirm), `{"order_id": "34e16535-480c-43f8-95a9-b7a503499a86"}`)
+	require.Equal(t, http.StatusNoContent, rr.Code, "Failed to confirm order")
+	require.Equal(t, money.MustParse("50"), balance())
+
+	state, err := r.GetReservation(context.Background(), order.Lines[1].ReservationID)
+	require.NoError(t, err, "Failed to get reservation")
+	require.Equal(t, model.Confirm, state.Status, "Remaining line must be confirmed")
+
+	rr = do(path.Join(h.BasePathReservation, h.Order, h.Cancel), `{"order_id": "34e16535-480c-43f8-95a9-b7a503499a86"}`)
+	require.Equal(t, http.StatusNotFound, rr.Code, "Closed order must not be cancelled")
+}
+
+func TestReservationFee(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	h.NewReservationHandler(s, logger).Register(router)
+
+	created, err := r.CreateService(context.Background(), dto.ServiceCreateRequest{Name: "Упаковка заказа"})
+	require.NoError(t, err, "Failed to create service")
+
+	_, err = r.CreateFeeRule(context.Background(), model.FeeRule{
+		Operation: model.ReserveOperation,
+		ServiceID: created.ServiceID,
+		Flat:      money.MustParse("2"),
+	})
+	require.NoError(t, err, "Failed to create fee rule")
+
+	_, err = r.CreateFeeRule(context.Background(), model.FeeRule{
+		Operation: model.ConfirmOperation,
+		ServiceID: created.ServiceID,
+		Percent:   10,
+		MaxFee:    money.MustParse("3"),
+	})
+	require.NoError(t, err, "Failed to create fee rule")
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610090",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	reserved, err := r.ReserveMoney(context.Background(), model.Reservation{
+		UserID:    "7a13445c-d6df-4111-abc0-abb12f610090",
+		ServiceID: created.ServiceID,
+		OrderID:   "34e16535-480c-43f8-95a9-b7a503499a90",
+		Cost:      money.MustParse("50"),
+	})
+	require.NoError(t, err, "Failed to reserve")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610090")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("48"), balance, "Reserve fee must be charged")
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, path.Join(h.BasePathReservation, h.Confirm),
+		bytes.NewBufferString(`{"reservation_id": "`+reserved.ReservationID+`", "capture": 40}`))
+	require.NoError(t, err, "Failed to create request")
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code, "Failed to confirm reservation")
+
+	// 10 released, комиссия 10% от 40 ограничена 3
+	balance, err = r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610090")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("55"), balance, "Confirm fee must be charged from captured amount")
+
+	year, month, _ := time.Now().UTC().Date()
+	report, err := r.GetReport(context.Background(), year, int(month))
+	require.NoError(t, err, "Failed to get report")
+	require.Contains(t, report, model.ReportRow{
+		ServiceName: "Упаковка заказа",
+		Cost:        money.MustParse("40"),
+		Fee:         money.MustParse("5"),
+	}, "Report must show revenue and fees separately")
+}
+
+func TestConfirmFrozenReservationFee(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	h.NewReservationHandler(s, logger).Register(router)
+
+	const userID = "7a13445c-d6df-4111-abc0-abb12f610113"
+
+	created, err := r.CreateService(context.Background(), dto.ServiceCreateRequest{Name: "Хранение заказа"})
+	require.NoError(t, err, "Failed to create service")
+
+	_, err = r.CreateFeeRule(context.Background(), model.FeeRule{
+		Operation: model.ConfirmOperation,
+		ServiceID: created.ServiceID,
+		Flat:      money.MustParse("2"),
+	})
+	require.NoError(t, err, "Failed to create fee rule")
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: userID,
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	reserved, err := r.ReserveMoney(context.Background(), model.Reservation{
+		UserID:    userID,
+		ServiceID: created.ServiceID,
+		OrderID:   "34e16535-480c-43f8-95a9-b7a503499a95",
+		Cost:      money.MustParse("50"),
+	})
+	require.NoError(t, err, "Failed to reserve")
+
+	err = r.ChangeStatus(context.Background(), dto.AccountStatusRequest{UserID: userID}, model.FrozenStatus)
+	require.NoError(t, err, "Failed to freeze account")
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, path.Join(h.BasePathReservation, h.Confirm),
+		bytes.NewBufferString(`{"reservation_id": "`+reserved.ReservationID+`"}`))
+	require.NoError(t, err, "Failed to create request")
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code, "Reservation made before freeze must be confirmed")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), userID)
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("48"), balance, "Confirm fee must be charged from frozen account")
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("10"),
+		UserID: userID,
+	}, model.Reduce)
+	require.Error(t, err, "Frozen account must still reject reduce")
 }
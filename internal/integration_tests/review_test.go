@@ -0,0 +1,308 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/garet2gis/user_balance_service/internal/csv"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	h "github.com/garet2gis/user_balance_service/internal/handler"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
+	"github.com/garet2gis/user_balance_service/internal/repository"
+	"github.com/garet2gis/user_balance_service/internal/service"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestReviewQueue(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	s.SetRiskConfig(model.RiskConfig{
+		Window:            10 * time.Minute,
+		ReviewScore:       100,
+		NewRecipientScore: 30,
+		ReplenishOutScore: 40,
+		VelocityScore:     10,
+	})
+	h.NewBalanceHandler(s, logger).Register(router)
+	h.NewReviewHandler(s, logger).Register(router)
+
+	do := func(method, url, body, key string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		if key != "" {
+			req.Header.Set(idempotency.Header, key)
+		}
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	transfer := func(userIDTo, key string) *httptest.ResponseRecorder {
+		return do(http.MethodPost, path.Join(h.BasePathBalance, h.Transfer), `
+		{
+			"amount": 100,
+			"user_id_from": "7a13445c-d6df-4111-abc0-abb12f610094",
+			"user_id_to": "`+userIDTo+`"
+		}`, key)
+	}
+
+	decodeReview := func(rr *httptest.ResponseRecorder) model.Review {
+		var review model.Review
+		err := json.NewDecoder(rr.Body).Decode(&review)
+		require.NoError(t, err, "Failed to decode review")
+		return review
+	}
+
+	for userID, amount := range map[string]string{
+		"7a13445c-d6df-4111-abc0-abb12f610094": "1000",
+		"7a13445c-d6df-4111-abc0-abb12f610095": "10",
+		"7a13445c-d6df-4111-abc0-abb12f610096": "10",
+		"7a13445c-d6df-4111-abc0-abb12f610097": "10",
+	} {
+		_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+			Amount: money.MustParse(amount),
+			UserID: userID,
+		}, model.Replenish)
+		require.NoError(t, err, "Failed to replenish")
+	}
+
+	// новый получатель, вывод после пополнения и одна исходящая операция: 30 + 40 + 10
+	rr := transfer("7a13445c-d6df-4111-abc0-abb12f610095", "")
+	require.Equal(t, http.StatusNoContent, rr.Code, "Low risk transfer must be executed")
+
+	// два новых получателя и две исходящие операции: 60 + 40 + 20
+	rr = transfer("7a13445c-d6df-4111-abc0-abb12f610096", "")
+	require.Equal(t, http.StatusAccepted, rr.Code, "High risk transfer must be sent to review")
+	rejected := decodeReview(rr)
+	require.Equal(t, model.PendingReview, rejected.Status)
+	require.Equal(t, 120, rejected.Score)
+
+	rr = do(http.MethodPost, path.Join(h.BasePathReview, h.RejectReview), `{"review_id": "`+rejected.ReviewID+`"}`, "")
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to reject review")
+	require.Equal(t, model.RejectedReview, decodeReview(rr).Status)
+
+	const key = "c0ffee00-review-610094"
+	rr = transfer("7a13445c-d6df-4111-abc0-abb12f610097", key)
+	require.Equal(t, http.StatusAccepted, rr.Code, "High risk transfer must be sent to review")
+	pending := decodeReview(rr)
+
+	rr = transfer("7a13445c-d6df-4111-abc0-abb12f610097", key)
+	require.Equal(t, http.StatusAccepted, rr.Code, "Retry must return the same review")
+	require.Equal(t, pending.ReviewID, decodeReview(rr).ReviewID)
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610094")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("900"), balance, "Operations on review must not change balance")
+
+	rr = do(http.MethodGet, h.BasePathReview, `{"status": "pending", "user_id": "7a13445c-d6df-4111-abc0-abb12f610094"}`, "")
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to get reviews")
+	var reviews []model.Review
+	err = json.NewDecoder(rr.Body).Decode(&reviews)
+	require.NoError(t, err, "Failed to decode reviews")
+	require.Len(t, reviews, 1)
+	require.Equal(t, pending.ReviewID, reviews[0].ReviewID)
+
+	rr = do(http.MethodPost, path.Join(h.BasePathReview, h.ApproveReview), `{"review_id": "`+pending.ReviewID+`"}`, "")
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to approve review")
+	require.Equal(t, model.ApprovedReview, decodeReview(rr).Status)
+
+	rr = do(http.MethodPost, path.Join(h.BasePathReview, h.ApproveReview), `{"review_id": "`+pending.ReviewID+`"}`, "")
+	require.Equal(t, http.StatusConflict, rr.Code, "Review must be approved only once")
+
+	// повтор одобренного запроса возвращает результат без повторного перевода
+	rr = transfer("7a13445c-d6df-4111-abc0-abb12f610097", key)
+	require.Equal(t, http.StatusNoContent, rr.Code, "Approved transfer must be replayed")
+
+	balance, err = r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610094")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("800"), balance, "Approved transfer must be executed once")
+
+	balance, err = r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610097")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("110"), balance)
+}
+
+func TestReviewScreening(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	// на проверку отправляются только операции нового получателя после пополнения: 60 + 40
+	s.SetRiskConfig(model.RiskConfig{
+		Window:            10 * time.Minute,
+		ReviewScore:       100,
+		NewRecipientScore: 60,
+		ReplenishOutScore: 40,
+	})
+	h.NewBalanceHandler(s, logger).Register(router)
+	h.NewReviewHandler(s, logger).Register(router)
+	h.NewScheduleHandler(s, logger).Register(router)
+
+	do := func(method, url, body, key string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		if key != "" {
+			req.Header.Set(idempotency.Header, key)
+		}
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	approve := func(reviewID string) {
+		rr := do(http.MethodPost, path.Join(h.BasePathReview, h.ApproveReview), `{"review_id": "`+reviewID+`"}`, "")
+		require.Equal(t, http.StatusOK, rr.Code, "Failed to approve review")
+		var review model.Review
+		err := json.NewDecoder(rr.Body).Decode(&review)
+		require.NoError(t, err, "Failed to decode review")
+		require.Equal(t, model.ApprovedReview, review.Status, review.Error)
+	}
+
+	pendingReviews := func(userID string) []model.Review {
+		rr := do(http.MethodGet, h.BasePathReview, `{"status": "pending", "user_id": "`+userID+`"}`, "")
+		require.Equal(t, http.StatusOK, rr.Code, "Failed to get reviews")
+		var reviews []model.Review
+		err := json.NewDecoder(rr.Body).Decode(&reviews)
+		require.NoError(t, err, "Failed to decode reviews")
+		return reviews
+	}
+
+	requireBalance := func(userID, expected, msg string) {
+		balance, err := r.GetBalanceByUserID(context.Background(), userID)
+		require.NoError(t, err, "Failed to get balance")
+		require.Equal(t, money.MustParse(expected), balance, msg)
+	}
+
+	for _, userID := range []string{
+		"7a13445c-d6df-4111-abc0-abb12f610105",
+		"7a13445c-d6df-4111-abc0-abb12f610108",
+		"7a13445c-d6df-4111-abc0-abb12f610110",
+	} {
+		_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+			Amount: money.MustParse("1000"),
+			UserID: userID,
+		}, model.Replenish)
+		require.NoError(t, err, "Failed to replenish")
+	}
+
+	t.Run("split transfer", func(t *testing.T) {
+		rr := do(http.MethodPost, path.Join(h.BasePathBalance, h.SplitTransfer), `
+		{
+			"user_id_from": "7a13445c-d6df-4111-abc0-abb12f610105",
+			"recipients": [
+				{"user_id_to": "7a13445c-d6df-4111-abc0-abb12f610106", "amount": 100},
+				{"user_id_to": "7a13445c-d6df-4111-abc0-abb12f610107", "amount": 50}
+			]
+		}`, "")
+		require.Equal(t, http.StatusAccepted, rr.Code, "High risk split transfer must be sent to review")
+
+		var review model.Review
+		err := json.NewDecoder(rr.Body).Decode(&review)
+		require.NoError(t, err, "Failed to decode review")
+		require.Equal(t, model.SplitTransferOperation, review.Operation)
+		require.Equal(t, money.MustParse("150"), review.Amount)
+		// два новых получателя и пополнение: 120 + 40
+		require.Equal(t, 160, review.Score)
+		requireBalance("7a13445c-d6df-4111-abc0-abb12f610105", "1000", "Split transfer on review must not change balance")
+
+		approve(review.ReviewID)
+		requireBalance("7a13445c-d6df-4111-abc0-abb12f610105", "850", "Approved split transfer must be executed")
+		requireBalance("7a13445c-d6df-4111-abc0-abb12f610106", "100", "Approved split transfer must be executed")
+		requireBalance("7a13445c-d6df-4111-abc0-abb12f610107", "50", "Approved split transfer must be executed")
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		items := `[
+			{"type": "reduce", "amount": 10, "user_id": "7a13445c-d6df-4111-abc0-abb12f610108"},
+			{"type": "transfer", "amount": 100, "user_id": "7a13445c-d6df-4111-abc0-abb12f610108", "user_id_to": "7a13445c-d6df-4111-abc0-abb12f610109"},
+			{"type": "reduce", "amount": 5000, "user_id": "7a13445c-d6df-4111-abc0-abb12f610108"}
+		]`
+
+		// ошибка операции отменяет пакет вместе с заявкой на проверку
+		rr := do(http.MethodPost, path.Join(h.BasePathBalance, h.Batch), `{"mode": "atomic", "items": `+items+`}`, "")
+		require.Equal(t, http.StatusOK, rr.Code, "Failed to execute batch")
+		var res model.BatchResult
+		err := json.NewDecoder(rr.Body).Decode(&res)
+		require.NoError(t, err, "Failed to decode batch result")
+		require.False(t, res.Committed)
+		require.Equal(t, model.RolledBackItem, res.Items[1].Status)
+		require.Empty(t, pendingReviews("7a13445c-d6df-4111-abc0-abb12f610108"), "Rolled back batch must not leave reviews")
+
+		rr = do(http.MethodPost, path.Join(h.BasePathBalance, h.Batch), `{"mode": "best_effort", "items": `+items+`}`, "review-batch-610108")
+		require.Equal(t, http.StatusOK, rr.Code, "Failed to execute batch")
+		err = json.NewDecoder(rr.Body).Decode(&res)
+		require.NoError(t, err, "Failed to decode batch result")
+		require.True(t, res.Committed)
+		require.Equal(t, model.AppliedItem, res.Items[0].Status, "Low risk reduce must be applied")
+		require.Equal(t, model.PendingReviewItem, res.Items[1].Status, "High risk transfer must be sent to review")
+		require.NotEmpty(t, res.Items[1].ReviewID)
+		require.Equal(t, model.FailedItem, res.Items[2].Status)
+		requireBalance("7a13445c-d6df-4111-abc0-abb12f610108", "990", "Transfer on review must not change balance")
+
+		reviews := pendingReviews("7a13445c-d6df-4111-abc0-abb12f610108")
+		require.Len(t, reviews, 1)
+		require.Equal(t, res.Items[1].ReviewID, reviews[0].ReviewID)
+		require.Equal(t, "7a13445c-d6df-4111-abc0-abb12f610109", reviews[0].UserIDTo)
+
+		approve(res.Items[1].ReviewID)
+		requireBalance("7a13445c-d6df-4111-abc0-abb12f610108", "890", "Approved batch transfer must be executed")
+		requireBalance("7a13445c-d6df-4111-abc0-abb12f610109", "100", "Approved batch transfer must be executed")
+	})
+
+	t.Run("schedule", func(t *testing.T) {
+		rr := do(http.MethodPost, h.BasePathSchedule, `
+		{
+			"operation": "transfer",
+			"amount": 200,
+			"user_id": "7a13445c-d6df-4111-abc0-abb12f610110",
+			"user_id_to": "7a13445c-d6df-4111-abc0-abb12f610111",
+			"start_at": "`+time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)+`"
+		}`, "")
+		require.Equal(t, http.StatusCreated, rr.Code, "Failed to create schedule")
+		var schedule model.Schedule
+		err := json.NewDecoder(rr.Body).Decode(&schedule)
+		require.NoError(t, err, "Failed to decode schedule")
+
+		err = s.RunDueSchedules(context.Background(), 100, time.Minute, time.Minute)
+		require.NoError(t, err, "Failed to run schedules")
+		requireBalance("7a13445c-d6df-4111-abc0-abb12f610110", "1000", "Scheduled transfer on review must not change balance")
+
+		runs, err := s.GetScheduleRuns(context.Background(), schedule.ScheduleID)
+		require.NoError(t, err, "Failed to get schedule runs")
+		require.Len(t, runs, 1)
+		require.Equal(t, model.PendingReviewRun, runs[0].Status)
+		require.NotEmpty(t, runs[0].ReviewID)
+
+		reviews := pendingReviews("7a13445c-d6df-4111-abc0-abb12f610110")
+		require.Len(t, reviews, 1)
+		require.Equal(t, runs[0].ReviewID, reviews[0].ReviewID)
+
+		approve(runs[0].ReviewID)
+		requireBalance("7a13445c-d6df-4111-abc0-abb12f610110", "800", "Approved scheduled transfer must be executed")
+		requireBalance("7a13445c-d6df-4111-abc0-abb12f610111", "200", "Approved scheduled transfer must be executed")
+	})
+}
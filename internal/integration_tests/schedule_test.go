@@ -0,0 +1,132 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/garet2gis/user_balance_service/internal/csv"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	h "github.com/garet2gis/user_balance_service/internal/handler"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
+	"github.com/garet2gis/user_balance_service/internal/repository"
+	"github.com/garet2gis/user_balance_service/internal/service"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	logger := logging.GetLogger()
+	client, err := initTestDB()
+	require.NoError(t, err, "Failed to connect to db")
+	defer client.Close()
+
+	r := repository.NewRepository(client, logger)
+	router := httprouter.New()
+	c := csv.NewBuilder(logger)
+	p := policy.NewEngine(false, logger)
+	s := service.NewService(r, c, p, logger)
+	h.NewScheduleHandler(s, logger).Register(router)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+		require.NoError(t, err, "Failed to create request")
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	_, err = r.ChangeUserBalance(context.Background(), dto.BalanceChangeRequest{
+		Amount: money.MustParse("100"),
+		UserID: "7a13445c-d6df-4111-abc0-abb12f610078",
+	}, model.Replenish)
+	require.NoError(t, err, "Failed to replenish")
+
+	start := time.Now().Add(-time.Minute).UTC()
+	startAt := start.Format(time.RFC3339)
+
+	rr := do(http.MethodPost, h.BasePathSchedule, `
+	{
+		"operation": "reduce",
+		"amount": 30,
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610078",
+		"start_at": "`+startAt+`"
+	}`)
+	require.Equal(t, http.StatusCreated, rr.Code, "Failed to create schedule")
+
+	var reduce model.Schedule
+	err = json.NewDecoder(rr.Body).Decode(&reduce)
+	require.NoError(t, err, "Failed to decode response")
+
+	rr = do(http.MethodPost, h.BasePathSchedule, `
+	{
+		"operation": "reduce",
+		"amount": 1000,
+		"user_id": "7a13445c-d6df-4111-abc0-abb12f610078",
+		"start_at": "`+startAt+`",
+		"recurrence": "monthly",
+		"day_of_month": `+strconv.Itoa(start.Day())+`,
+		"max_retries": 1
+	}`)
+	require.Equal(t, http.StatusCreated, rr.Code, "Failed to create schedule")
+
+	var monthly model.Schedule
+	err = json.NewDecoder(rr.Body).Decode(&monthly)
+	require.NoError(t, err, "Failed to decode response")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathSchedule, h.PauseSchedule), `{"schedule_id": "`+monthly.ScheduleID+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to pause schedule")
+
+	err = s.RunDueSchedules(context.Background(), 100, time.Minute, time.Minute)
+	require.NoError(t, err, "Failed to run schedules")
+
+	balance, err := r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610078")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("70"), balance, "Scheduled reduce must be executed once")
+
+	// повторный запуск не должен выполнить разовое расписание еще раз
+	err = s.RunDueSchedules(context.Background(), 100, time.Minute, time.Minute)
+	require.NoError(t, err, "Failed to run schedules")
+
+	balance, err = r.GetBalanceByUserID(context.Background(), "7a13445c-d6df-4111-abc0-abb12f610078")
+	require.NoError(t, err, "Failed to get balance")
+	require.Equal(t, money.MustParse("70"), balance, "Scheduled reduce must not be executed twice")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathSchedule, h.ResumeSchedule), `{"schedule_id": "`+monthly.ScheduleID+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to resume schedule")
+
+	err = s.RunDueSchedules(context.Background(), 100, time.Minute, time.Minute)
+	require.NoError(t, err, "Failed to run schedules")
+
+	rr = do(http.MethodGet, path.Join(h.BasePathSchedule, h.ScheduleRuns), `{"schedule_id": "`+monthly.ScheduleID+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to get schedule runs")
+
+	var runs []model.ScheduleRun
+	err = json.NewDecoder(rr.Body).Decode(&runs)
+	require.NoError(t, err, "Failed to decode response")
+	require.Len(t, runs, 1, "Failed run must be recorded")
+	require.Equal(t, model.FailedRun, runs[0].Status)
+
+	rr = do(http.MethodGet, h.BasePathSchedule, `{"user_id": "7a13445c-d6df-4111-abc0-abb12f610078", "status": "completed"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to get schedules")
+
+	var completed []model.Schedule
+	err = json.NewDecoder(rr.Body).Decode(&completed)
+	require.NoError(t, err, "Failed to decode response")
+	require.Len(t, completed, 1, "One-time schedule must be completed")
+	require.Equal(t, reduce.ScheduleID, completed[0].ScheduleID)
+
+	rr = do(http.MethodPost, path.Join(h.BasePathSchedule, h.CancelSchedule), `{"schedule_id": "`+monthly.ScheduleID+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code, "Failed to cancel schedule")
+
+	rr = do(http.MethodPost, path.Join(h.BasePathSchedule, h.ResumeSchedule), `{"schedule_id": "`+monthly.ScheduleID+`"}`)
+	require.Equal(t, http.StatusConflict, rr.Code, "Cancelled schedule must not be resumed")
+}
@@ -0,0 +1,22 @@
+package model
+
+import "github.com/garet2gis/user_balance_service/pkg/money"
+
+type AccountStatus string
+
+const (
+	// ActiveStatus все операции разрешены
+	ActiveStatus AccountStatus = "active"
+	// FrozenStatus разрешены только поступления денег и расчеты по резервам, созданным до заморозки
+	FrozenStatus AccountStatus = "frozen"
+	// ClosedStatus операции запрещены, статус окончательный
+	ClosedStatus AccountStatus = "closed"
+)
+
+// AccountState настройки счета пользователя, не связанные с движением денег
+type AccountState struct {
+	Status      AccountStatus
+	CreditLimit money.Amount
+	// Версия строки баланса, растет при каждом изменении
+	Version int64
+}
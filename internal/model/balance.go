@@ -1,5 +1,11 @@
 package model
 
+import (
+	"fmt"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"time"
+)
+
 type DepositType string
 
 const (
@@ -9,7 +15,40 @@ const (
 
 type Balance struct {
 	// Баланс пользователя
-	Balance float64 `json:"balance" validate:"required,gte=0"`
+	Balance money.Amount `json:"balance" swaggertype:"number" example:"120.50" validate:"required"`
+	// Зарезервированные деньги пользователя
+	Reserved money.Amount `json:"reserved" swaggertype:"number" example:"50"`
+	// Кредитный лимит: насколько баланс может уйти в минус
+	CreditLimit money.Amount `json:"credit_limit" swaggertype:"number" example:"1000"`
+	// Неиспользованная часть кредитного лимита
+	AvailableCredit money.Amount `json:"available_credit" swaggertype:"number" example:"1000"`
+	// Статус счета: active, frozen или closed
+	Status AccountStatus `json:"status" example:"active"`
 	// UUID баланса пользователя
 	UserID string `json:"user_id" validate:"required"`
+	// Версия баланса, также возвращается в заголовке ETag
+	Version int64 `json:"version" example:"3"`
+	// Момент времени, на который посчитан баланс
+	AsOf *time.Time `json:"as_of,omitempty"`
 } // @name Balance
+
+// VersionMismatch операция не выполнена: версия баланса не совпала с заголовком If-Match
+type VersionMismatch struct {
+	// UUID баланса пользователя
+	UserID string `json:"user_id"`
+	// Текущая версия баланса, 0 - баланса нет
+	Version int64 `json:"version" example:"4"`
+	// Текущий баланс пользователя
+	Balance money.Amount `json:"balance" swaggertype:"number" example:"120.50"`
+} // @name VersionMismatch
+
+func (e *VersionMismatch) Error() string {
+	return fmt.Sprintf("balance of user %s has version %d", e.UserID, e.Version)
+}
+
+type SplitTransfer struct {
+	// UUID группы, по которому разделенный перевод связан в истории отправителя и получателей
+	GroupID string `json:"group_id" example:"0f8b4c53-7d3e-4d2a-9a4e-6a8f0c1d2e3f"`
+	// Баланс отправителя после перевода
+	Balance money.Amount `json:"balance" swaggertype:"number" example:"120.50"`
+} // @name SplitTransfer
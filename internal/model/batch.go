@@ -0,0 +1,49 @@
+package model
+
+import (
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+)
+
+type BatchMode string
+
+const (
+	// AtomicBatch ошибка любой операции отменяет весь пакет
+	AtomicBatch BatchMode = "atomic"
+	// BestEffortBatch ошибочные операции пропускаются, остальные применяются
+	BestEffortBatch BatchMode = "best_effort"
+)
+
+type BatchItemStatus string
+
+const (
+	AppliedItem    BatchItemStatus = "applied"
+	FailedItem     BatchItemStatus = "failed"
+	RolledBackItem BatchItemStatus = "rolled_back"
+	SkippedItem    BatchItemStatus = "skipped"
+	// PendingReviewItem операция не выполнена, а отправлена на ручную проверку и выполнится при одобрении
+	PendingReviewItem BatchItemStatus = "pending_review"
+)
+
+type BatchItemResult struct {
+	// Порядковый номер операции в запросе
+	Index int `json:"index"`
+	// Результат: applied, failed, rolled_back (отменена вместе с пакетом), skipped (не выполнялась)
+	// или pending_review (отправлена на проверку)
+	Status BatchItemStatus `json:"status" example:"applied"`
+	// Баланс пользователя (отправителя для перевода) после операции
+	Balance *money.Amount `json:"balance,omitempty" swaggertype:"number" example:"120.50"`
+	// Баланс получателя после перевода
+	BalanceTo *money.Amount `json:"balance_to,omitempty" swaggertype:"number" example:"20"`
+	// Ошибка операции
+	Error *apperror.AppError `json:"error,omitempty"`
+	// UUID заявки на проверку для операции со статусом pending_review
+	ReviewID string `json:"review_id,omitempty"`
+} // @name BatchItemResult
+
+type BatchResult struct {
+	Mode BatchMode `json:"mode" example:"atomic"`
+	// Были ли изменения сохранены
+	Committed bool              `json:"committed"`
+	Items     []BatchItemResult `json:"items"`
+} // @name BatchResult
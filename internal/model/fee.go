@@ -0,0 +1,53 @@
+package model
+
+import (
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// FeeRule правило комиссии за операцию: Flat + Percent% от суммы, но не меньше MinFee и не больше MaxFee
+type FeeRule struct {
+	// UUID правила
+	FeeRuleID string `json:"fee_rule_id"`
+	// Операция: transfer, reserve или confirm
+	Operation OperationType `json:"operation" example:"transfer"`
+	// UUID сервиса, пустой для правила, действующего для всех услуг
+	ServiceID string `json:"service_id,omitempty" example:"34e16535-480c-43f8-95a9-b7a503499af0"`
+	// Фиксированная часть комиссии
+	Flat money.Amount `json:"flat" swaggertype:"number" example:"10"`
+	// Процент от суммы операции
+	Percent float64 `json:"percent" example:"1.5"`
+	// Минимальная комиссия
+	MinFee money.Amount `json:"min_fee" swaggertype:"number" example:"5"`
+	// Максимальная комиссия, 0 - без ограничения
+	MaxFee money.Amount `json:"max_fee,omitempty" swaggertype:"number" example:"500"`
+	// Отключенное правило не применяется
+	Active    bool      `json:"active" example:"true"`
+	CreatedAt time.Time `json:"created_at"`
+} // @name FeeRule
+
+// Fee рассчитывает комиссию с суммы операции amount. Процентная часть округляется до копеек
+func (f FeeRule) Fee(amount money.Amount) (money.Amount, error) {
+	fee := f.Flat
+	if f.Percent > 0 {
+		// десятичная запись процента точнее двоичного представления float64
+		percent, _ := new(big.Rat).SetString(strconv.FormatFloat(f.Percent, 'f', -1, 64))
+		share := new(big.Rat).Mul(amount.Rat(), percent)
+		part, err := money.Round(share.Quo(share, big.NewRat(100, 1)))
+		if err != nil {
+			return 0, err
+		}
+		fee += part
+	}
+
+	if fee < f.MinFee {
+		fee = f.MinFee
+	}
+	if f.MaxFee > 0 && fee > f.MaxFee {
+		fee = f.MaxFee
+	}
+
+	return fee, nil
+}
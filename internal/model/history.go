@@ -1,5 +1,7 @@
 package model
 
+import "github.com/garet2gis/user_balance_service/pkg/money"
+
 type HistoryRow struct {
 	// UUID заказа
 	OrderID string `json:"order_id,omitempty"`
@@ -12,9 +14,11 @@ type HistoryRow struct {
 	// Время создания
 	CreateAt string `json:"create_at"`
 	// Сумма
-	Amount float64 `json:"amount"`
+	Amount money.Amount `json:"amount" swaggertype:"number" example:"-20.25"`
 	// Тип транзакции
 	TransactionType string `json:"transaction_type"`
 	// Комментарий
 	Comment string `json:"comment"`
+	// UUID группы разделенного перевода, общий для отправителя и всех получателей
+	GroupID string `json:"group_id,omitempty"`
 } // @name HistoryRow
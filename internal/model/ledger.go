@@ -0,0 +1,90 @@
+package model
+
+import "github.com/garet2gis/user_balance_service/pkg/money"
+
+// SystemOwnerID владелец системных счетов, не привязанных к пользователю или услуге
+const SystemOwnerID = "00000000-0000-0000-0000-000000000000"
+
+type AccountType string
+
+const (
+	// UserAccount доступный остаток пользователя
+	UserAccount AccountType = "user"
+	// ReservedAccount деньги пользователя, зарезервированные под услуги
+	ReservedAccount AccountType = "reserved"
+	// RevenueAccount выручка услуги
+	RevenueAccount AccountType = "revenue"
+	// CashAccount внешние деньги: пополнения и выводы
+	CashAccount AccountType = "cash"
+	// FeeAccount системный счет удержанных комиссий
+	FeeAccount AccountType = "fee"
+)
+
+type OperationType string
+
+const (
+	OpeningOperation   OperationType = "opening"
+	ReplenishOperation OperationType = "replenish"
+	ReduceOperation    OperationType = "reduce"
+	TransferOperation  OperationType = "transfer"
+	// SplitTransferOperation перевод от одного отправителя нескольким получателям
+	SplitTransferOperation OperationType = "split_transfer"
+	ReserveOperation       OperationType = "reserve"
+	ConfirmOperation       OperationType = "confirm"
+	CancelOperation        OperationType = "cancel"
+	// ExpireOperation возврат денег по истечении срока резерва
+	ExpireOperation OperationType = "expired"
+	// ReleaseOperation возврат неподтвержденной части резерва при частичном подтверждении
+	ReleaseOperation OperationType = "release"
+	// AdjustOperation изменение суммы действующего резерва
+	AdjustOperation OperationType = "adjust"
+	// RefundOperation возврат денег по подтвержденному резерву
+	RefundOperation OperationType = "refund"
+	// FeeOperation списание комиссии за операцию
+	FeeOperation OperationType = "fee"
+)
+
+type Account struct {
+	Type AccountType
+	// user_id для UserAccount и ReservedAccount, service_id для RevenueAccount
+	OwnerID string
+}
+
+func UserAccountOf(userID string) Account {
+	return Account{Type: UserAccount, OwnerID: userID}
+}
+
+func ReservedAccountOf(userID string) Account {
+	return Account{Type: ReservedAccount, OwnerID: userID}
+}
+
+func RevenueAccountOf(serviceID string) Account {
+	return Account{Type: RevenueAccount, OwnerID: serviceID}
+}
+
+func CashAccountOf() Account {
+	return Account{Type: CashAccount, OwnerID: SystemOwnerID}
+}
+
+func FeeAccountOf() Account {
+	return Account{Type: FeeAccount, OwnerID: SystemOwnerID}
+}
+
+// Journal одна бизнес-операция, состоящая из сбалансированных проводок
+type Journal struct {
+	Operation OperationType
+	OrderID   string
+	ServiceID string
+	Comment   string
+}
+
+// Posting изменение остатка одного счета: Amount > 0 - поступление, Amount < 0 - списание
+type Posting struct {
+	Account Account
+	Amount  money.Amount
+	// Комментарий проводки, если он отличается от комментария журнала
+	Comment string
+	// Settlement списание в расчетах по операции, разрешенной до заморозки счета (комиссия за подтверждение резерва).
+	// Такое списание проходит и по замороженному счету
+	Settlement bool
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"time"
+)
+
+type LimitPeriod string
+
+const (
+	// SingleLimit максимальная сумма одной операции
+	SingleLimit  LimitPeriod = "single"
+	DailyLimit   LimitPeriod = "daily"
+	WeeklyLimit  LimitPeriod = "weekly"
+	MonthlyLimit LimitPeriod = "monthly"
+)
+
+// Start возвращает начало календарного периода, в который попадает now. Периоды считаются в UTC,
+// неделя начинается с понедельника. Для SingleLimit возвращает нулевое время
+func (p LimitPeriod) Start(now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch p {
+	case DailyLimit:
+		return day
+	case WeeklyLimit:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case MonthlyLimit:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Time{}
+	}
+}
+
+// End возвращает время, когда лимит периода, в который попадает now, обновится
+func (p LimitPeriod) End(now time.Time) time.Time {
+	start := p.Start(now)
+
+	switch p {
+	case DailyLimit:
+		return start.AddDate(0, 0, 1)
+	case WeeklyLimit:
+		return start.AddDate(0, 0, 7)
+	case MonthlyLimit:
+		return start.AddDate(0, 1, 0)
+	default:
+		return time.Time{}
+	}
+}
+
+// Limit лимит операции пользователя. Пустой UserID - общий лимит из конфигурации
+type Limit struct {
+	// UUID баланса пользователя
+	UserID string `json:"user_id,omitempty" example:"7a13445c-d6df-4111-abc0-abb12f610069"`
+	// Операция: reduce, transfer (исходящие переводы) или reserve
+	Operation OperationType `json:"operation" example:"transfer"`
+	// Период: single, daily, weekly или monthly
+	Period LimitPeriod `json:"period" example:"daily"`
+	// Лимит
+	Amount money.Amount `json:"amount" swaggertype:"number" example:"10000"`
+} // @name Limit
+
+// LimitUsage использование лимита пользователем в текущем периоде
+type LimitUsage struct {
+	// Операция: reduce, transfer или reserve
+	Operation OperationType `json:"operation" example:"transfer"`
+	// Период: single, daily, weekly или monthly
+	Period LimitPeriod `json:"period" example:"daily"`
+	// Лимит
+	Limit money.Amount `json:"limit" swaggertype:"number" example:"10000"`
+	// Общий лимит из конфигурации, а не лимит пользователя
+	Default bool `json:"default" example:"false"`
+	// Использовано в текущем периоде
+	Used money.Amount `json:"used" swaggertype:"number" example:"2500"`
+	// Остаток лимита, для single - максимальная сумма одной операции
+	Remaining money.Amount `json:"remaining" swaggertype:"number" example:"7500"`
+	// Время обновления лимита
+	ResetsAt *time.Time `json:"resets_at,omitempty"`
+} // @name LimitUsage
@@ -1,6 +1,13 @@
 package model
 
+import "github.com/garet2gis/user_balance_service/pkg/money"
+
 type ReportRow struct {
-	ServiceName string `json:"service_name"`
-	Cost        string `json:"cost"`
+	ServiceName string       `json:"service_name"`
+	Cost        money.Amount `json:"cost"`
+	// Комиссии, взятые с пользователей за резервирование и подтверждение услуги
+	Fee money.Amount `json:"fee"`
 }
+
+// TransfersReportName название строки отчета с комиссиями за переводы, которые не относятся ни к одной услуге
+const TransfersReportName = "Переводы"
@@ -1,10 +1,19 @@
 package model
 
+import (
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"time"
+)
+
 type ReservationStatus string
 
 const (
-	Confirm ReservationStatus = "confirm"
-	Cancel                    = "cancel"
+	// ActiveReservation резерв еще не подтвержден и не отменен
+	ActiveReservation ReservationStatus = "active"
+	Confirm           ReservationStatus = "confirm"
+	Cancel                              = "cancel"
+	// Expired резерв отменен автоматически по истечении срока
+	Expired ReservationStatus = "expired"
 )
 
 type Reservation struct {
@@ -15,7 +24,77 @@ type Reservation struct {
 	// UUID заказа
 	OrderID string `json:"order_id" example:"983e8792-6736-41bd-9f1a-7c67f8501645" validate:"required,uuid"`
 	// Стоимость услуги
-	Cost float64 `json:"cost" validate:"gt=0,required"`
+	Cost money.Amount `json:"cost" swaggertype:"number" example:"150.50" validate:"gt=0,required"`
 	// Дополнительный комментарий
 	Comment string `json:"comment,omitempty"`
+	// Время жизни резерва в секундах, 0 - бессрочный резерв. По умолчанию RESERVATION_TTL
+	TTL *int `json:"ttl,omitempty" example:"3600" validate:"omitempty,gte=0"`
 } // @name Reservation
+
+// ReservationState текущее состояние резерва
+type ReservationState struct {
+	// UUID резерва
+	ReservationID string `json:"reservation_id" example:"5c0e5b4e-6f1a-4c1a-8b8e-3f0d2a1b9c7d"`
+	UserID        string `json:"user_id" example:"7a13445c-d6df-4111-abc0-abb12f610069"`
+	ServiceID     string `json:"service_id" example:"34e16535-480c-43f8-95a9-b7a503499af0"`
+	OrderID       string `json:"order_id" example:"983e8792-6736-41bd-9f1a-7c67f8501645"`
+	// Зарезервированная сумма
+	Cost money.Amount `json:"cost" swaggertype:"number" example:"150.50"`
+	// Подтвержденная сумма
+	Captured money.Amount `json:"captured" swaggertype:"number" example:"120.00"`
+	// Возвращенная пользователю часть подтвержденной суммы
+	Refunded money.Amount `json:"refunded" swaggertype:"number" example:"20.00"`
+	Comment  string       `json:"comment,omitempty"`
+	// Статус: active, confirm, cancel или expired
+	Status    ReservationStatus `json:"status" example:"active"`
+	CreatedAt time.Time         `json:"created_at"`
+	// Срок резерва, отсутствует у бессрочного резерва
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Время подтверждения или отмены
+	ClosedAt *time.Time `json:"closed_at,omitempty"`
+} // @name ReservationState
+
+// IsExpired проверяет, истек ли срок резерва к моменту now
+func (rs ReservationState) IsExpired(now time.Time) bool {
+	return rs.ExpiresAt != nil && !rs.ExpiresAt.After(now)
+}
+
+// ReservationAdjustment запись об изменении суммы резерва
+type ReservationAdjustment struct {
+	AdjustmentID  string `json:"adjustment_id"`
+	ReservationID string `json:"reservation_id"`
+	UserID        string `json:"user_id"`
+	OrderID       string `json:"order_id"`
+	ServiceID     string `json:"service_id"`
+	// Сумма резерва до изменения
+	OldCost money.Amount `json:"old_cost" swaggertype:"number" example:"150.50"`
+	// Сумма резерва после изменения
+	NewCost   money.Amount `json:"new_cost" swaggertype:"number" example:"180.00"`
+	Comment   string       `json:"comment,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+} // @name ReservationAdjustment
+
+// OpenReservation действующий резерв в списке
+type OpenReservation struct {
+	ReservationState
+	// Сколько всего денег пользователя сейчас зарезервировано
+	UserHeld money.Amount `json:"user_held" swaggertype:"number" example:"300.00"`
+} // @name OpenReservation
+
+// ReservationList страница действующих резервов с итогами по всем резервам, подходящим под фильтр
+type ReservationList struct {
+	// Число резервов
+	Total int64 `json:"total" example:"2"`
+	// Сумма резервов
+	Held         money.Amount      `json:"held" swaggertype:"number" example:"300.00"`
+	Reservations []OpenReservation `json:"reservations"`
+} // @name ReservationList
+
+// OrderReservation резервы всех услуг заказа
+type OrderReservation struct {
+	OrderID string `json:"order_id" example:"983e8792-6736-41bd-9f1a-7c67f8501645"`
+	UserID  string `json:"user_id" example:"7a13445c-d6df-4111-abc0-abb12f610069"`
+	// Суммарная стоимость услуг заказа
+	Total money.Amount       `json:"total" swaggertype:"number" example:"300.00"`
+	Lines []ReservationState `json:"lines"`
+} // @name OrderReservation
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"time"
+)
+
+type ReviewStatus string
+
+const (
+	PendingReview  ReviewStatus = "pending"
+	ApprovedReview ReviewStatus = "approved"
+	RejectedReview ReviewStatus = "rejected"
+	// FailedReview одобренная операция не выполнилась, например, не хватило денег
+	FailedReview ReviewStatus = "failed"
+)
+
+type Review struct {
+	// UUID заявки на проверку
+	ReviewID string `json:"review_id"`
+	// Операция: reduce, transfer или split_transfer
+	Operation OperationType `json:"operation" example:"transfer"`
+	// UUID баланса пользователя (отправителя для перевода)
+	UserID string `json:"user_id"`
+	// UUID баланса получателя перевода. Пустой для перевода нескольким получателям
+	UserIDTo string `json:"user_id_to,omitempty"`
+	// Сумма операции
+	Amount money.Amount `json:"amount" swaggertype:"number" example:"100.50"`
+	// Исходный запрос, выполняется при одобрении
+	Request json.RawMessage `json:"request" swaggertype:"object"`
+	// Оценка риска
+	Score int `json:"score" example:"120"`
+	// Причины оценки
+	Reasons []string `json:"reasons"`
+	// Статус: pending, approved, rejected или failed
+	Status ReviewStatus `json:"status" example:"pending"`
+	// Комментарий администратора
+	Comment string `json:"comment,omitempty"`
+	// Ошибка выполнения одобренной операции
+	Error string `json:"error,omitempty"`
+	// Ключ идемпотентности исходного запроса
+	IdempotencyKey string `json:"-"`
+	RequestHash    string `json:"-"`
+	// Время создания
+	CreatedAt time.Time `json:"created_at"`
+	// Время решения администратора
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+} // @name Review
+
+// ReviewRequired операция не выполнена, а отправлена на ручную проверку
+type ReviewRequired struct {
+	Review *Review
+}
+
+func (e *ReviewRequired) Error() string {
+	return fmt.Sprintf("operation is pending review %s, risk score %d", e.Review.ReviewID, e.Review.Score)
+}
+
+// RiskSignals недавняя активность пользователя за окно оценки риска
+type RiskSignals struct {
+	// Получатели переводов за окно, которым пользователь раньше не переводил, включая получателя текущей операции
+	NewRecipients int
+	// Исходящие операции за окно: списания, переводы и резервирования
+	Outgoing int
+	// Сумма пополнений за окно
+	Replenished money.Amount
+}
+
+// RiskConfig веса сигналов оценки риска. Операция с оценкой не ниже ReviewScore отправляется на проверку
+type RiskConfig struct {
+	// Окно, за которое учитывается активность пользователя
+	Window time.Duration
+	// Порог отправки на проверку. 0 - оценка риска выключена
+	ReviewScore int
+	// За каждого нового получателя за окно
+	NewRecipientScore int
+	// За списание или перевод после пополнения за окно
+	ReplenishOutScore int
+	// За каждую исходящую операцию за окно, включая текущую
+	VelocityScore int
+}
+
+func (c RiskConfig) Enabled() bool {
+	return c.ReviewScore > 0
+}
+
+// Score оценивает риск исходящей операции и возвращает причины оценки
+func (c RiskConfig) Score(s RiskSignals) (int, []string) {
+	score := 0
+	reasons := make([]string, 0)
+
+	if s.NewRecipients > 0 && c.NewRecipientScore > 0 {
+		score += s.NewRecipients * c.NewRecipientScore
+		reasons = append(reasons, fmt.Sprintf("%d new recipients in %s", s.NewRecipients, c.Window))
+	}
+	if s.Replenished > 0 && c.ReplenishOutScore > 0 {
+		score += c.ReplenishOutScore
+		reasons = append(reasons, fmt.Sprintf("replenished %s in %s", s.Replenished, c.Window))
+	}
+	if c.VelocityScore > 0 {
+		score += (s.Outgoing + 1) * c.VelocityScore
+		reasons = append(reasons, fmt.Sprintf("%d outgoing operations in %s", s.Outgoing+1, c.Window))
+	}
+
+	return score, reasons
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"time"
+)
+
+// DefaultScheduleMaxRetries число повторов неудачного выполнения, если оно не задано при создании расписания
+const DefaultScheduleMaxRetries = 3
+
+type ScheduleStatus string
+
+const (
+	ActiveSchedule    ScheduleStatus = "active"
+	PausedSchedule    ScheduleStatus = "paused"
+	CancelledSchedule ScheduleStatus = "cancelled"
+	// CompletedSchedule разовое расписание успешно выполнено
+	CompletedSchedule ScheduleStatus = "completed"
+	// FailedSchedule разовое расписание не выполнено после всех повторов
+	FailedSchedule ScheduleStatus = "failed"
+)
+
+type Recurrence string
+
+const (
+	Once    Recurrence = "once"
+	Daily   Recurrence = "daily"
+	Weekly  Recurrence = "weekly"
+	Monthly Recurrence = "monthly"
+)
+
+type Schedule struct {
+	// UUID расписания
+	ScheduleID string `json:"schedule_id"`
+	// Операция: reduce или transfer
+	Operation OperationType `json:"operation" example:"transfer"`
+	// UUID баланса пользователя (отправителя для перевода)
+	UserID string `json:"user_id"`
+	// UUID баланса получателя перевода
+	UserIDTo string `json:"user_id_to,omitempty"`
+	// Сумма операции
+	Amount money.Amount `json:"amount" swaggertype:"number" example:"100.50"`
+	// Коментарий
+	Comment string `json:"comment,omitempty"`
+	// Периодичность: once, daily, weekly или monthly
+	Recurrence Recurrence `json:"recurrence" example:"monthly"`
+	// День месяца для monthly
+	DayOfMonth int `json:"day_of_month,omitempty" example:"10"`
+	// Статус: active, paused, cancelled, completed или failed
+	Status ScheduleStatus `json:"status" example:"active"`
+	// Плановое время текущего выполнения
+	DueAt time.Time `json:"due_at"`
+	// Время следующей попытки, позже due_at при повторе после ошибки
+	NextRunAt time.Time `json:"next_run_at"`
+	// Номер повтора текущего выполнения
+	Attempt int `json:"attempt"`
+	// Сколько раз повторять неудачное выполнение
+	MaxRetries int `json:"max_retries"`
+	// Время создания
+	CreatedAt time.Time `json:"created_at"`
+} // @name Schedule
+
+// Next возвращает первое плановое время выполнения позже after. Пропущенные выполнения
+// (например, пока сервис был остановлен) не наверстываются. Для разового расписания возвращает false
+func (s Schedule) Next(after time.Time) (time.Time, bool) {
+	if s.Recurrence == Once || s.Recurrence == "" {
+		return time.Time{}, false
+	}
+
+	next := s.DueAt
+	for !next.After(after) {
+		switch s.Recurrence {
+		case Daily:
+			next = next.AddDate(0, 0, 1)
+		case Weekly:
+			next = next.AddDate(0, 0, 7)
+		case Monthly:
+			next = DayOfMonth(next.Year(), next.Month()+1, s.DayOfMonth, next)
+		default:
+			return time.Time{}, false
+		}
+	}
+
+	return next, true
+}
+
+// DayOfMonth возвращает время clock в день day заданного месяца. Если в месяце меньше дней,
+// используется последний день месяца
+func DayOfMonth(year int, month time.Month, day int, clock time.Time) time.Time {
+	// нулевой день следующего месяца - последний день текущего
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, clock.Location()).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(year, month, day, clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(), clock.Location())
+}
+
+type ScheduleRunStatus string
+
+const (
+	SucceededRun ScheduleRunStatus = "succeeded"
+	FailedRun    ScheduleRunStatus = "failed"
+	// PendingReviewRun операция отправлена на ручную проверку и выполнится при одобрении заявки
+	PendingReviewRun ScheduleRunStatus = "pending_review"
+)
+
+// ScheduleRun результат одной попытки выполнения расписания
+type ScheduleRun struct {
+	ScheduleID string `json:"schedule_id"`
+	// Плановое время выполнения, к которому относится попытка
+	DueAt time.Time `json:"due_at"`
+	// Номер повтора, 0 - первая попытка
+	Attempt int               `json:"attempt"`
+	Status  ScheduleRunStatus `json:"status" example:"failed"`
+	// Ошибка неудачной попытки
+	Error string `json:"error,omitempty" example:"not enough money on balance"`
+	// UUID заявки на проверку для попытки со статусом pending_review
+	ReviewID   string    `json:"review_id,omitempty"`
+	ExecutedAt time.Time `json:"executed_at"`
+} // @name ScheduleRun
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"fmt"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"time"
+)
+
+// Service услуга из каталога
+type Service struct {
+	// UUID сервиса
+	ServiceID string `json:"service_id" example:"34e16535-480c-43f8-95a9-b7a503499af0"`
+	// Название
+	Name string `json:"name" example:"Курьерская доставка"`
+	// Неактивная услуга недоступна для новых резервов
+	Active    bool      `json:"active" example:"true"`
+	CreatedAt time.Time `json:"created_at"`
+} // @name Service
+
+// ServicePrice цена услуги в прайс-листе: фиксированная (Price) либо диапазон (MinPrice, MaxPrice),
+// нулевое значение означает, что поле не задано
+type ServicePrice struct {
+	// UUID цены
+	PriceID string `json:"price_id"`
+	// UUID сервиса
+	ServiceID string `json:"service_id" example:"34e16535-480c-43f8-95a9-b7a503499af0"`
+	// Фиксированная цена
+	Price money.Amount `json:"price,omitempty" swaggertype:"number" example:"100.50"`
+	// Минимальная цена
+	MinPrice money.Amount `json:"min_price,omitempty" swaggertype:"number" example:"50"`
+	// Максимальная цена
+	MaxPrice money.Amount `json:"max_price,omitempty" swaggertype:"number" example:"500"`
+	// Начало действия цены
+	ValidFrom time.Time `json:"valid_from"`
+	// Окончание действия цены, без него цена действует до появления более новой
+	ValidTo   *time.Time `json:"valid_to,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+} // @name ServicePrice
+
+// Allows проверяет, что стоимость cost соответствует цене
+func (p ServicePrice) Allows(cost money.Amount) bool {
+	if p.Price != 0 {
+		return cost == p.Price
+	}
+	return (p.MinPrice == 0 || cost >= p.MinPrice) && (p.MaxPrice == 0 || cost <= p.MaxPrice)
+}
+
+// String описание цены для сообщений об ошибках
+func (p ServicePrice) String() string {
+	switch {
+	case p.Price != 0:
+		return p.Price.String()
+	case p.MaxPrice == 0:
+		return fmt.Sprintf("from %s", p.MinPrice)
+	case p.MinPrice == 0:
+		return fmt.Sprintf("up to %s", p.MaxPrice)
+	default:
+		return fmt.Sprintf("%s-%s", p.MinPrice, p.MaxPrice)
+	}
+}
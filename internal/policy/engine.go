@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var Denied = errors.New("operation denied by policy")
+
+// Decision результат проверки операции правилами
+type Decision struct {
+	// Правило, разрешившее или отклонившее операцию. nil - ни одно такое правило не сработало
+	Rule *Rule
+	// Сработавшие правила с действием flag
+	Flags []Rule
+}
+
+// Engine проверяет операции правилами из файла. Без загруженного файла все операции разрешены
+type Engine struct {
+	mu      sync.RWMutex
+	rules   RuleSet
+	path    string
+	modTime time.Time
+	// dryRun только записывает решения в лог для всех правил
+	dryRun bool
+	logger *logging.Logger
+}
+
+func NewEngine(dryRun bool, l *logging.Logger) *Engine {
+	return &Engine{
+		dryRun: dryRun,
+		logger: l,
+	}
+}
+
+// Load читает правила из YAML или JSON файла. При ошибке действуют ранее загруженные правила
+func (e *Engine) Load(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var rules RuleSet
+	// JSON - подмножество YAML, поэтому оба формата читаются одним парсером
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err = dec.Decode(&rules); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("policy file %s: %w", path, err)
+	}
+	if err = rules.validate(); err != nil {
+		return fmt.Errorf("policy file %s: %w", path, err)
+	}
+
+	e.mu.Lock()
+	e.rules, e.path, e.modTime = rules, path, info.ModTime()
+	e.mu.Unlock()
+
+	e.logger.Infof("loaded %d policy rules from %s", len(rules.Rules), path)
+	return nil
+}
+
+// Reload перечитывает файл правил, если он изменился после последней загрузки
+func (e *Engine) Reload() error {
+	e.mu.RLock()
+	path, modTime := e.path, e.modTime
+	e.mu.RUnlock()
+
+	if path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(modTime) {
+		return nil
+	}
+
+	return e.Load(path)
+}
+
+// Evaluate проверяет операцию правилами по порядку до первого сработавшего правила allow или deny
+func (e *Engine) Evaluate(op Operation) Decision {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var d Decision
+	for i := range e.rules.Rules {
+		r := e.rules.Rules[i]
+		if !r.Matches(op) {
+			continue
+		}
+		if r.Action == Flag {
+			d.Flags = append(d.Flags, r)
+			continue
+		}
+		d.Rule = &r
+		break
+	}
+	return d
+}
+
+// Check проверяет операцию и возвращает ошибку, если правило ее отклонило.
+// В режиме dry run решение только записывается в лог
+func (e *Engine) Check(op Operation) error {
+	d := e.Evaluate(op)
+
+	for _, r := range d.Flags {
+		e.logger.Warnf("%spolicy rule %s flagged %s", e.prefix(r), r.Name, op)
+	}
+
+	if d.Rule == nil {
+		return nil
+	}
+	r := *d.Rule
+
+	if r.Action == Allow {
+		if e.dryRun || r.DryRun {
+			e.logger.Infof("%spolicy rule %s allowed %s", e.prefix(r), r.Name, op)
+		}
+		return nil
+	}
+
+	e.logger.Warnf("%spolicy rule %s denied %s", e.prefix(r), r.Name, op)
+	if e.dryRun || r.DryRun {
+		return nil
+	}
+
+	message := r.Message
+	if message == "" {
+		message = Denied.Error()
+	}
+	return apperror.NewAppError(Denied, message, fmt.Sprintf("rule %s denied %s", r.Name, op))
+}
+
+func (e *Engine) prefix(r Rule) string {
+	if e.dryRun || r.DryRun {
+		return "dry run: "
+	}
+	return ""
+}
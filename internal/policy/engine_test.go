@@ -0,0 +1,201 @@
+package policy
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestEngine движок с логгером без записи в файл
+func newTestEngine(t *testing.T, dryRun bool, content string) *Engine {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	e := NewEngine(dryRun, &logging.Logger{Entry: logrus.NewEntry(l)})
+
+	require.NoError(t, e.Load(writeRules(t, content)))
+	return e
+}
+
+func writeRules(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+const testRules = `
+rules:
+  - name: flag-large
+    action: flag
+    amount_gte: 1000
+  - name: allow-trusted
+    action: allow
+    user_ids: ["7a13445c-d6df-4111-abc0-abb12f610001"]
+  - name: deny-large
+    action: deny
+    message: amount is too large
+    amount_gt: 5000
+  - name: deny-empty-comment
+    action: deny
+    dry_run: true
+    operations: [transfer]
+    comment_empty: true
+`
+
+func TestEngineCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		dryRun  bool
+		op      Operation
+		rule    string
+		flags   []string
+		message string
+	}{
+		{
+			name: "no rule matches",
+			op:   Operation{Type: model.ReplenishOperation, UserID: "u", Amount: money.MustParse("10")},
+		},
+		{
+			name:  "flag does not stop evaluation",
+			op:    Operation{Type: model.ReplenishOperation, UserID: "u", Amount: money.MustParse("1000")},
+			flags: []string{"flag-large"},
+		},
+		{
+			name:  "allow stops before deny",
+			op:    Operation{Type: model.ReplenishOperation, UserID: "7a13445c-d6df-4111-abc0-abb12f610001", Amount: money.MustParse("6000")},
+			rule:  "allow-trusted",
+			flags: []string{"flag-large"},
+		},
+		{
+			name:    "deny",
+			op:      Operation{Type: model.ReplenishOperation, UserID: "u", Amount: money.MustParse("6000")},
+			rule:    "deny-large",
+			flags:   []string{"flag-large"},
+			message: "amount is too large",
+		},
+		{
+			name:   "deny in dry run engine",
+			dryRun: true,
+			op:     Operation{Type: model.ReplenishOperation, UserID: "u", Amount: money.MustParse("6000")},
+			rule:   "deny-large",
+			flags:  []string{"flag-large"},
+		},
+		{
+			name: "deny in dry run rule",
+			op:   Operation{Type: model.TransferOperation, UserID: "u", UserIDTo: "v", Amount: money.MustParse("10")},
+			rule: "deny-empty-comment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, tt.dryRun, testRules)
+
+			d := e.Evaluate(tt.op)
+			if tt.rule == "" {
+				require.Nil(t, d.Rule)
+			} else {
+				require.NotNil(t, d.Rule)
+				require.Equal(t, tt.rule, d.Rule.Name)
+			}
+			var flags []string
+			for _, r := range d.Flags {
+				flags = append(flags, r.Name)
+			}
+			require.Equal(t, tt.flags, flags)
+
+			err := e.Check(tt.op)
+			if tt.message == "" {
+				require.NoError(t, err)
+				return
+			}
+			var appErr *apperror.AppError
+			require.True(t, errors.As(err, &appErr))
+			require.ErrorIs(t, err, Denied)
+			require.Equal(t, tt.message, appErr.Message)
+		})
+	}
+}
+
+func TestEngineDefaultMessage(t *testing.T) {
+	e := newTestEngine(t, false, "rules:\n  - name: deny-all\n    action: deny\n")
+
+	err := e.Check(Operation{Type: model.ReduceOperation, UserID: "u", Amount: money.MustParse("1")})
+	var appErr *apperror.AppError
+	require.True(t, errors.As(err, &appErr))
+	require.Equal(t, Denied.Error(), appErr.Message)
+}
+
+func TestEngineLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{name: "malformed", content: "rules: [", err: "policy file"},
+		{name: "unknown field", content: "rules:\n  - name: a\n    action: deny\n    amount_above: 1\n", err: "amount_above"},
+		{name: "invalid amount", content: "rules:\n  - name: a\n    action: deny\n    amount_gt: abc\n", err: "policy file"},
+		{name: "missing name", content: "rules:\n  - action: deny\n", err: "rule name is required"},
+		{name: "unknown action", content: "rules:\n  - name: a\n    action: block\n", err: `unknown action "block"`},
+		{name: "unknown operation", content: "rules:\n  - name: a\n    action: deny\n    operations: [refund]\n", err: `unknown operation "refund"`},
+		{name: "duplicate name", content: "rules:\n  - name: a\n    action: deny\n  - name: a\n    action: flag\n", err: "duplicate rule name a"},
+		{name: "json", content: `{"rules": [{"name": "a", "action": "block"}]}`, err: `unknown action "block"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, false, "rules: []\n")
+
+			err := e.Load(writeRules(t, tt.content))
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		e := newTestEngine(t, false, "rules: []\n")
+		require.Error(t, e.Load(filepath.Join(t.TempDir(), "missing.yaml")))
+	})
+}
+
+func TestEngineReload(t *testing.T) {
+	op := Operation{Type: model.ReplenishOperation, UserID: "u", Amount: money.MustParse("10")}
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	e := NewEngine(false, &logging.Logger{Entry: logrus.NewEntry(l)})
+
+	// Без загруженного файла перечитывать нечего
+	require.NoError(t, e.Reload())
+
+	path := writeRules(t, "rules: []\n")
+	require.NoError(t, e.Load(path))
+	require.NoError(t, e.Check(op))
+
+	// Файл не изменился - правила не перечитываются
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: deny-all\n    action: deny\n"), 0o600))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Chtimes(path, info.ModTime(), e.modTime))
+	require.NoError(t, e.Reload())
+	require.NoError(t, e.Check(op))
+
+	// Файл изменился - загружаются новые правила
+	require.NoError(t, os.Chtimes(path, time.Now(), e.modTime.Add(time.Second)))
+	require.NoError(t, e.Reload())
+	require.ErrorIs(t, e.Check(op), Denied)
+
+	// Ошибка в новом файле - действуют прежние правила
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: a\n    action: block\n"), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), e.modTime.Add(time.Second)))
+	require.Error(t, e.Reload())
+	require.ErrorIs(t, e.Check(op), Denied)
+}
@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+)
+
+type Action string
+
+const (
+	// Allow разрешает операцию без проверки следующих правил
+	Allow Action = "allow"
+	// Deny отклоняет операцию
+	Deny Action = "deny"
+	// Flag помечает операцию в логе, проверка продолжается следующими правилами
+	Flag Action = "flag"
+)
+
+// Operation операция, которую проверяют правила
+type Operation struct {
+	// replenish, reduce, transfer или reserve
+	Type model.OperationType
+	// Пользователь, чей баланс меняется (отправитель перевода)
+	UserID string
+	// Получатель перевода
+	UserIDTo string
+	// Услуга резерва
+	ServiceID string
+	Amount    money.Amount
+	Comment   string
+}
+
+func (o Operation) String() string {
+	s := fmt.Sprintf("%s %s of user %s", o.Type, o.Amount, o.UserID)
+	if o.UserIDTo != "" {
+		s += fmt.Sprintf(" to user %s", o.UserIDTo)
+	}
+	if o.ServiceID != "" {
+		s += fmt.Sprintf(" for service %s", o.ServiceID)
+	}
+	return s
+}
+
+// Rule правило срабатывает, когда выполнены все заданные в нем условия. Незаданное условие не проверяется
+type Rule struct {
+	// Уникальное имя правила, попадает в ответ и лог
+	Name   string `yaml:"name" json:"name"`
+	Action Action `yaml:"action" json:"action"`
+	// Сообщение клиенту при отклонении операции
+	Message string `yaml:"message" json:"message"`
+	// Только записывать решение правила в лог
+	DryRun bool `yaml:"dry_run" json:"dry_run"`
+
+	// Операции, к которым применяется правило. Пусто - ко всем
+	Operations []model.OperationType `yaml:"operations" json:"operations"`
+	UserIDs    []string              `yaml:"user_ids" json:"user_ids"`
+	ServiceIDs []string              `yaml:"service_ids" json:"service_ids"`
+	AmountGT   *money.Amount         `yaml:"amount_gt" json:"amount_gt"`
+	AmountGTE  *money.Amount         `yaml:"amount_gte" json:"amount_gte"`
+	AmountLT   *money.Amount         `yaml:"amount_lt" json:"amount_lt"`
+	AmountLTE  *money.Amount         `yaml:"amount_lte" json:"amount_lte"`
+	// Срабатывает, только если комментарий пустой (true) или заполнен (false)
+	CommentEmpty *bool `yaml:"comment_empty" json:"comment_empty"`
+}
+
+// RuleSet содержимое файла правил. Правила проверяются по порядку
+type RuleSet struct {
+	Rules []Rule `yaml:"rules" json:"rules"`
+}
+
+// operations операции, перед которыми вызываются правила
+var operations = map[model.OperationType]bool{
+	model.ReplenishOperation: true,
+	model.ReduceOperation:    true,
+	model.TransferOperation:  true,
+	model.ReserveOperation:   true,
+}
+
+func (r Rule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if r.Action != Allow && r.Action != Deny && r.Action != Flag {
+		return fmt.Errorf("rule %s: unknown action %q", r.Name, r.Action)
+	}
+	for _, op := range r.Operations {
+		if !operations[op] {
+			return fmt.Errorf("rule %s: unknown operation %q", r.Name, op)
+		}
+	}
+	return nil
+}
+
+func (s RuleSet) validate() error {
+	names := make(map[string]bool, len(s.Rules))
+	for _, r := range s.Rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate rule name %s", r.Name)
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+func (r Rule) Matches(op Operation) bool {
+	if len(r.Operations) > 0 && !contains(r.Operations, op.Type) {
+		return false
+	}
+	if len(r.UserIDs) > 0 && !contains(r.UserIDs, op.UserID) {
+		return false
+	}
+	if len(r.ServiceIDs) > 0 && !contains(r.ServiceIDs, op.ServiceID) {
+		return false
+	}
+	if r.AmountGT != nil && op.Amount <= *r.AmountGT {
+		return false
+	}
+	if r.AmountGTE != nil && op.Amount < *r.AmountGTE {
+		return false
+	}
+	if r.AmountLT != nil && op.Amount >= *r.AmountLT {
+		return false
+	}
+	if r.AmountLTE != nil && op.Amount > *r.AmountLTE {
+		return false
+	}
+	if r.CommentEmpty != nil && *r.CommentEmpty != (op.Comment == "") {
+		return false
+	}
+	return true
+}
+
+func contains[T comparable](values []T, v T) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
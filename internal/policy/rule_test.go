@@ -0,0 +1,89 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/stretchr/testify/require"
+)
+
+func amount(s string) *money.Amount {
+	a := money.MustParse(s)
+	return &a
+}
+
+func boolean(b bool) *bool {
+	return &b
+}
+
+func TestRuleMatches(t *testing.T) {
+	op := Operation{
+		Type:      model.ReserveOperation,
+		UserID:    "7a13445c-d6df-4111-abc0-abb12f610069",
+		ServiceID: "34e16535-480c-43f8-95a9-b7a503499af0",
+		Amount:    money.MustParse("100"),
+		Comment:   "order",
+	}
+
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{name: "no conditions", rule: Rule{}, want: true},
+		{name: "operation matches", rule: Rule{Operations: []model.OperationType{model.TransferOperation, model.ReserveOperation}}, want: true},
+		{name: "operation differs", rule: Rule{Operations: []model.OperationType{model.ReduceOperation}}, want: false},
+		{name: "user matches", rule: Rule{UserIDs: []string{"7a13445c-d6df-4111-abc0-abb12f610069"}}, want: true},
+		{name: "user differs", rule: Rule{UserIDs: []string{"7a13445c-d6df-4111-abc0-abb12f610068"}}, want: false},
+		{name: "service matches", rule: Rule{ServiceIDs: []string{"34e16535-480c-43f8-95a9-b7a503499af0"}}, want: true},
+		{name: "service differs", rule: Rule{ServiceIDs: []string{"34e16535-480c-43f8-95a9-b7a503499af1"}}, want: false},
+		{name: "amount_gt below", rule: Rule{AmountGT: amount("99.99")}, want: true},
+		{name: "amount_gt equal", rule: Rule{AmountGT: amount("100")}, want: false},
+		{name: "amount_gte equal", rule: Rule{AmountGTE: amount("100")}, want: true},
+		{name: "amount_gte above", rule: Rule{AmountGTE: amount("100.01")}, want: false},
+		{name: "amount_lt above", rule: Rule{AmountLT: amount("100.01")}, want: true},
+		{name: "amount_lt equal", rule: Rule{AmountLT: amount("100")}, want: false},
+		{name: "amount_lte equal", rule: Rule{AmountLTE: amount("100")}, want: true},
+		{name: "amount_lte below", rule: Rule{AmountLTE: amount("99.99")}, want: false},
+		{name: "amount range", rule: Rule{AmountGTE: amount("50"), AmountLT: amount("150")}, want: true},
+		{name: "comment filled", rule: Rule{CommentEmpty: boolean(false)}, want: true},
+		{name: "comment empty", rule: Rule{CommentEmpty: boolean(true)}, want: false},
+		{
+			name: "one condition fails",
+			rule: Rule{Operations: []model.OperationType{model.ReserveOperation}, AmountGT: amount("1000")},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.rule.Matches(op))
+		})
+	}
+}
+
+func TestRuleSetValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		err   string
+	}{
+		{name: "valid", rules: []Rule{{Name: "a", Action: Deny}, {Name: "b", Action: Flag, Operations: []model.OperationType{model.ReplenishOperation}}}},
+		{name: "missing name", rules: []Rule{{Action: Deny}}, err: "rule name is required"},
+		{name: "unknown action", rules: []Rule{{Name: "a", Action: "block"}}, err: `unknown action "block"`},
+		{name: "unknown operation", rules: []Rule{{Name: "a", Action: Deny, Operations: []model.OperationType{model.ConfirmOperation}}}, err: `unknown operation "confirm"`},
+		{name: "duplicate name", rules: []Rule{{Name: "a", Action: Deny}, {Name: "a", Action: Flag}}, err: "duplicate rule name a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RuleSet{Rules: tt.rules}.validate()
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
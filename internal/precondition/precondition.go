@@ -0,0 +1,90 @@
+package precondition
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Header заголовок, в котором клиент передает версии баланса, при которых операцию можно выполнить
+	Header = "If-Match"
+	// ETagHeader заголовок, в котором возвращается текущая версия баланса
+	ETagHeader = "ETag"
+)
+
+// Versions версии баланса из заголовка If-Match
+type Versions []int64
+
+type ctxKey struct{}
+
+type resultKey struct{}
+
+// ETag форматирует версию баланса для заголовка ETag
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// Parse разбирает заголовок If-Match: список ETag через запятую или *. Для * возвращается nil,
+// то есть операция выполняется при любой версии. If-Match сравнивает ETag строго, поэтому слабый
+// ETag (W/"3") допустим, но не совпадает ни с одной версией
+func Parse(value string) (Versions, error) {
+	if strings.TrimSpace(value) == "*" {
+		return nil, nil
+	}
+
+	tags := strings.Split(value, ",")
+	versions := make(Versions, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		weak := strings.HasPrefix(tag, "W/")
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		unquoted, err := strconv.Unquote(tag)
+		if err != nil || !strings.HasPrefix(tag, `"`) {
+			return nil, fmt.Errorf("%s must contain balance versions as returned in %s, got %q", Header, ETagHeader, tag)
+		}
+		if weak {
+			continue
+		}
+		version, err := strconv.ParseInt(unquoted, 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("%s must contain balance versions as returned in %s, got %q", Header, ETagHeader, tag)
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+func (v Versions) Match(version int64) bool {
+	for _, expected := range v {
+		if expected == version {
+			return true
+		}
+	}
+	return false
+}
+
+func WithVersions(ctx context.Context, v Versions) context.Context {
+	return context.WithValue(ctx, ctxKey{}, v)
+}
+
+func FromContext(ctx context.Context) (Versions, bool) {
+	v, ok := ctx.Value(ctxKey{}).(Versions)
+	return v, ok
+}
+
+// WithResult добавляет в контекст переменную, в которую операция записывает версию баланса после изменения.
+// Версия остается нулевой, если операция не выполнялась, например, при повторе по ключу идемпотентности
+func WithResult(ctx context.Context) (context.Context, *int64) {
+	version := new(int64)
+	return context.WithValue(ctx, resultKey{}, version), version
+}
+
+func ResultFromContext(ctx context.Context) (*int64, bool) {
+	version, ok := ctx.Value(resultKey{}).(*int64)
+	return version, ok
+}
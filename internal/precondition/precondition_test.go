@@ -0,0 +1,63 @@
+package precondition
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Versions
+		wantErr bool
+	}{
+		{name: "any", value: " * ", want: nil},
+		{name: "single", value: `"3"`, want: Versions{3}},
+		{name: "list", value: `"3", "5"`, want: Versions{3, 5}},
+		{name: "weak", value: `W/"3"`, want: Versions{}},
+		{name: "weak and strong", value: `W/"3", "5"`, want: Versions{5}},
+		{name: "unquoted", value: `3`, wantErr: true},
+		{name: "weak unquoted", value: `W/3`, wantErr: true},
+		{name: "not a number", value: `"abc"`, wantErr: true},
+		{name: "zero", value: `"0"`, wantErr: true},
+		{name: "empty element", value: `"3",`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVersionsMatch(t *testing.T) {
+	weak, err := Parse(`W/"3"`)
+	require.NoError(t, err)
+	require.NotNil(t, weak, "Weak ETag must not mean any version")
+	require.False(t, weak.Match(3), "Weak ETag must not match")
+
+	strong, err := Parse(`"3", "5"`)
+	require.NoError(t, err)
+	require.True(t, strong.Match(5))
+	require.False(t, strong.Match(4))
+}
+
+func TestResult(t *testing.T) {
+	_, ok := ResultFromContext(context.Background())
+	require.False(t, ok)
+
+	ctx, version := WithResult(context.Background())
+	saved, ok := ResultFromContext(ctx)
+	require.True(t, ok)
+	*saved = 7
+	require.Equal(t, int64(7), *version)
+}
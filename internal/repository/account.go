@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/garet2gis/user_balance_service/pkg/postgresql"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	CreditLimitBelowDebt    = errors.New("credit limit is lower than current debt")
+	AccountFrozen           = errors.New("account is frozen")
+	AccountClosed           = errors.New("account is closed")
+	AccountNotEmpty         = errors.New("account has non-zero balance or open reservations")
+	InvalidStatusTransition = errors.New("invalid account status transition")
+)
+
+// statusTransitions допустимые переходы: новый статус -> статусы, из которых в него можно перейти
+var statusTransitions = map[model.AccountStatus][]model.AccountStatus{
+	model.ActiveStatus: {model.FrozenStatus},
+	model.FrozenStatus: {model.ActiveStatus},
+	model.ClosedStatus: {model.ActiveStatus, model.FrozenStatus},
+}
+
+type AccountRepository struct {
+	TransactionHelper
+	BalanceChanger
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewAccountRepository(c *pgxpool.Pool, l *logging.Logger) *AccountRepository {
+	return &AccountRepository{
+		TransactionHelper: *NewTransactionHelper(c, l),
+		BalanceChanger:    *NewBalanceChanger(c, l),
+		client:            c,
+		logger:            l,
+	}
+}
+
+func (r *AccountRepository) GetAccountState(ctx context.Context, id string) (*model.AccountState, error) {
+	q := `
+		SELECT status::text, credit_limit, version
+		FROM balance
+		WHERE user_id = $1
+	`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var state model.AccountState
+	err := conn(ctx, r.client).QueryRow(ctx, q, id).Scan(&state.Status, &state.CreditLimit, &state.Version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.ErrNotFound
+		}
+
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// SetCreditLimit устанавливает кредитный лимит, создавая баланс пользователя, если его еще нет.
+// С заголовком If-Match лимит меняется только у существующего баланса с совпавшей версией
+func (r *AccountRepository) SetCreditLimit(ctx context.Context, cl dto.CreditLimitRequest) error {
+	return r.inTx(ctx, func(t pgx.Tx) (err error) {
+		err = r.checkVersion(ctx, t, cl.UserID)
+		if err != nil {
+			return err
+		}
+
+		q := `
+		INSERT INTO balance (user_id, credit_limit)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET credit_limit = excluded.credit_limit
+	`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		_, err = t.Exec(ctx, q, cl.UserID, cl.CreditLimit)
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			// новый лимит не покрывает уже имеющийся долг
+			if errors.Is(err, NotEnoughMoney) {
+				return toDBError(CreditLimitBelowDebt)
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ChangeStatus переводит счет в новый статус и записывает переход в историю.
+// Закрыть можно только счет с нулевым балансом и без открытых резерваций
+func (r *AccountRepository) ChangeStatus(ctx context.Context, sr dto.AccountStatusRequest, status model.AccountStatus) error {
+	return r.inTx(ctx, func(t pgx.Tx) (err error) {
+		q := `
+		SELECT status::text, balance
+		FROM balance
+		WHERE user_id = $1
+		FOR UPDATE
+	`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		var previous model.AccountStatus
+		var balance money.Amount
+		err = t.QueryRow(ctx, q, sr.UserID).Scan(&previous, &balance)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return apperror.ErrNotFound
+			}
+
+			err = PgxErrorLog(err, r.logger)
+			return err
+		}
+
+		err = r.checkVersion(ctx, t, sr.UserID)
+		if err != nil {
+			return err
+		}
+
+		if !canTransit(previous, status) {
+			err = apperror.NewAppError(apperror.ErrConflict, InvalidStatusTransition.Error(),
+				fmt.Sprintf("%s -> %s", previous, status))
+			return err
+		}
+
+		if status == model.ClosedStatus {
+			err = r.checkEmpty(ctx, t, sr.UserID, balance)
+			if err != nil {
+				return err
+			}
+		}
+
+		q = `
+		UPDATE balance
+		SET status = $1
+		WHERE user_id = $2
+	`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		_, err = t.Exec(ctx, q, string(status), sr.UserID)
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return err
+		}
+
+		q = `
+		INSERT INTO account_status_history (user_id, previous_status, status, comment)
+		VALUES ($1, $2, $3, $4)
+	`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		_, err = t.Exec(ctx, q, sr.UserID, string(previous), string(status), sr.Comment)
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// checkEmpty проверяет, что на счетах пользователя не осталось денег
+func (r *AccountRepository) checkEmpty(ctx context.Context, tx pgx.Tx, userID string, balance money.Amount) error {
+	q := `
+		SELECT EXISTS(SELECT 1 FROM reservation WHERE user_id = $1 AND status IS NULL)
+		    OR COALESCE((SELECT SUM(posting.amount)
+		                 FROM posting
+		                          JOIN account USING (account_id)
+		                 WHERE account.type = 'reserved'
+		                   AND account.owner_id = $1), 0) <> 0
+	`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var reserved bool
+	err := tx.QueryRow(ctx, q, userID).Scan(&reserved)
+	if err != nil {
+		return PgxErrorLog(err, r.logger)
+	}
+
+	if balance != 0 || reserved {
+		return apperror.NewAppError(apperror.ErrConflict, AccountNotEmpty.Error(),
+			fmt.Sprintf("balance: %s", balance))
+	}
+
+	return nil
+}
+
+func canTransit(from, to model.AccountStatus) bool {
+	for _, s := range statusTransitions[to] {
+		if s == from {
+			return true
+		}
+	}
+	return false
+}
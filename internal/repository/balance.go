@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"github.com/garet2gis/user_balance_service/internal/apperror"
 	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
 	"github.com/garet2gis/user_balance_service/internal/model"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
 	"github.com/garet2gis/user_balance_service/pkg/postgresql"
 	"github.com/garet2gis/user_balance_service/pkg/utils"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
 var (
@@ -20,17 +23,19 @@ var (
 
 type BalanceRepository struct {
 	TransactionHelper
-	BalanceChanger
+	Ledger
+	IdempotencyRepository
 	client postgresql.Client
 	logger *logging.Logger
 }
 
 func NewBalanceRepository(c *pgxpool.Pool, l *logging.Logger) *BalanceRepository {
 	return &BalanceRepository{
-		TransactionHelper: *NewTransactionHelper(c, l),
-		BalanceChanger:    *NewBalanceChanger(c, l),
-		client:            c,
-		logger:            l,
+		TransactionHelper:     *NewTransactionHelper(c, l),
+		Ledger:                *NewLedger(c, l),
+		IdempotencyRepository: *NewIdempotencyRepository(c, l),
+		client:                c,
+		logger:                l,
 	}
 }
 
@@ -50,57 +55,12 @@ func (r *BalanceRepository) createBalance(ctx context.Context, tx pgx.Tx, id str
 	return nil
 }
 
-func (r *BalanceRepository) createHistoryDeposit(ctx context.Context, tx pgx.Tx, b dto.BalanceChangeRequest) error {
-	q := `
-		INSERT INTO history_deposit (user_id, amount, comment) 
-		VALUES ($1, $2, $3)
-		`
-	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
-
-	_, err := tx.Exec(ctx, q, b.UserID, b.Amount, b.Comment)
-	if err != nil {
-		err = PgxErrorLog(err, r.logger)
-		return err
-	}
-
-	return nil
-}
-
-func (r *BalanceRepository) createHistoryTransfer(ctx context.Context, tx pgx.Tx, b dto.TransferRequest) error {
-
-	q := `
-		INSERT INTO history_deposit (user_id, to_user_id, amount, comment) 
-		VALUES ($1, $2 ,$3, $4)
-		`
-	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
-
-	_, err := tx.Exec(ctx, q, b.UserIDFrom, b.UserIDTo, -b.Amount, b.Comment)
-	if err != nil {
-		err = PgxErrorLog(err, r.logger)
-		return err
-	}
-
-	q = `
-		INSERT INTO history_deposit (user_id, from_user_id, amount, comment) 
-		VALUES ($1, $2 ,$3, $4)
-		`
-	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
-
-	_, err = tx.Exec(ctx, q, b.UserIDTo, b.UserIDFrom, b.Amount, b.Comment)
-	if err != nil {
-		err = PgxErrorLog(err, r.logger)
-		return err
-	}
-
-	return nil
-}
-
-func (r *BalanceRepository) GetBalanceByUserID(ctx context.Context, id string) (float64, error) {
+func (r *BalanceRepository) GetBalanceByUserID(ctx context.Context, id string) (money.Amount, error) {
 	var fakeTx pgx.Tx
 	return r.getBalanceByUserID(ctx, fakeTx, id)
 }
 
-func (r *BalanceRepository) getBalanceByUserID(ctx context.Context, tx pgx.Tx, id string) (float64, error) {
+func (r *BalanceRepository) getBalanceByUserID(ctx context.Context, tx pgx.Tx, id string) (money.Amount, error) {
 	q := `
 		SELECT 
 		       balance.balance
@@ -112,10 +72,10 @@ func (r *BalanceRepository) getBalanceByUserID(ctx context.Context, tx pgx.Tx, i
 	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
 
 	var err error
-	var balance float64
+	var balance money.Amount
 
 	if tx == nil {
-		err = r.client.QueryRow(ctx, q, id).Scan(&balance)
+		err = conn(ctx, r.client).QueryRow(ctx, q, id).Scan(&balance)
 	} else {
 		err = tx.QueryRow(ctx, q, id).Scan(&balance)
 	}
@@ -132,77 +92,259 @@ func (r *BalanceRepository) getBalanceByUserID(ctx context.Context, tx pgx.Tx, i
 	return balance, nil
 }
 
-func (r *BalanceRepository) ChangeUserBalance(ctx context.Context, b dto.BalanceChangeRequest, depositType model.DepositType) (bm *dto.BalanceChangeRequest, err error) {
-	t, err := r.beginTransaction(ctx)
+// GetBalanceAt считает доступный и зарезервированный остаток пользователя по проводкам.
+// Если asOf не передан, учитываются все проводки, иначе - созданные не позже asOf
+func (r *BalanceRepository) GetBalanceAt(ctx context.Context, id string, asOf *time.Time) (available money.Amount, reserved money.Amount, err error) {
+	q := `
+		SELECT 
+		       COALESCE(SUM(posting.amount) FILTER (WHERE account.type = 'user'), 0),
+		       COALESCE(SUM(posting.amount) FILTER (WHERE account.type = 'reserved'), 0)
+		FROM account
+		JOIN posting USING (account_id)
+		JOIN journal USING (journal_id)
+		WHERE account.owner_id = $1
+  			AND account.type IN ('user', 'reserved')
+  			AND ($2::timestamp IS NULL OR journal.created_at <= $2::timestamp)
+	`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var at *time.Time
+	if asOf != nil {
+		utc := asOf.UTC()
+		at = &utc
+	}
+
+	err = conn(ctx, r.client).QueryRow(ctx, q, id, at).Scan(&available, &reserved)
 	if err != nil {
-		return nil, err
+		err = PgxErrorLog(err, r.logger)
+		return 0, 0, err
 	}
-	defer func() {
+
+	return available, reserved, nil
+}
+
+func (r *BalanceRepository) ChangeUserBalance(ctx context.Context, b dto.BalanceChangeRequest, depositType model.DepositType) (*dto.BalanceChangeRequest, error) {
+	return inTxWithResult(ctx, &r.TransactionHelper, func(t pgx.Tx) (bm *dto.BalanceChangeRequest, err error) {
+		// сумма запроса перезаписывается итоговым балансом, повтор должен начинаться с исходного запроса
+		b := b
+
+		key, withKey := idempotency.FromContext(ctx)
+		if withKey {
+			var replay dto.BalanceChangeRequest
+			found, err := r.replayIdempotencyKey(ctx, t, key, &replay)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				return &replay, nil
+			}
+		}
+
+		err = r.checkVersion(ctx, t, b.UserID)
 		if err != nil {
-			r.rollbackTransaction(ctx, t)
-		} else {
-			r.commitTransaction(ctx, t)
+			return nil, err
+		}
+
+		b.Amount, err = r.changeUserBalance(ctx, t, b, depositType)
+		if err != nil {
+			return nil, err
 		}
-	}()
 
-	_, err = r.getBalanceByUserID(ctx, t, b.UserID)
+		err = r.saveVersion(ctx, t, b.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		if withKey {
+			err = r.saveIdempotencyKey(ctx, t, key, b)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &b, nil
+	})
+}
+
+func (r *BalanceRepository) TransferMoney(ctx context.Context, transfer dto.TransferRequest) error {
+	return r.inTx(ctx, func(t pgx.Tx) (err error) {
+		key, withKey := idempotency.FromContext(ctx)
+		if withKey {
+			found, err := r.replayIdempotencyKey(ctx, t, key, nil)
+			if err != nil || found {
+				return err
+			}
+		}
+
+		err = r.checkVersion(ctx, t, transfer.UserIDFrom)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.transferMoney(ctx, t, transfer)
+		if err != nil {
+			return err
+		}
+
+		err = r.saveVersion(ctx, t, transfer.UserIDFrom)
+		if err != nil {
+			return err
+		}
+
+		if withKey {
+			err = r.saveIdempotencyKey(ctx, t, key, nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SplitTransfer переводит деньги от одного отправителя нескольким получателям одним журналом,
+// идентификатор которого служит идентификатором группы в истории, и списывает с отправителя комиссию за перевод
+func (r *BalanceRepository) SplitTransfer(ctx context.Context, transfer dto.SplitTransferRequest) (*model.SplitTransfer, error) {
+	return inTxWithResult(ctx, &r.TransactionHelper, func(t pgx.Tx) (st *model.SplitTransfer, err error) {
+		key, withKey := idempotency.FromContext(ctx)
+		if withKey {
+			var replay model.SplitTransfer
+			found, err := r.replayIdempotencyKey(ctx, t, key, &replay)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				return &replay, nil
+			}
+		}
+
+		err = r.checkVersion(ctx, t, transfer.UserIDFrom)
+		if err != nil {
+			return nil, err
+		}
+
+		var total money.Amount
+		postings := make([]model.Posting, 0, len(transfer.Recipients)+1)
+		for _, recipient := range transfer.Recipients {
+			total += recipient.Amount
+			postings = append(postings, model.Posting{
+				Account: model.UserAccountOf(recipient.UserIDTo),
+				Amount:  recipient.Amount,
+				Comment: recipient.Comment,
+			})
+		}
+		err = r.checkLimits(ctx, t, transfer.UserIDFrom, model.TransferOperation, total)
+		if err != nil {
+			return nil, err
+		}
+
+		// списание отправителя идет первым, чтобы нехватка денег обнаруживалась до зачислений
+		postings = append([]model.Posting{{Account: model.UserAccountOf(transfer.UserIDFrom), Amount: -total}}, postings...)
+
+		groupID, balances, err := r.postJournal(ctx, t,
+			model.Journal{Operation: model.SplitTransferOperation, Comment: transfer.Comment},
+			postings...,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// комиссия берется по правилу transfer от общей суммы: с одним получателем - как за обычный перевод
+		feeBalances, err := r.chargeFee(ctx, t, model.Journal{Operation: model.TransferOperation, Comment: transfer.Comment},
+			transfer.UserIDFrom, total)
+		if err != nil {
+			return nil, err
+		}
+		for userID, balance := range feeBalances {
+			balances[userID] = balance
+		}
+
+		st = &model.SplitTransfer{
+			GroupID: groupID,
+			Balance: balances[transfer.UserIDFrom],
+		}
+
+		err = r.saveVersion(ctx, t, transfer.UserIDFrom)
+		if err != nil {
+			return nil, err
+		}
+
+		if withKey {
+			err = r.saveIdempotencyKey(ctx, t, key, st)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return st, nil
+	})
+}
+
+// changeUserBalance пополняет или списывает баланс в рамках транзакции tx и возвращает новый баланс
+func (r *BalanceRepository) changeUserBalance(ctx context.Context, tx pgx.Tx, b dto.BalanceChangeRequest, depositType model.DepositType) (money.Amount, error) {
+	_, err := r.getBalanceByUserID(ctx, tx, b.UserID)
 	if err != nil {
 		if errors.Is(err, apperror.ErrNotFound) && depositType == model.Replenish {
-			err = r.createBalance(ctx, t, b.UserID)
+			err = r.createBalance(ctx, tx, b.UserID)
 			if err != nil {
-				return nil, err
+				return 0, err
 			}
 		} else {
-			return nil, err
+			return 0, err
 		}
 	}
 
-	if depositType == model.Reduce {
-		b.Amount = -b.Amount
+	journal := model.Journal{
+		Operation: model.ReplenishOperation,
+		Comment:   b.Comment,
 	}
+	diff := b.Amount
+	if depositType == model.Reduce {
+		journal.Operation = model.ReduceOperation
+		diff = -diff
 
-	newBalance, err := r.changeBalance(ctx, t, b.UserID, b.Amount)
-	if err != nil {
-		return nil, err
+		err = r.checkLimits(ctx, tx, b.UserID, model.ReduceOperation, b.Amount)
+		if err != nil {
+			return 0, err
+		}
 	}
 
-	// записываем пополнение баланса в таблицу для отображения истории
-	err = r.createHistoryDeposit(ctx, t, b)
+	// деньги приходят из внешней кассы и уходят в нее
+	balances, err := r.post(ctx, tx, journal,
+		model.Posting{Account: model.UserAccountOf(b.UserID), Amount: diff},
+		model.Posting{Account: model.CashAccountOf(), Amount: -diff},
+	)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	b.Amount = newBalance
-	return &b, nil
+	return balances[b.UserID], nil
 }
 
-func (r *BalanceRepository) TransferMoney(ctx context.Context, transfer dto.TransferRequest) (err error) {
-	t, err := r.beginTransaction(ctx)
+// transferMoney переводит деньги в рамках транзакции tx, списывая с отправителя комиссию за перевод,
+// и возвращает новые балансы отправителя и получателя
+func (r *BalanceRepository) transferMoney(ctx context.Context, tx pgx.Tx, transfer dto.TransferRequest) (map[string]money.Amount, error) {
+	err := r.checkLimits(ctx, tx, transfer.UserIDFrom, model.TransferOperation, transfer.Amount)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func() {
-		if err != nil {
-			r.rollbackTransaction(ctx, t)
-		} else {
-			r.commitTransaction(ctx, t)
-		}
-	}()
 
-	_, err = r.changeBalance(ctx, t, transfer.UserIDFrom, -transfer.Amount)
+	journal := model.Journal{Operation: model.TransferOperation, Comment: transfer.Comment}
+	balances, err := r.post(ctx, tx, journal,
+		model.Posting{Account: model.UserAccountOf(transfer.UserIDFrom), Amount: -transfer.Amount},
+		model.Posting{Account: model.UserAccountOf(transfer.UserIDTo), Amount: transfer.Amount},
+	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = r.changeBalance(ctx, t, transfer.UserIDTo, transfer.Amount)
+	feeBalances, err := r.chargeFee(ctx, tx, journal, transfer.UserIDFrom, transfer.Amount)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	err = r.createHistoryTransfer(ctx, t, transfer)
-	if err != nil {
-		return err
+	for userID, balance := range feeBalances {
+		balances[userID] = balance
 	}
 
-	return nil
+	return balances, nil
 }
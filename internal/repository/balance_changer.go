@@ -2,8 +2,13 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/precondition"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
 	"github.com/garet2gis/user_balance_service/pkg/postgresql"
 	"github.com/garet2gis/user_balance_service/pkg/utils"
 	"github.com/jackc/pgx/v5"
@@ -22,18 +27,25 @@ func NewBalanceChanger(c *pgxpool.Pool, l *logging.Logger) *BalanceChanger {
 	}
 }
 
-func (r *BalanceChanger) changeBalance(ctx context.Context, tx pgx.Tx, userID string, diff float64) (float64, error) {
+// changeBalance изменяет доступный остаток пользователя. Замороженный счет принимает только поступления
+// и списания settlement, закрытый счет не принимает ничего
+func (r *BalanceChanger) changeBalance(ctx context.Context, tx pgx.Tx, userID string, diff money.Amount, settlement bool) (money.Amount, error) {
 	q := `
 		UPDATE balance
     	SET balance= balance + $1
    		WHERE user_id = $2
+   			AND (status = 'active' OR (status = 'frozen' AND ($1 > 0 OR $3)))
     	RETURNING balance
 		`
 	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
 
-	var newBalance float64
+	var newBalance money.Amount
+
+	if err := tx.QueryRow(ctx, q, diff, userID, settlement).Scan(&newBalance); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, r.statusError(ctx, tx, userID)
+		}
 
-	if err := tx.QueryRow(ctx, q, diff, userID).Scan(&newBalance); err != nil {
 		err = PgxErrorLog(err, r.logger)
 
 		return 0, err
@@ -41,3 +53,90 @@ func (r *BalanceChanger) changeBalance(ctx context.Context, tx pgx.Tx, userID st
 
 	return newBalance, nil
 }
+
+// statusError объясняет, почему changeBalance не нашел подходящий счет
+func (r *BalanceChanger) statusError(ctx context.Context, tx pgx.Tx, userID string) error {
+	q := `
+		SELECT status::text
+		FROM balance
+		WHERE user_id = $1
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var status model.AccountStatus
+	if err := tx.QueryRow(ctx, q, userID).Scan(&status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+
+		return PgxErrorLog(err, r.logger)
+	}
+
+	switch status {
+	case model.FrozenStatus:
+		return apperror.NewAppError(AccountFrozen, AccountFrozen.Error(), fmt.Sprintf("user_id: %s", userID))
+	case model.ClosedStatus:
+		return apperror.NewAppError(AccountClosed, AccountClosed.Error(), fmt.Sprintf("user_id: %s", userID))
+	}
+
+	return fmt.Errorf("balance of user %s was not changed", userID)
+}
+
+// CheckVersion сравнивает версию баланса с заголовком If-Match запроса до начала операции, например,
+// перед отправкой операции на ручную проверку. Операция проверяет версию еще раз в своей транзакции
+func (r *BalanceChanger) CheckVersion(ctx context.Context, userID string) error {
+	return r.checkVersion(ctx, conn(ctx, r.client), userID)
+}
+
+// checkVersion блокирует баланс пользователя и сравнивает его версию с заголовком If-Match запроса.
+// Если версия не совпала или баланса нет, возвращается *model.VersionMismatch
+func (r *BalanceChanger) checkVersion(ctx context.Context, tx querier, userID string) error {
+	versions, ok := precondition.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	q := `
+		SELECT version, balance
+		FROM balance
+		WHERE user_id = $1
+		FOR UPDATE
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	current := model.VersionMismatch{UserID: userID}
+	if err := tx.QueryRow(ctx, q, userID).Scan(&current.Version, &current.Balance); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &current
+		}
+
+		return PgxErrorLog(err, r.logger)
+	}
+
+	if !versions.Match(current.Version) {
+		return &current
+	}
+
+	return nil
+}
+
+// saveVersion записывает версию баланса пользователя после операции в контекст запроса для заголовка ETag
+func (r *BalanceChanger) saveVersion(ctx context.Context, tx pgx.Tx, userID string) error {
+	version, ok := precondition.ResultFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	q := `
+		SELECT version
+		FROM balance
+		WHERE user_id = $1
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	if err := tx.QueryRow(ctx, q, userID).Scan(version); err != nil {
+		return PgxErrorLog(err, r.logger)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// ExecuteBatch выполняет операции пакета по порядку в одной транзакции, каждую - в своей точке сохранения.
+// Перед выполнением операции вызывается check с контекстом транзакции пакета: ее ошибка считается ошибкой
+// операции, а операция, отправленная на проверку (*model.ReviewRequired), не выполняется и получает статус
+// pending_review. Повтор пакета по ключу идемпотентности возвращает сохраненный результат без вызова check.
+// В режиме atomic первая ошибка отменяет весь пакет вместе с заявками на проверку, в режиме best_effort
+// ошибочная операция откатывается до точки сохранения, а остальные применяются
+func (r *BalanceRepository) ExecuteBatch(ctx context.Context, batch dto.BatchRequest, check func(ctx context.Context, i int, item dto.BatchItem) error) (*model.BatchResult, error) {
+	var res *model.BatchResult
+	err := r.run(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(ctx context.Context, t pgx.Tx) error {
+		key, withKey := idempotency.FromContext(ctx)
+		if withKey {
+			var replay model.BatchResult
+			found, err := r.replayIdempotencyKey(ctx, t, key, &replay)
+			if err != nil {
+				return err
+			}
+			if found {
+				res = &replay
+				return nil
+			}
+		}
+
+		res = &model.BatchResult{
+			Mode:      batch.Mode,
+			Committed: true,
+			Items:     make([]model.BatchItemResult, len(batch.Items)),
+		}
+		for i := range res.Items {
+			res.Items[i] = model.BatchItemResult{Index: i, Status: model.SkippedItem}
+		}
+
+		for i, item := range batch.Items {
+			itemErr := check(ctx, i, item)
+
+			var review *model.ReviewRequired
+			if errors.As(itemErr, &review) {
+				res.Items[i] = model.BatchItemResult{Index: i, Status: model.PendingReviewItem, ReviewID: review.Review.ReviewID}
+				continue
+			}
+
+			if itemErr == nil {
+				itemErr = r.run(ctx, pgx.TxOptions{}, func(ctx context.Context, savepoint pgx.Tx) error {
+					return r.executeBatchItem(ctx, savepoint, item, &res.Items[i])
+				})
+			}
+			if itemErr == nil {
+				res.Items[i].Status = model.AppliedItem
+				continue
+			}
+
+			// ошибки, не связанные с самой операцией, прерывают весь пакет
+			var appErr *apperror.AppError
+			if !errors.As(itemErr, &appErr) {
+				return itemErr
+			}
+
+			res.Items[i] = model.BatchItemResult{Index: i, Status: model.FailedItem, Error: appErr}
+
+			if batch.Mode == model.AtomicBatch {
+				res.Committed = false
+				for j := 0; j < i; j++ {
+					res.Items[j] = model.BatchItemResult{Index: j, Status: model.RolledBackItem}
+				}
+				// пакет откатывается, а результат с ошибками операций возвращается клиенту
+				return errRollback
+			}
+		}
+
+		if withKey {
+			return r.saveIdempotencyKey(ctx, t, key, res)
+		}
+
+		return nil
+	})
+	if errors.Is(err, errRollback) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *BalanceRepository) executeBatchItem(ctx context.Context, tx pgx.Tx, item dto.BatchItem, res *model.BatchItemResult) error {
+	if item.Type == model.TransferOperation {
+		balances, err := r.transferMoney(ctx, tx, dto.TransferRequest{
+			Amount:     item.Amount,
+			UserIDFrom: item.UserID,
+			UserIDTo:   item.UserIDTo,
+			Comment:    item.Comment,
+		})
+		if err != nil {
+			return err
+		}
+
+		from, to := balances[item.UserID], balances[item.UserIDTo]
+		res.Balance, res.BalanceTo = &from, &to
+		return nil
+	}
+
+	balance, err := r.changeUserBalance(ctx, tx, dto.BalanceChangeRequest{
+		Amount:  item.Amount,
+		UserID:  item.UserID,
+		Comment: item.Comment,
+	}, model.DepositType(item.Type))
+	if err != nil {
+		return err
+	}
+
+	res.Balance = &balance
+	return nil
+}
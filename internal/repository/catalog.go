@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/garet2gis/user_balance_service/pkg/postgresql"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ServiceNameTaken = errors.New("service with this name already exists")
+	ServiceInactive  = errors.New("service is inactive")
+	ServiceNotFound  = errors.New("service not found")
+	PriceMismatch    = errors.New("cost does not match service price")
+)
+
+const (
+	serviceColumns      = `service_id::text, name, active, created_at`
+	servicePriceColumns = `price_id::text, service_id::text, COALESCE(price, 0), COALESCE(min_price, 0), COALESCE(max_price, 0),
+		valid_from, valid_to, created_at`
+)
+
+type CatalogRepository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewCatalogRepository(c *pgxpool.Pool, l *logging.Logger) *CatalogRepository {
+	return &CatalogRepository{
+		client: c,
+		logger: l,
+	}
+}
+
+func (r *CatalogRepository) CreateService(ctx context.Context, sc dto.ServiceCreateRequest) (*model.Service, error) {
+	q := `
+		INSERT INTO service (service_id, name)
+		VALUES (COALESCE($1::uuid, gen_random_uuid()), $2)
+		RETURNING ` + serviceColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	s, err := scanService(conn(ctx, r.client).QueryRow(ctx, q, nullUUID(sc.ServiceID), sc.Name))
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *CatalogRepository) RenameService(ctx context.Context, sr dto.ServiceRenameRequest) (*model.Service, error) {
+	q := `
+		UPDATE service
+		SET name = $2
+		WHERE service_id = $1
+		RETURNING ` + serviceColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	return r.updateService(ctx, q, sr.ServiceID, sr.Name)
+}
+
+// SetServiceActive включает или отключает услугу. Отключенная услуга остается в каталоге,
+// чтобы история и отчеты по ней показывали название
+func (r *CatalogRepository) SetServiceActive(ctx context.Context, id string, active bool) (*model.Service, error) {
+	q := `
+		UPDATE service
+		SET active = $2
+		WHERE service_id = $1
+		RETURNING ` + serviceColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	return r.updateService(ctx, q, id, active)
+}
+
+func (r *CatalogRepository) updateService(ctx context.Context, q string, args ...interface{}) (*model.Service, error) {
+	s, err := scanService(conn(ctx, r.client).QueryRow(ctx, q, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.ErrNotFound
+		}
+
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *CatalogRepository) GetServices(ctx context.Context, sl dto.ServiceListRequest) ([]model.Service, error) {
+	qb := sq.Select(serviceColumns).
+		From("service").PlaceholderFormat(sq.Dollar).
+		OrderBy("name")
+
+	if sl.Active != nil {
+		qb = qb.Where(sq.Eq{"active": *sl.Active})
+	}
+
+	if sl.Limit > 0 {
+		qb = qb.Limit(uint64(sl.Limit))
+	}
+
+	if sl.Offset > 0 {
+		qb = qb.Offset(uint64(sl.Offset))
+	}
+
+	q, i, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	rows, err := conn(ctx, r.client).Query(ctx, q, i...)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+	defer rows.Close()
+
+	services := make([]model.Service, 0)
+	for rows.Next() {
+		s, err := scanService(rows)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, *s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
+
+func (r *CatalogRepository) CreateServicePrice(ctx context.Context, p model.ServicePrice) (*model.ServicePrice, error) {
+	q := `
+		INSERT INTO service_price (service_id, price, min_price, max_price, valid_from, valid_to)
+		VALUES ($1, NULLIF($2::decimal, 0), NULLIF($3::decimal, 0), NULLIF($4::decimal, 0),
+		        COALESCE($5, (now() AT TIME ZONE 'utc')), $6)
+		RETURNING ` + servicePriceColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var validFrom interface{}
+	if !p.ValidFrom.IsZero() {
+		validFrom = p.ValidFrom.UTC()
+	}
+
+	var validTo interface{}
+	if p.ValidTo != nil {
+		validTo = p.ValidTo.UTC()
+	}
+
+	created, err := scanServicePrice(conn(ctx, r.client).QueryRow(ctx, q, p.ServiceID, p.Price, p.MinPrice, p.MaxPrice, validFrom, validTo))
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// GetServicePrices возвращает все цены услуги, включая прошедшие и будущие
+func (r *CatalogRepository) GetServicePrices(ctx context.Context, serviceID string) ([]model.ServicePrice, error) {
+	q := `
+		SELECT ` + servicePriceColumns + `
+		FROM service_price
+		WHERE service_id = $1
+		ORDER BY valid_from DESC, created_at DESC
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	rows, err := conn(ctx, r.client).Query(ctx, q, serviceID)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make([]model.ServicePrice, 0)
+	for rows.Next() {
+		p, err := scanServicePrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, *p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+// checkServiceActive проверяет, что на услугу можно создать резерв. Строка услуги блокируется
+// до конца транзакции, чтобы услугу нельзя было отключить одновременно с резервированием
+func checkServiceActive(ctx context.Context, tx pgx.Tx, id string, l *logging.Logger) error {
+	q := `
+		SELECT active
+		FROM service
+		WHERE service_id = $1
+		FOR SHARE
+		`
+	l.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var active bool
+	err := tx.QueryRow(ctx, q, id).Scan(&active)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return toDBError(ServiceNotFound)
+		}
+
+		return PgxErrorLog(err, l)
+	}
+
+	if !active {
+		return toDBError(ServiceInactive)
+	}
+
+	return nil
+}
+
+// checkServicePrice сверяет стоимость резерва с ценой услуги, действующей в момент резервирования.
+// Если для услуги не задано ни одной действующей цены, подходит любая стоимость
+func checkServicePrice(ctx context.Context, tx pgx.Tx, serviceID string, cost money.Amount, l *logging.Logger) error {
+	q := `
+		SELECT ` + servicePriceColumns + `
+		FROM service_price
+		WHERE service_id = $1
+		  AND valid_from <= (now() AT TIME ZONE 'utc')
+		  AND (valid_to IS NULL OR valid_to > (now() AT TIME ZONE 'utc'))
+		ORDER BY valid_from DESC, created_at DESC
+		LIMIT 1
+		`
+	l.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	p, err := scanServicePrice(tx.QueryRow(ctx, q, serviceID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+
+		return PgxErrorLog(err, l)
+	}
+
+	if !p.Allows(cost) {
+		return apperror.NewAppError(PriceMismatch, PriceMismatch.Error(),
+			fmt.Sprintf("cost %s, service %s price %s (price_id: %s)", cost, serviceID, p, p.PriceID))
+	}
+
+	return nil
+}
+
+func scanService(row pgx.Row) (*model.Service, error) {
+	var s model.Service
+	err := row.Scan(&s.ServiceID, &s.Name, &s.Active, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func scanServicePrice(row pgx.Row) (*model.ServicePrice, error) {
+	var p model.ServicePrice
+	err := row.Scan(&p.PriceID, &p.ServiceID, &p.Price, &p.MinPrice, &p.MaxPrice, &p.ValidFrom, &p.ValidTo, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/garet2gis/user_balance_service/pkg/postgresql"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	FeeRuleInProgress = errors.New("fee rule for this operation and service is being changed concurrently")
+)
+
+const feeRuleColumns = `fee_rule_id::text, operation::text, COALESCE(service_id::text, ''), flat, percent::float8, min_fee,
+		COALESCE(max_fee, 0), active, created_at`
+
+type FeeRepository struct {
+	TransactionHelper
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewFeeRepository(c *pgxpool.Pool, l *logging.Logger) *FeeRepository {
+	return &FeeRepository{
+		TransactionHelper: *NewTransactionHelper(c, l),
+		client:            c,
+		logger:            l,
+	}
+}
+
+// CreateFeeRule добавляет правило комиссии, отключая действовавшее до него правило той же операции и услуги
+func (r *FeeRepository) CreateFeeRule(ctx context.Context, f model.FeeRule) (*model.FeeRule, error) {
+	return inTxWithResult(ctx, &r.TransactionHelper, func(t pgx.Tx) (created *model.FeeRule, err error) {
+		q := `
+		UPDATE fee_rule
+		SET active = FALSE
+		WHERE active
+		  AND operation = $1
+		  AND service_id IS NOT DISTINCT FROM $2::uuid
+		`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		_, err = t.Exec(ctx, q, string(f.Operation), nullUUID(f.ServiceID))
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return nil, err
+		}
+
+		q = `
+		INSERT INTO fee_rule (operation, service_id, flat, percent, min_fee, max_fee)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6::decimal, 0))
+		RETURNING ` + feeRuleColumns
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		created, err = scanFeeRule(t.QueryRow(ctx, q, string(f.Operation), nullUUID(f.ServiceID), f.Flat, f.Percent,
+			f.MinFee, f.MaxFee))
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return nil, err
+		}
+
+		return created, nil
+	})
+}
+
+func (r *FeeRepository) GetFeeRules(ctx context.Context, fl dto.FeeRuleListRequest) ([]model.FeeRule, error) {
+	qb := sq.Select(feeRuleColumns).
+		From("fee_rule").PlaceholderFormat(sq.Dollar).
+		OrderBy("operation", "service_id NULLS FIRST", "created_at DESC")
+
+	if fl.Operation != "" {
+		qb = qb.Where(sq.Eq{"operation::text": string(fl.Operation)})
+	}
+
+	if fl.ServiceID != "" {
+		qb = qb.Where(sq.Eq{"service_id": fl.ServiceID})
+	}
+
+	if fl.Active != nil {
+		qb = qb.Where(sq.Eq{"active": *fl.Active})
+	}
+
+	q, i, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	rows, err := conn(ctx, r.client).Query(ctx, q, i...)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]model.FeeRule, 0)
+	for rows.Next() {
+		f, err := scanFeeRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (r *FeeRepository) DeactivateFeeRule(ctx context.Context, id string) (*model.FeeRule, error) {
+	q := `
+		UPDATE fee_rule
+		SET active = FALSE
+		WHERE fee_rule_id = $1
+		RETURNING ` + feeRuleColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	f, err := scanFeeRule(conn(ctx, r.client).QueryRow(ctx, q, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.ErrNotFound
+		}
+
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// chargeFee списывает с пользователя комиссию за операцию j отдельным журналом в рамках транзакции tx
+// и возвращает новые остатки, как post. Правило услуги важнее общего правила операции,
+// без действующего правила комиссия не взимается
+func (r *Ledger) chargeFee(ctx context.Context, tx pgx.Tx, j model.Journal, userID string, amount money.Amount) (map[string]money.Amount, error) {
+	q := `
+		SELECT ` + feeRuleColumns + `
+		FROM fee_rule
+		WHERE active
+		  AND operation = $1
+		  AND (service_id = $2 OR service_id IS NULL)
+		ORDER BY service_id IS NULL
+		LIMIT 1
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	rule, err := scanFeeRule(tx.QueryRow(ctx, q, string(j.Operation), nullUUID(j.ServiceID)))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, PgxErrorLog(err, r.logger)
+	}
+
+	fee, err := rule.Fee(amount)
+	if err != nil {
+		return nil, err
+	}
+	if fee <= 0 {
+		return nil, nil
+	}
+
+	return r.post(ctx, tx,
+		model.Journal{
+			Operation: model.FeeOperation,
+			OrderID:   j.OrderID,
+			ServiceID: j.ServiceID,
+			Comment:   fmt.Sprintf("%s fee", j.Operation),
+		},
+		// комиссия за подтверждение завершает резерв, созданный до заморозки счета, и не должна блокировать расчет
+		model.Posting{Account: model.UserAccountOf(userID), Amount: -fee, Settlement: j.Operation == model.ConfirmOperation},
+		model.Posting{Account: model.FeeAccountOf(), Amount: fee},
+	)
+}
+
+func scanFeeRule(row pgx.Row) (*model.FeeRule, error) {
+	var f model.FeeRule
+	err := row.Scan(&f.FeeRuleID, &f.Operation, &f.ServiceID, &f.Flat, &f.Percent, &f.MinFee, &f.MaxFee, &f.Active,
+		&f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
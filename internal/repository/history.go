@@ -26,7 +26,7 @@ func NewHistoryRepository(c *pgxpool.Pool, l *logging.Logger) *HistoryRepository
 }
 
 func (r *HistoryRepository) GetUserBalanceHistory(ctx context.Context, bh dto.BalanceHistory) ([]model.HistoryRow, error) {
-	qb := sq.Select("order_id, service_name, from_user_id, to_user_id, create_date, amount, transaction_type, comment").
+	qb := sq.Select("order_id, service_name, from_user_id, to_user_id, create_date, amount, transaction_type, comment, group_id").
 		From("balance_history").
 		Where(sq.Eq{"user_id": bh.UserID}).PlaceholderFormat(sq.Dollar).
 		OrderBy(fmt.Sprintf("%s %s", bh.OrderField, bh.OrderBy))
@@ -46,7 +46,7 @@ func (r *HistoryRepository) GetUserBalanceHistory(ctx context.Context, bh dto.Ba
 
 	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
 
-	rows, err := r.client.Query(ctx, q, i...)
+	rows, err := conn(ctx, r.client).Query(ctx, q, i...)
 	if err != nil {
 		err = PgxErrorLog(err, r.logger)
 		return nil, err
@@ -61,8 +61,9 @@ func (r *HistoryRepository) GetUserBalanceHistory(ctx context.Context, bh dto.Ba
 		var orderID pgtype.UUID
 		var UserIDFrom pgtype.UUID
 		var UserIDTo pgtype.UUID
+		var groupID pgtype.UUID
 
-		err = rows.Scan(&orderID, &row.ServiceName, &UserIDFrom, &UserIDTo, &createAt, &row.Amount, &row.TransactionType, &row.Comment)
+		err = rows.Scan(&orderID, &row.ServiceName, &UserIDFrom, &UserIDTo, &createAt, &row.Amount, &row.TransactionType, &row.Comment, &groupID)
 		if err != nil {
 			return nil, err
 		}
@@ -77,6 +78,9 @@ func (r *HistoryRepository) GetUserBalanceHistory(ctx context.Context, bh dto.Ba
 		if UserIDTo.Valid {
 			row.UserIDTo = utils.EncodeUUID(UserIDTo)
 		}
+		if groupID.Valid {
+			row.GroupID = utils.EncodeUUID(groupID)
+		}
 
 		historyRows = append(historyRows, row)
 	}
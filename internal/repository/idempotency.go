@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/postgresql"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+var (
+	IdempotencyKeyReused     = errors.New("idempotency key was already used with a different request")
+	IdempotencyKeyInProgress = errors.New("request with this idempotency key is already in progress")
+)
+
+type IdempotencyRepository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewIdempotencyRepository(c *pgxpool.Pool, l *logging.Logger) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		client: c,
+		logger: l,
+	}
+}
+
+// replayIdempotencyKey ищет сохраненный результат запроса с ключом key.
+// Если ключ найден, ответ записывается в dst (dst может быть nil для операций без тела ответа)
+func (r *IdempotencyRepository) replayIdempotencyKey(ctx context.Context, tx pgx.Tx, key idempotency.Key, dst interface{}) (bool, error) {
+	q := `
+		SELECT request_hash, response
+		FROM idempotency_key
+		WHERE key = $1
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var requestHash string
+	var response []byte
+
+	err := tx.QueryRow(ctx, q, key.Value).Scan(&requestHash, &response)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		err = PgxErrorLog(err, r.logger)
+		return false, err
+	}
+
+	if requestHash != key.RequestHash {
+		return false, apperror.NewAppError(apperror.ErrConflict, IdempotencyKeyReused.Error(), fmt.Sprintf("key: %s", key.Value))
+	}
+
+	if dst != nil && response != nil {
+		if err = json.Unmarshal(response, dst); err != nil {
+			return false, err
+		}
+	}
+
+	r.logger.Infof("replay request with idempotency key %s", key.Value)
+
+	return true, nil
+}
+
+// IdempotencyKeyUsed проверяет, сохранен ли уже результат запроса с ключом key
+func (r *IdempotencyRepository) IdempotencyKeyUsed(ctx context.Context, key idempotency.Key) (bool, error) {
+	q := `
+		SELECT EXISTS(SELECT 1 FROM idempotency_key WHERE key = $1)
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var used bool
+	err := conn(ctx, r.client).QueryRow(ctx, q, key.Value).Scan(&used)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return false, err
+	}
+
+	return used, nil
+}
+
+// saveIdempotencyKey сохраняет результат запроса в той же транзакции, что и сама операция
+func (r *IdempotencyRepository) saveIdempotencyKey(ctx context.Context, tx pgx.Tx, key idempotency.Key, response interface{}) error {
+	q := `
+		INSERT INTO idempotency_key (key, operation, request_hash, response)
+		VALUES ($1, $2, $3, $4)
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var body []byte
+	if response != nil {
+		var err error
+		body, err = json.Marshal(response)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(ctx, q, key.Value, key.Operation, key.RequestHash, body)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys удаляет ключи, созданные раньше чем ttl назад
+func (r *IdempotencyRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, ttl time.Duration) (int64, error) {
+	q := `
+		DELETE
+		FROM idempotency_key
+		WHERE created_at < (now() AT TIME ZONE 'utc') - $1::interval
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	commandTag, err := conn(ctx, r.client).Exec(ctx, q, ttl)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return 0, err
+	}
+
+	return commandTag.RowsAffected(), nil
+}
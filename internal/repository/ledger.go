@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/garet2gis/user_balance_service/pkg/postgresql"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	UnbalancedJournal = errors.New("journal postings do not sum to zero")
+)
+
+type Ledger struct {
+	BalanceChanger
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewLedger(c *pgxpool.Pool, l *logging.Logger) *Ledger {
+	return &Ledger{
+		BalanceChanger: *NewBalanceChanger(c, l),
+		client:         c,
+		logger:         l,
+	}
+}
+
+// accountID возвращает идентификатор счета, создавая его при первом обращении
+func (r *Ledger) accountID(ctx context.Context, tx pgx.Tx, a model.Account) (string, error) {
+	q := `
+		WITH created AS (
+			INSERT INTO account (type, owner_id)
+			VALUES ($1, $2)
+			ON CONFLICT (type, owner_id) DO NOTHING
+			RETURNING account_id
+		)
+		SELECT account_id FROM created
+		UNION ALL
+		SELECT account_id FROM account WHERE type = $1 AND owner_id = $2
+		LIMIT 1
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var id pgtype.UUID
+	if err := tx.QueryRow(ctx, q, a.Type, a.OwnerID).Scan(&id); err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return "", err
+	}
+
+	return utils.EncodeUUID(id), nil
+}
+
+func (r *Ledger) createJournal(ctx context.Context, tx pgx.Tx, j model.Journal) (string, error) {
+	q := `
+		INSERT INTO journal (operation, order_id, service_id, comment)
+		VALUES ($1, $2, $3, $4)
+		RETURNING journal_id
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var id pgtype.UUID
+	err := tx.QueryRow(ctx, q, j.Operation, nullUUID(j.OrderID), nullUUID(j.ServiceID), j.Comment).Scan(&id)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return "", err
+	}
+
+	return utils.EncodeUUID(id), nil
+}
+
+func (r *Ledger) createPosting(ctx context.Context, tx pgx.Tx, journalID, accountID string, p model.Posting) error {
+	q := `
+		INSERT INTO posting (journal_id, account_id, amount, comment)
+		VALUES ($1, $2, $3, $4)
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	_, err := tx.Exec(ctx, q, journalID, accountID, p.Amount, p.Comment)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return err
+	}
+
+	return nil
+}
+
+// post записывает журнал с проводками и обновляет текущий остаток затронутых счетов пользователей.
+// Возвращает новые остатки в разрезе user_id
+func (r *Ledger) post(ctx context.Context, tx pgx.Tx, j model.Journal, postings ...model.Posting) (map[string]money.Amount, error) {
+	_, balances, err := r.postJournal(ctx, tx, j, postings...)
+	return balances, err
+}
+
+// postJournal аналог post, дополнительно возвращающий идентификатор созданного журнала
+func (r *Ledger) postJournal(ctx context.Context, tx pgx.Tx, j model.Journal, postings ...model.Posting) (string, map[string]money.Amount, error) {
+	var sum money.Amount
+	for _, p := range postings {
+		sum += p.Amount
+	}
+	if sum != 0 || len(postings) < 2 {
+		return "", nil, fmt.Errorf("%w: operation %s", UnbalancedJournal, j.Operation)
+	}
+
+	journalID, err := r.createJournal(ctx, tx, j)
+	if err != nil {
+		return "", nil, err
+	}
+
+	balances := make(map[string]money.Amount)
+
+	for _, p := range postings {
+		accountID, err := r.accountID(ctx, tx, p.Account)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if p.Account.Type == model.UserAccount {
+			balances[p.Account.OwnerID], err = r.changeBalance(ctx, tx, p.Account.OwnerID, p.Amount, p.Settlement)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+
+		err = r.createPosting(ctx, tx, journalID, accountID, p)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	return journalID, balances, nil
+}
+
+// nullUUID передает пустой идентификатор в БД как NULL
+func nullUUID(id string) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id
+}
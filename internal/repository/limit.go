@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/garet2gis/user_balance_service/pkg/postgresql"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+var (
+	LimitExceeded = errors.New("operation exceeds user limit")
+)
+
+// limitedJournals журналы, списания по которым расходуют лимит операции. Лимит резервирования считается
+// по резервам, см. limitUsed
+var limitedJournals = map[model.OperationType][]string{
+	model.ReduceOperation:   {string(model.ReduceOperation)},
+	model.TransferOperation: {string(model.TransferOperation), string(model.SplitTransferOperation)},
+}
+
+type LimitRepository struct {
+	TransactionHelper
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewLimitRepository(c *pgxpool.Pool, l *logging.Logger) *LimitRepository {
+	return &LimitRepository{
+		TransactionHelper: *NewTransactionHelper(c, l),
+		client:            c,
+		logger:            l,
+	}
+}
+
+func (r *LimitRepository) SetLimit(ctx context.Context, l model.Limit) error {
+	q := `
+		INSERT INTO spending_limit (user_id, operation, period, amount)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, operation, period) DO UPDATE
+			SET amount     = excluded.amount,
+			    updated_at = (now() AT TIME ZONE 'utc')
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	_, err := conn(ctx, r.client).Exec(ctx, q, l.UserID, string(l.Operation), string(l.Period), l.Amount)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return err
+	}
+
+	return nil
+}
+
+// ResetLimit удаляет лимит пользователя, после чего для него действует общий лимит
+func (r *LimitRepository) ResetLimit(ctx context.Context, l model.Limit) error {
+	q := `
+		DELETE FROM spending_limit
+		WHERE user_id = $1
+		  AND operation = $2
+		  AND period = $3
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	commandTag, err := conn(ctx, r.client).Exec(ctx, q, l.UserID, string(l.Operation), string(l.Period))
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	return nil
+}
+
+// SetDefaultLimits заменяет общие лимиты, действующие для пользователей без собственного лимита
+func (r *LimitRepository) SetDefaultLimits(ctx context.Context, limits []model.Limit) error {
+	return r.inTx(ctx, func(t pgx.Tx) (err error) {
+		q := `
+		DELETE FROM spending_limit
+		WHERE user_id = $1
+		`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		_, err = t.Exec(ctx, q, model.SystemOwnerID)
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return err
+		}
+
+		q = `
+		INSERT INTO spending_limit (user_id, operation, period, amount)
+		VALUES ($1, $2, $3, $4)
+		`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		for _, l := range limits {
+			_, err = t.Exec(ctx, q, model.SystemOwnerID, string(l.Operation), string(l.Period), l.Amount)
+			if err != nil {
+				err = PgxErrorLog(err, r.logger)
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetLimitUsage возвращает действующие лимиты пользователя и их остаток в текущих периодах
+func (r *LimitRepository) GetLimitUsage(ctx context.Context, userID string) ([]model.LimitUsage, error) {
+	now := time.Now().UTC()
+	usage := make([]model.LimitUsage, 0)
+
+	for _, op := range []model.OperationType{model.ReduceOperation, model.TransferOperation, model.ReserveOperation} {
+		limits, err := effectiveLimits(ctx, conn(ctx, r.client), userID, op, r.logger)
+		if err != nil {
+			return nil, err
+		}
+		if len(limits) == 0 {
+			continue
+		}
+
+		used, err := limitUsed(ctx, conn(ctx, r.client), userID, op, now, r.logger)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range limits {
+			l.Used = used[l.Period]
+			l.Remaining = l.Limit - l.Used
+			if l.Remaining < 0 {
+				l.Remaining = 0
+			}
+			if l.Period != model.SingleLimit {
+				resetsAt := l.Period.End(now)
+				l.ResetsAt = &resetsAt
+			}
+			usage = append(usage, l)
+		}
+	}
+
+	return usage, nil
+}
+
+// checkLimits проверяет, что списание amount по операции op укладывается в лимиты пользователя.
+// Счет пользователя блокируется до конца транзакции tx, поэтому параллельные операции
+// не могут одновременно израсходовать один и тот же остаток лимита
+func (r *Ledger) checkLimits(ctx context.Context, tx pgx.Tx, userID string, op model.OperationType, amount money.Amount) error {
+	limits, err := effectiveLimits(ctx, tx, userID, op, r.logger)
+	if err != nil || len(limits) == 0 {
+		return err
+	}
+
+	q := `
+		SELECT 1
+		FROM balance
+		WHERE user_id = $1
+		FOR UPDATE
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var locked int
+	err = tx.QueryRow(ctx, q, userID).Scan(&locked)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return PgxErrorLog(err, r.logger)
+	}
+
+	used, err := limitUsed(ctx, tx, userID, op, time.Now().UTC(), r.logger)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range limits {
+		if used[l.Period]+amount > l.Limit {
+			return apperror.NewAppError(apperror.ErrLimitExceeded, LimitExceeded.Error(),
+				fmt.Sprintf("%s %s limit %s, used %s, requested %s", l.Period, op, l.Limit, used[l.Period], amount))
+		}
+	}
+
+	return nil
+}
+
+// effectiveLimits возвращает лимиты операции op, действующие для пользователя: собственный лимит
+// пользователя либо общий лимит того же периода
+func effectiveLimits(ctx context.Context, q querier, userID string, op model.OperationType, l *logging.Logger) ([]model.LimitUsage, error) {
+	sql := `
+		SELECT DISTINCT ON (period) period::text, amount, user_id = $3
+		FROM spending_limit
+		WHERE user_id IN ($1, $3)
+		  AND operation = $2
+		ORDER BY period, user_id = $3
+		`
+	l.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(sql)))
+
+	rows, err := q.Query(ctx, sql, userID, string(op), model.SystemOwnerID)
+	if err != nil {
+		return nil, PgxErrorLog(err, l)
+	}
+	defer rows.Close()
+
+	limits := make([]model.LimitUsage, 0)
+	for rows.Next() {
+		u := model.LimitUsage{Operation: op}
+		err = rows.Scan(&u.Period, &u.Limit, &u.Default)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+// reserveUsedQuery расход лимита резервирования: сумма резерва в момент резервирования и каждое увеличение суммы
+// в момент изменения. Отмененные и просроченные резервы деньги не потратили и лимит не расходуют
+const reserveUsedQuery = `
+		WITH held AS (SELECT reservation_id, created_at, cost
+		              FROM reservation
+		              WHERE user_id = $1
+		                AND (status IS NULL OR status = 'confirm')),
+		     spent AS (SELECT held.created_at,
+		                      COALESCE((SELECT adjustment.old_cost
+		                                FROM reservation_adjustment adjustment
+		                                WHERE adjustment.reservation_id = held.reservation_id
+		                                ORDER BY adjustment.created_at
+		                                LIMIT 1), held.cost) as amount
+		               FROM held
+		               UNION ALL
+		               SELECT adjustment.created_at, adjustment.new_cost - adjustment.old_cost
+		               FROM reservation_adjustment adjustment
+		               JOIN held USING (reservation_id)
+		               WHERE adjustment.new_cost > adjustment.old_cost)
+		SELECT COALESCE(SUM(amount) FILTER (WHERE created_at >= $2::timestamp), 0),
+		       COALESCE(SUM(amount) FILTER (WHERE created_at >= $3::timestamp), 0),
+		       COALESCE(SUM(amount) FILTER (WHERE created_at >= $4::timestamp), 0)
+		FROM spent
+		WHERE created_at >= LEAST($3::timestamp, $4::timestamp)
+		`
+
+// limitUsed считает, сколько пользователь потратил по операции op в текущих календарных периодах.
+// Для SingleLimit использование всегда нулевое
+func limitUsed(ctx context.Context, q querier, userID string, op model.OperationType, now time.Time, l *logging.Logger) (map[model.LimitPeriod]money.Amount, error) {
+	sql := `
+		SELECT COALESCE(SUM(-posting.amount) FILTER (WHERE journal.created_at >= $3::timestamp), 0),
+		       COALESCE(SUM(-posting.amount) FILTER (WHERE journal.created_at >= $4::timestamp), 0),
+		       COALESCE(SUM(-posting.amount) FILTER (WHERE journal.created_at >= $5::timestamp), 0)
+		FROM posting
+		JOIN account USING (account_id)
+		JOIN journal USING (journal_id)
+		WHERE account.type = 'user'
+		  AND account.owner_id = $1
+		  AND posting.amount < 0
+		  AND journal.operation::text = ANY ($2)
+		  AND journal.created_at >= LEAST($4::timestamp, $5::timestamp)
+		`
+	args := []interface{}{userID, limitedJournals[op]}
+	if op == model.ReserveOperation {
+		sql, args = reserveUsedQuery, []interface{}{userID}
+	}
+	args = append(args, model.DailyLimit.Start(now), model.WeeklyLimit.Start(now), model.MonthlyLimit.Start(now))
+	l.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(sql)))
+
+	var daily, weekly, monthly money.Amount
+	err := q.QueryRow(ctx, sql, args...).Scan(&daily, &weekly, &monthly)
+	if err != nil {
+		return nil, PgxErrorLog(err, l)
+	}
+
+	return map[model.LimitPeriod]money.Amount{
+		model.DailyLimit:   daily,
+		model.WeeklyLimit:  weekly,
+		model.MonthlyLimit: monthly,
+	}, nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReserveOrder резервирует стоимость всех услуг заказа в одной транзакции: при нехватке денег на любую
+// услугу не создается ни один резерв
+func (r *ReservationRepository) ReserveOrder(ctx context.Context, or dto.OrderReservationRequest) (*model.OrderReservation, error) {
+	return inTxWithResult(ctx, &r.TransactionHelper, func(t pgx.Tx) (order *model.OrderReservation, err error) {
+		key, withKey := idempotency.FromContext(ctx)
+		if withKey {
+			var replay model.OrderReservation
+			found, err := r.replayIdempotencyKey(ctx, t, key, &replay)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				return &replay, nil
+			}
+		}
+
+		err = r.checkVersion(ctx, t, or.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		order = &model.OrderReservation{
+			OrderID: or.OrderID,
+			UserID:  or.UserID,
+			Lines:   make([]model.ReservationState, 0, len(or.Lines)),
+		}
+
+		for _, line := range or.Lines {
+			comment := line.Comment
+			if comment == "" {
+				comment = or.Comment
+			}
+
+			state, err := r.reserveMoney(ctx, t, model.Reservation{
+				UserID:    or.UserID,
+				ServiceID: line.ServiceID,
+				OrderID:   or.OrderID,
+				Cost:      line.Cost,
+				Comment:   comment,
+				TTL:       or.TTL,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			order.Total += state.Cost
+			order.Lines = append(order.Lines, *state)
+		}
+
+		err = r.saveVersion(ctx, t, or.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		if withKey {
+			err = r.saveIdempotencyKey(ctx, t, key, order)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return order, nil
+	})
+}
+
+// CommitOrder подтверждает или отменяет все действующие резервы заказа в одной транзакции
+func (r *ReservationRepository) CommitOrder(ctx context.Context, oc dto.OrderCommitRequest, status model.ReservationStatus) error {
+	return r.inTx(ctx, func(t pgx.Tx) (err error) {
+		where := sq.Eq{"order_id": oc.OrderID, "status": nil}
+		if oc.UserID != "" {
+			where["user_id"] = oc.UserID
+		}
+
+		q, i, err := sq.Select(reservationColumns).
+			From("reservation").
+			Where(where).PlaceholderFormat(sq.Dollar).
+			OrderBy("created_at", "reservation_id").
+			Suffix("FOR UPDATE").
+			ToSql()
+		if err != nil {
+			return err
+		}
+
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		rows, err := t.Query(ctx, q, i...)
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return err
+		}
+
+		reservations, err := scanReservations(rows)
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return err
+		}
+
+		if len(reservations) == 0 {
+			return apperror.ErrNotFound
+		}
+
+		checked := make(map[string]bool)
+		for _, rm := range reservations {
+			if checked[rm.UserID] {
+				continue
+			}
+			err = r.checkVersion(ctx, t, rm.UserID)
+			if err != nil {
+				return err
+			}
+			checked[rm.UserID] = true
+		}
+
+		for _, rm := range reservations {
+			err = r.commitReservation(ctx, t, rm, status, 0, oc.Comment)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
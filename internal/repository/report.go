@@ -23,19 +23,27 @@ func NewReportRepository(c *pgxpool.Pool, l *logging.Logger) *ReportRepository {
 }
 
 func (r *ReportRepository) GetReport(ctx context.Context, year int, month int) ([]model.ReportRow, error) {
+	// выручка относится к услуге по счету revenue, комиссия - к услуге журнала комиссии. Комиссии за переводы
+	// не относятся ни к одной услуге и выводятся последней строкой
 	q := `
-		SELECT service.name, SUM(-history_reservation.cost) as "sum"
-		FROM history_reservation
-		JOIN service USING (service_id)
-		WHERE history_reservation.status = 'confirm'
-  			AND EXTRACT(YEAR FROM history_reservation.created_at) = $1
-  			AND EXTRACT(MONTH FROM history_reservation.created_at) = $2
-		GROUP BY service.name;
+		SELECT COALESCE(service.name, $3),
+		       COALESCE(SUM(posting.amount) FILTER (WHERE account.type = 'revenue'), 0) as "sum",
+		       COALESCE(SUM(posting.amount) FILTER (WHERE account.type = 'fee'), 0)     as fee
+		FROM posting
+		JOIN account USING (account_id)
+		JOIN journal USING (journal_id)
+		LEFT JOIN service ON service.service_id = CASE WHEN account.type = 'revenue' THEN account.owner_id
+		                                               ELSE journal.service_id END
+		WHERE (account.type = 'revenue' OR (account.type = 'fee' AND journal.operation = 'fee'))
+  			AND EXTRACT(YEAR FROM journal.created_at) = $1
+  			AND EXTRACT(MONTH FROM journal.created_at) = $2
+		GROUP BY service.service_id, service.name
+		ORDER BY service.name NULLS LAST;
 	`
 
 	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
 
-	rows, err := r.client.Query(ctx, q, year, month)
+	rows, err := conn(ctx, r.client).Query(ctx, q, year, month, model.TransfersReportName)
 	if err != nil {
 		err = PgxErrorLog(err, r.logger)
 		return nil, err
@@ -46,7 +54,7 @@ func (r *ReportRepository) GetReport(ctx context.Context, year int, month int) (
 	for rows.Next() {
 		var row model.ReportRow
 
-		err = rows.Scan(&row.ServiceName, &row.Cost)
+		err = rows.Scan(&row.ServiceName, &row.Cost, &row.Fee)
 
 		if err != nil {
 			return nil, err
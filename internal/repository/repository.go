@@ -11,6 +11,8 @@ import (
 )
 
 type Repository struct {
+	// WithTx объединяет вызовы репозиториев в одну транзакцию
+	TransactionHelper
 	client postgresql.Client
 	logger *logging.Logger
 	ReservationRepository
@@ -18,10 +20,18 @@ type Repository struct {
 	BalanceRepository
 	ReportRepository
 	BalanceChanger
+	IdempotencyRepository
+	AccountRepository
+	ScheduleRepository
+	CatalogRepository
+	FeeRepository
+	LimitRepository
+	ReviewRepository
 }
 
 func NewRepository(c *pgxpool.Pool, l *logging.Logger) *Repository {
 	return &Repository{
+		TransactionHelper:     *NewTransactionHelper(c, l),
 		client:                c,
 		logger:                l,
 		HistoryRepository:     *NewHistoryRepository(c, l),
@@ -29,6 +39,13 @@ func NewRepository(c *pgxpool.Pool, l *logging.Logger) *Repository {
 		ReportRepository:      *NewReportRepository(c, l),
 		ReservationRepository: *NewReservationRepository(c, l),
 		BalanceChanger:        *NewBalanceChanger(c, l),
+		IdempotencyRepository: *NewIdempotencyRepository(c, l),
+		AccountRepository:     *NewAccountRepository(c, l),
+		ScheduleRepository:    *NewScheduleRepository(c, l),
+		CatalogRepository:     *NewCatalogRepository(c, l),
+		FeeRepository:         *NewFeeRepository(c, l),
+		LimitRepository:       *NewLimitRepository(c, l),
+		ReviewRepository:      *NewReviewRepository(c, l),
 	}
 }
 
@@ -36,9 +53,26 @@ func PgxErrorLog(err error, l *logging.Logger) error {
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
 		pgErr = err.(*pgconn.PgError)
+		// конфликт сериализации и взаимная блокировка возвращаются как есть, транзакция будет повторена
+		if pgErr.Code == "40001" || pgErr.Code == "40P01" {
+			return pgErr
+		}
 		if pgErr.Code == "23514" && pgErr.ConstraintName == "balance_balance_check" {
 			return toDBError(NotEnoughMoney)
 		}
+		if pgErr.Code == "23505" && pgErr.ConstraintName == "idempotency_key_pkey" {
+			return apperror.NewAppError(apperror.ErrConflict, IdempotencyKeyInProgress.Error(), pgErr.Detail)
+		}
+		if pgErr.Code == "23505" && pgErr.ConstraintName == "service_name_key" {
+			return apperror.NewAppError(apperror.ErrConflict, ServiceNameTaken.Error(), pgErr.Detail)
+		}
+		if pgErr.Code == "23505" && pgErr.ConstraintName == "uq_fee_rule_active" {
+			return apperror.NewAppError(apperror.ErrConflict, FeeRuleInProgress.Error(), pgErr.Detail)
+		}
+		if pgErr.Code == "23503" && (pgErr.ConstraintName == "service_price_service_id_fkey" ||
+			pgErr.ConstraintName == "fee_rule_service_id_fkey") {
+			return toDBError(ServiceNotFound)
+		}
 		newErr := fmt.Errorf("Code: %s, Message: %s, Where: %s, Detail: %s, SQLState: %s", pgErr.Code, pgErr.Message, pgErr.Where, pgErr.Detail, pgErr.SQLState())
 		l.Error(newErr)
 		return newErr
@@ -2,40 +2,88 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	sq "github.com/Masterminds/squirrel"
 	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
 	"github.com/garet2gis/user_balance_service/internal/model"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
 	"github.com/garet2gis/user_balance_service/pkg/postgresql"
 	"github.com/garet2gis/user_balance_service/pkg/utils"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
+var (
+	AmbiguousReservation     = errors.New("several reservations match order and service, specify reservation_id")
+	ReservationClosed        = errors.New("reservation is already confirmed, cancelled or expired")
+	ReservationExpired       = errors.New("reservation has expired, its money is returned to the user")
+	ReservationNotConfirmed  = errors.New("reservation is not confirmed")
+	RefundExceedsCaptured    = errors.New("refund exceeds captured amount not yet refunded")
+	ReservationCostUnchanged = errors.New("new reservation cost equals current cost")
+	CaptureExceedsCost       = errors.New("capture exceeds reserved cost")
+)
+
+const reservationColumns = `reservation_id::text, user_id::text, order_id::text, service_id::text, cost, captured, refunded, comment,
+		COALESCE(status::text, 'active'), created_at, expires_at, closed_at`
+
 type ReservationRepository struct {
 	TransactionHelper
-	BalanceChanger
+	Ledger
+	IdempotencyRepository
 	client postgresql.Client
 	logger *logging.Logger
 }
 
 func NewReservationRepository(c *pgxpool.Pool, l *logging.Logger) *ReservationRepository {
 	return &ReservationRepository{
-		TransactionHelper: *NewTransactionHelper(c, l),
-		BalanceChanger:    *NewBalanceChanger(c, l),
-		client:            c,
-		logger:            l,
+		TransactionHelper:     *NewTransactionHelper(c, l),
+		Ledger:                *NewLedger(c, l),
+		IdempotencyRepository: *NewIdempotencyRepository(c, l),
+		client:                c,
+		logger:                l,
 	}
 }
 
-func (r *ReservationRepository) createReservation(ctx context.Context, tx pgx.Tx, rm model.Reservation) error {
+// createReservation сохраняет резерв со сроком ttl секунд от текущего момента. Резерв без ttl или с нулевым ttl бессрочный
+func (r *ReservationRepository) createReservation(ctx context.Context, tx pgx.Tx, rm model.Reservation) (*model.ReservationState, error) {
+	var ttl int
+	if rm.TTL != nil {
+		ttl = *rm.TTL
+	}
+
 	q := `
-		INSERT INTO reservation (user_id, order_id, service_id, cost, comment)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO reservation (user_id, order_id, service_id, cost, comment, expires_at)
+		VALUES ($1, $2, $3, $4, $5,
+		        CASE WHEN $6::int > 0 THEN (now() AT TIME ZONE 'utc') + make_interval(secs => $6::int) END)
+		RETURNING ` + reservationColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	state, err := scanReservation(tx.QueryRow(ctx, q, rm.UserID, rm.OrderID, rm.ServiceID, rm.Cost, rm.Comment, ttl))
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// closeReservation сохраняет итоговый статус резерва и подтвержденную сумму
+func (r *ReservationRepository) closeReservation(ctx context.Context, tx pgx.Tx, id string, status model.ReservationStatus, captured money.Amount) error {
+	q := `
+		UPDATE reservation
+		SET status    = $2,
+		    captured  = $3,
+		    closed_at = (now() AT TIME ZONE 'utc')
+		WHERE reservation_id = $1
 		`
 	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
 
-	_, err := tx.Exec(ctx, q, rm.UserID, rm.OrderID, rm.ServiceID, rm.Cost, rm.Comment)
+	_, err := tx.Exec(ctx, q, id, string(status), captured)
 	if err != nil {
 		err = PgxErrorLog(err, r.logger)
 		return err
@@ -44,107 +92,606 @@ func (r *ReservationRepository) createReservation(ctx context.Context, tx pgx.Tx
 	return nil
 }
 
-func (r *ReservationRepository) createCommitReservation(ctx context.Context, tx pgx.Tx, rm model.Reservation, status model.ReservationStatus) error {
+func (r *ReservationRepository) ReserveMoney(ctx context.Context, rm model.Reservation) (*model.ReservationState, error) {
+	return inTxWithResult(ctx, &r.TransactionHelper, func(t pgx.Tx) (state *model.ReservationState, err error) {
+		key, withKey := idempotency.FromContext(ctx)
+		if withKey {
+			var replay model.ReservationState
+			found, err := r.replayIdempotencyKey(ctx, t, key, &replay)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				return &replay, nil
+			}
+		}
+
+		err = r.checkVersion(ctx, t, rm.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		state, err = r.reserveMoney(ctx, t, rm)
+		if err != nil {
+			return nil, err
+		}
+
+		err = r.saveVersion(ctx, t, rm.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		if withKey {
+			err = r.saveIdempotencyKey(ctx, t, key, state)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return state, nil
+	})
+}
+
+// reserveMoney переводит стоимость услуги с доступного остатка пользователя в резерв
+func (r *ReservationRepository) reserveMoney(ctx context.Context, tx pgx.Tx, rm model.Reservation) (*model.ReservationState, error) {
+	err := checkServiceActive(ctx, tx, rm.ServiceID, r.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkServicePrice(ctx, tx, rm.ServiceID, rm.Cost, r.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.checkLimits(ctx, tx, rm.UserID, model.ReserveOperation, rm.Cost)
+	if err != nil {
+		return nil, err
+	}
+
+	journal := reservationJournal(model.ReserveOperation, rm.OrderID, rm.ServiceID, rm.Comment)
+	_, err = r.post(ctx, tx, journal,
+		model.Posting{Account: model.UserAccountOf(rm.UserID), Amount: -rm.Cost},
+		model.Posting{Account: model.ReservedAccountOf(rm.UserID), Amount: rm.Cost},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.chargeFee(ctx, tx, journal, rm.UserID, rm.Cost)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.createReservation(ctx, tx, rm)
+}
+
+func (r *ReservationRepository) CommitReservation(ctx context.Context, rc dto.ReservationCommitRequest, status model.ReservationStatus) error {
+	return r.inTx(ctx, func(t pgx.Tx) (err error) {
+		where := reservationWhere(rc.ReservationRef, model.ActiveReservation)
+		if rc.UserID != "" {
+			where["user_id"] = rc.UserID
+		}
+		if rc.Cost > 0 {
+			where["cost"] = rc.Cost
+		}
+
+		rm, err := r.lockReservation(ctx, t, where, model.ActiveReservation)
+		if err != nil {
+			return err
+		}
+
+		err = r.checkVersion(ctx, t, rm.UserID)
+		if err != nil {
+			return err
+		}
+
+		return r.commitReservation(ctx, t, *rm, status, rc.Capture, rc.Comment)
+	})
+}
+
+// commitReservation закрывает заблокированный действующий резерв. При подтверждении capture задает подтверждаемую
+// сумму (0 - вся сумма резерва), пустой comment заменяется комментарием резерва
+func (r *ReservationRepository) commitReservation(ctx context.Context, tx pgx.Tx, rm model.ReservationState, status model.ReservationStatus, capture money.Amount, comment string) error {
+	// просроченный резерв закрывает только фоновая задача, даже если она до него еще не дошла
+	if status != model.Expired {
+		err := checkNotExpired(rm)
+		if err != nil {
+			return err
+		}
+	}
+
+	if capture > rm.Cost {
+		return toDBError(CaptureExceedsCost)
+	}
+
+	// подтвержденные деньги становятся выручкой услуги, отмененные и просроченные возвращаются на доступный остаток
+	operation, destination, amount := model.ConfirmOperation, model.RevenueAccountOf(rm.ServiceID), rm.Cost
+	switch status {
+	case model.Confirm:
+		if capture > 0 {
+			amount = capture
+		}
+	case model.Cancel:
+		operation, destination = model.CancelOperation, model.UserAccountOf(rm.UserID)
+	case model.Expired:
+		operation, destination = model.ExpireOperation, model.UserAccountOf(rm.UserID)
+	}
+
+	var captured money.Amount
+	if status == model.Confirm {
+		captured = amount
+	}
+
+	err := r.closeReservation(ctx, tx, rm.ReservationID, status, captured)
+	if err != nil {
+		return err
+	}
+
+	if comment == "" {
+		comment = rm.Comment
+	}
+
+	journal := reservationJournal(operation, rm.OrderID, rm.ServiceID, comment)
+	_, err = r.post(ctx, tx, journal,
+		model.Posting{Account: model.ReservedAccountOf(rm.UserID), Amount: -amount},
+		model.Posting{Account: destination, Amount: amount},
+	)
+	if err != nil {
+		return err
+	}
+
+	// комиссия за подтверждение считается от подтвержденной суммы
+	if status == model.Confirm {
+		_, err = r.chargeFee(ctx, tx, journal, rm.UserID, amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	// неподтвержденная часть резерва возвращается отдельным журналом, чтобы в истории были видны обе части
+	if released := rm.Cost - amount; released > 0 {
+		_, err = r.post(ctx, tx, reservationJournal(model.ReleaseOperation, rm.OrderID, rm.ServiceID, comment),
+			model.Posting{Account: model.ReservedAccountOf(rm.UserID), Amount: -released},
+			model.Posting{Account: model.UserAccountOf(rm.UserID), Amount: released},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ReservationRepository) GetReservation(ctx context.Context, id string) (*model.ReservationState, error) {
 	q := `
-		INSERT INTO history_reservation (user_id, order_id, service_id, cost, status, comment)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		SELECT ` + reservationColumns + `
+		FROM reservation
+		WHERE reservation_id = $1
 		`
-
 	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
 
-	_, err := tx.Exec(ctx, q, rm.UserID, rm.OrderID, rm.ServiceID, rm.Cost, status, rm.Comment)
+	state, err := scanReservation(conn(ctx, r.client).QueryRow(ctx, q, id))
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.ErrNotFound
+		}
+
 		err = PgxErrorLog(err, r.logger)
-		return err
+		return nil, err
 	}
 
-	return nil
+	return state, nil
 }
 
-func (r *ReservationRepository) deleteReservation(ctx context.Context, tx pgx.Tx, rm model.Reservation) error {
+// GetReservedAmount возвращает сумму действующих резервов пользователя
+func (r *ReservationRepository) GetReservedAmount(ctx context.Context, userID string) (money.Amount, error) {
 	q := `
-		DELETE
+		SELECT COALESCE(SUM(cost), 0)
 		FROM reservation
 		WHERE user_id = $1
-  		AND order_id = $2
-  		AND service_id = $3
-  		AND cost = $4
+		  AND status IS NULL
 		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var reserved money.Amount
+	err := conn(ctx, r.client).QueryRow(ctx, q, userID).Scan(&reserved)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return 0, err
+	}
+
+	return reserved, nil
+}
+
+// GetOpenReservations возвращает страницу действующих резервов, самые старые первыми
+func (r *ReservationRepository) GetOpenReservations(ctx context.Context, rl dto.ReservationListRequest) (*model.ReservationList, error) {
+	where := sq.And{sq.Eq{"status": nil}}
+	if rl.UserID != "" {
+		where = append(where, sq.Eq{"user_id": rl.UserID})
+	}
+	if rl.OrderID != "" {
+		where = append(where, sq.Eq{"order_id": rl.OrderID})
+	}
+	if rl.ServiceID != "" {
+		where = append(where, sq.Eq{"service_id": rl.ServiceID})
+	}
+	if rl.OlderThan > 0 {
+		where = append(where, sq.Expr("created_at <= (now() AT TIME ZONE 'utc') - make_interval(secs => ?::int)", rl.OlderThan))
+	}
+
+	q, i, err := sq.Select("COUNT(*)", "COALESCE(SUM(cost), 0)").
+		From("reservation").
+		Where(where).PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
 
 	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
 
-	commandTag, err := tx.Exec(ctx, q, rm.UserID, rm.OrderID, rm.ServiceID, rm.Cost)
+	list := &model.ReservationList{Reservations: make([]model.OpenReservation, 0)}
+	err = conn(ctx, r.client).QueryRow(ctx, q, i...).Scan(&list.Total, &list.Held)
 	if err != nil {
 		err = PgxErrorLog(err, r.logger)
-		return err
+		return nil, err
+	}
+
+	qb := sq.Select(reservationColumns,
+		`(SELECT SUM(held.cost) FROM reservation held WHERE held.user_id = reservation.user_id AND held.status IS NULL)`).
+		From("reservation").
+		Where(where).PlaceholderFormat(sq.Dollar).
+		OrderBy("created_at", "reservation_id")
+
+	if rl.Limit > 0 {
+		qb = qb.Limit(uint64(rl.Limit))
 	}
 
-	if commandTag.RowsAffected() != 1 {
-		return apperror.ErrNotFound
+	if rl.Offset > 0 {
+		qb = qb.Offset(uint64(rl.Offset))
 	}
 
-	return nil
-}
+	q, i, err = qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
 
-func (r *ReservationRepository) ReserveMoney(ctx context.Context, rm model.Reservation) (err error) {
-	t, err := r.beginTransaction(ctx)
+	rows, err := conn(ctx, r.client).Query(ctx, q, i...)
 	if err != nil {
-		return err
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
 	}
-	defer func() {
+	defer rows.Close()
+
+	for rows.Next() {
+		var rm model.OpenReservation
+		err = rows.Scan(&rm.ReservationID, &rm.UserID, &rm.OrderID, &rm.ServiceID, &rm.Cost, &rm.Captured, &rm.Refunded, &rm.Comment,
+			&rm.Status, &rm.CreatedAt, &rm.ExpiresAt, &rm.ClosedAt, &rm.UserHeld)
 		if err != nil {
-			r.rollbackTransaction(ctx, t)
-		} else {
-			r.commitTransaction(ctx, t)
+			return nil, err
 		}
-	}()
+		list.Reservations = append(list.Reservations, rm)
+	}
 
-	_, err = r.changeBalance(ctx, t, rm.UserID, -rm.Cost)
-	if err != nil {
-		return err
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
-	err = r.createReservation(ctx, t, rm)
+	return list, nil
+}
+
+// lockReservation блокирует единственный резерв со статусом status, подходящий под условие where. Если условию
+// соответствует несколько резервов, возвращается конфликт: такой резерв нужно указать по reservation_id
+func (r *ReservationRepository) lockReservation(ctx context.Context, tx pgx.Tx, where sq.Eq, status model.ReservationStatus) (*model.ReservationState, error) {
+	q, i, err := sq.Select(reservationColumns).
+		From("reservation").
+		Where(where).PlaceholderFormat(sq.Dollar).
+		Limit(2).
+		Suffix("FOR UPDATE").
+		ToSql()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
-}
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
 
-func (r *ReservationRepository) CommitReservation(ctx context.Context, rm model.Reservation, status model.ReservationStatus) (err error) {
-	t, err := r.beginTransaction(ctx)
+	rows, err := tx.Query(ctx, q, i...)
 	if err != nil {
-		return err
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	reservations, err := scanReservations(rows)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	switch {
+	case len(reservations) == 0:
+		return nil, apperror.ErrNotFound
+	case len(reservations) > 1:
+		return nil, apperror.NewAppError(apperror.ErrConflict, AmbiguousReservation.Error(),
+			fmt.Sprintf("order_id: %s, service_id: %s", reservations[0].OrderID, reservations[0].ServiceID))
+	}
+
+	rm := reservations[0]
+	if rm.Status != status {
+		statusErr := ReservationClosed
+		if status == model.Confirm {
+			statusErr = ReservationNotConfirmed
+		}
+		return nil, apperror.NewAppError(apperror.ErrConflict, statusErr.Error(),
+			fmt.Sprintf("reservation_id: %s, status: %s", rm.ReservationID, rm.Status))
 	}
-	defer func() {
+
+	return &rm, nil
+}
+
+// RefundReservation возвращает пользователю часть подтвержденной суммы резерва или весь ее невозвращенный остаток,
+// если сумма возврата не задана. Возврат списывается с выручки услуги и уменьшает ее в отчете за месяц возврата
+func (r *ReservationRepository) RefundReservation(ctx context.Context, rr dto.RefundRequest) (*model.ReservationState, error) {
+	return inTxWithResult(ctx, &r.TransactionHelper, func(t pgx.Tx) (state *model.ReservationState, err error) {
+		key, withKey := idempotency.FromContext(ctx)
+		if withKey {
+			var replay model.ReservationState
+			found, err := r.replayIdempotencyKey(ctx, t, key, &replay)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				return &replay, nil
+			}
+		}
+
+		rm, err := r.lockReservation(ctx, t, reservationWhere(rr.ReservationRef, model.Confirm), model.Confirm)
+		if err != nil {
+			return nil, err
+		}
+
+		err = r.checkVersion(ctx, t, rm.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		amount := rr.Amount
+		if amount == 0 {
+			amount = rm.Captured - rm.Refunded
+		}
+		if amount <= 0 || rm.Refunded+amount > rm.Captured {
+			return nil, toDBError(RefundExceedsCaptured)
+		}
+
+		_, err = r.post(ctx, t, reservationJournal(model.RefundOperation, rm.OrderID, rm.ServiceID, rr.Comment),
+			model.Posting{Account: model.RevenueAccountOf(rm.ServiceID), Amount: -amount},
+			model.Posting{Account: model.UserAccountOf(rm.UserID), Amount: amount},
+		)
 		if err != nil {
-			r.rollbackTransaction(ctx, t)
-		} else {
-			r.commitTransaction(ctx, t)
+			return nil, err
 		}
-	}()
 
-	err = r.deleteReservation(ctx, t, rm)
+		q := `
+		UPDATE reservation
+		SET refunded = refunded + $2
+		WHERE reservation_id = $1
+		RETURNING ` + reservationColumns
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		state, err = scanReservation(t.QueryRow(ctx, q, rm.ReservationID, amount))
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return nil, err
+		}
+
+		if withKey {
+			err = r.saveIdempotencyKey(ctx, t, key, state)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return state, nil
+	})
+}
+
+// AdjustReservation меняет сумму действующего резерва: разница списывается с доступного остатка
+// или возвращается на него, а изменение сохраняется в журнале изменений резерва. Новая сумма проверяется
+// по статусу и цене услуги так же, как при резервировании
+func (r *ReservationRepository) AdjustReservation(ctx context.Context, ra dto.ReservationAdjustRequest) (*model.ReservationAdjustment, error) {
+	return inTxWithResult(ctx, &r.TransactionHelper, func(t pgx.Tx) (adjustment *model.ReservationAdjustment, err error) {
+		key, withKey := idempotency.FromContext(ctx)
+		if withKey {
+			var replay model.ReservationAdjustment
+			found, err := r.replayIdempotencyKey(ctx, t, key, &replay)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				return &replay, nil
+			}
+		}
+
+		rm, err := r.lockReservation(ctx, t, reservationWhere(ra.ReservationRef, model.ActiveReservation), model.ActiveReservation)
+		if err != nil {
+			return nil, err
+		}
+		id := rm.ReservationID
+
+		err = r.checkVersion(ctx, t, rm.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = checkNotExpired(*rm)
+		if err != nil {
+			return nil, err
+		}
+
+		diff := ra.Cost - rm.Cost
+		if diff == 0 {
+			return nil, toDBError(ReservationCostUnchanged)
+		}
+
+		// новая сумма резерва проверяется так же, как сумма нового резерва
+		err = checkServiceActive(ctx, t, rm.ServiceID, r.logger)
+		if err != nil {
+			return nil, err
+		}
+
+		err = checkServicePrice(ctx, t, rm.ServiceID, ra.Cost, r.logger)
+		if err != nil {
+			return nil, err
+		}
+
+		// увеличение резерва расходует лимит резервирования так же, как новый резерв
+		if diff > 0 {
+			err = r.checkLimits(ctx, t, rm.UserID, model.ReserveOperation, diff)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		_, err = r.post(ctx, t, reservationJournal(model.AdjustOperation, rm.OrderID, rm.ServiceID, ra.Comment),
+			model.Posting{Account: model.UserAccountOf(rm.UserID), Amount: -diff},
+			model.Posting{Account: model.ReservedAccountOf(rm.UserID), Amount: diff},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		q := `
+		UPDATE reservation
+		SET cost = $2
+		WHERE reservation_id = $1
+		`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		_, err = t.Exec(ctx, q, id, ra.Cost)
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return nil, err
+		}
+
+		q = `
+		INSERT INTO reservation_adjustment (reservation_id, user_id, order_id, service_id, old_cost, new_cost, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING adjustment_id::text, created_at
+		`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		adjustment = &model.ReservationAdjustment{
+			ReservationID: id,
+			UserID:        rm.UserID,
+			OrderID:       rm.OrderID,
+			ServiceID:     rm.ServiceID,
+			OldCost:       rm.Cost,
+			NewCost:       ra.Cost,
+			Comment:       ra.Comment,
+		}
+		err = t.QueryRow(ctx, q, id, rm.UserID, rm.OrderID, rm.ServiceID, rm.Cost, ra.Cost, ra.Comment).
+			Scan(&adjustment.AdjustmentID, &adjustment.CreatedAt)
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return nil, err
+		}
+
+		if withKey {
+			err = r.saveIdempotencyKey(ctx, t, key, adjustment)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return adjustment, nil
+	})
+}
+
+// GetExpiredReservations возвращает до limit действующих резервов, срок которых истек. Бессрочные резервы не возвращаются
+func (r *ReservationRepository) GetExpiredReservations(ctx context.Context, limit int) ([]model.ReservationState, error) {
+	q := `
+		SELECT ` + reservationColumns + `
+		FROM reservation
+		WHERE status IS NULL
+		  AND expires_at <= (now() AT TIME ZONE 'utc')
+		ORDER BY created_at
+		LIMIT $1
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	rows, err := conn(ctx, r.client).Query(ctx, q, limit)
 	if err != nil {
-		return err
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
 	}
 
-	if status == model.Confirm {
-		rm.Cost = -rm.Cost
+	return scanReservations(rows)
+}
+
+// checkNotExpired возвращает конфликт для резерва, срок которого истек
+func checkNotExpired(rm model.ReservationState) error {
+	if rm.IsExpired(time.Now().UTC()) {
+		return apperror.NewAppError(apperror.ErrConflict, ReservationExpired.Error(),
+			fmt.Sprintf("reservation_id: %s, expires_at: %s", rm.ReservationID, rm.ExpiresAt.Format(time.RFC3339)))
 	}
+	return nil
+}
 
-	err = r.createCommitReservation(ctx, t, rm, status)
+// reservationWhere условие поиска резерва: по идентификатору либо среди резервов заказа на услугу со статусом status
+func reservationWhere(ref dto.ReservationRef, status model.ReservationStatus) sq.Eq {
+	where := sq.Eq{}
+	switch {
+	case ref.ReservationID != "":
+		where["reservation_id"] = ref.ReservationID
+	case status == model.ActiveReservation:
+		where["status"] = nil
+	default:
+		where["status::text"] = string(status)
+	}
+	if ref.OrderID != "" {
+		where["order_id"] = ref.OrderID
+	}
+	if ref.ServiceID != "" {
+		where["service_id"] = ref.ServiceID
+	}
+	return where
+}
+
+func reservationJournal(operation model.OperationType, orderID, serviceID, comment string) model.Journal {
+	return model.Journal{
+		Operation: operation,
+		OrderID:   orderID,
+		ServiceID: serviceID,
+		Comment:   comment,
+	}
+}
+
+func scanReservation(row pgx.Row) (*model.ReservationState, error) {
+	var rm model.ReservationState
+	err := row.Scan(&rm.ReservationID, &rm.UserID, &rm.OrderID, &rm.ServiceID, &rm.Cost, &rm.Captured, &rm.Refunded, &rm.Comment,
+		&rm.Status, &rm.CreatedAt, &rm.ExpiresAt, &rm.ClosedAt)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return &rm, nil
+}
 
-	if status == model.Cancel {
-		_, err = r.changeBalance(ctx, t, rm.UserID, rm.Cost)
+func scanReservations(rows pgx.Rows) ([]model.ReservationState, error) {
+	defer rows.Close()
+
+	reservations := make([]model.ReservationState, 0)
+	for rows.Next() {
+		rm, err := scanReservation(rows)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		reservations = append(reservations, *rm)
 	}
 
-	return nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
 }
@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/postgresql"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+var (
+	InvalidReviewTransition = errors.New("invalid review status transition")
+)
+
+const reviewColumns = `review_id::text, operation::text, user_id::text, COALESCE(user_id_to::text, ''), amount, request,
+		score, reasons, status::text, COALESCE(idempotency_key, ''), COALESCE(request_hash, ''), comment, error,
+		created_at, decided_at`
+
+type ReviewRepository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewReviewRepository(c *pgxpool.Pool, l *logging.Logger) *ReviewRepository {
+	return &ReviewRepository{
+		client: c,
+		logger: l,
+	}
+}
+
+// GetRiskSignals собирает по журналу проводок активность пользователя начиная с since.
+// recipients - получатели текущего перевода, пустые для остальных операций
+func (r *ReviewRepository) GetRiskSignals(ctx context.Context, userID string, recipients []string, since time.Time) (*model.RiskSignals, error) {
+	q := `
+		WITH own AS (
+			SELECT journal.journal_id, journal.operation::text AS operation, journal.created_at, posting.amount
+			FROM posting
+			         JOIN account USING (account_id)
+			         JOIN journal USING (journal_id)
+			WHERE account.type = 'user'
+			  AND account.owner_id = $1
+		),
+		     -- первый перевод каждому получателю
+		     recipients AS (
+		         SELECT recipient.owner_id, MIN(own.created_at) AS first_at
+		         FROM own
+		                  JOIN posting other ON other.journal_id = own.journal_id
+		                  JOIN account recipient ON recipient.account_id = other.account_id
+		         WHERE own.amount < 0
+		           AND own.operation IN ('transfer', 'split_transfer')
+		           AND other.amount > 0
+		           AND recipient.type = 'user'
+		           AND recipient.owner_id <> $1
+		         GROUP BY recipient.owner_id
+		     )
+		SELECT (SELECT COUNT(*) FROM recipients WHERE first_at >= $2::timestamp) +
+		       (SELECT COUNT(DISTINCT recipient_id)
+		        FROM unnest($3::text[]) AS recipient_id
+		        WHERE NOT EXISTS(SELECT 1 FROM recipients WHERE owner_id = recipient_id::uuid)),
+		       (SELECT COUNT(DISTINCT journal_id)
+		        FROM own
+		        WHERE amount < 0
+		          AND created_at >= $2::timestamp
+		          AND operation IN ('reduce', 'transfer', 'split_transfer', 'reserve')),
+		       (SELECT COALESCE(SUM(amount), 0)
+		        FROM own
+		        WHERE amount > 0
+		          AND created_at >= $2::timestamp
+		          AND operation = 'replenish')
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var s model.RiskSignals
+	err := conn(ctx, r.client).QueryRow(ctx, q, userID, since.UTC(), recipients).Scan(&s.NewRecipients, &s.Outgoing, &s.Replenished)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// CreateReview сохраняет заявку на проверку. Повтор запроса с тем же ключом идемпотентности
+// возвращает уже созданную заявку
+func (r *ReviewRepository) CreateReview(ctx context.Context, rv model.Review) (*model.Review, error) {
+	q := `
+		INSERT INTO operation_review (operation, user_id, user_id_to, amount, request, score, reasons,
+		                              idempotency_key, request_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''))
+		ON CONFLICT (idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING
+		RETURNING ` + reviewColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	created, err := scanReview(conn(ctx, r.client).QueryRow(ctx, q, string(rv.Operation), rv.UserID, nullUUID(rv.UserIDTo),
+		rv.Amount, rv.Request, rv.Score, rv.Reasons, rv.IdempotencyKey, rv.RequestHash))
+	if err == nil {
+		return created, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	q = `
+		SELECT ` + reviewColumns + `
+		FROM operation_review
+		WHERE idempotency_key = $1
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	existing, err := scanReview(conn(ctx, r.client).QueryRow(ctx, q, rv.IdempotencyKey))
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	if existing.RequestHash != rv.RequestHash {
+		return nil, apperror.NewAppError(apperror.ErrConflict, IdempotencyKeyReused.Error(), fmt.Sprintf("key: %s", rv.IdempotencyKey))
+	}
+
+	return existing, nil
+}
+
+func (r *ReviewRepository) GetReview(ctx context.Context, id string) (*model.Review, error) {
+	q := `
+		SELECT ` + reviewColumns + `
+		FROM operation_review
+		WHERE review_id = $1
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	rv, err := scanReview(conn(ctx, r.client).QueryRow(ctx, q, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.ErrNotFound
+		}
+
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+func (r *ReviewRepository) GetReviews(ctx context.Context, rl dto.ReviewListRequest) ([]model.Review, error) {
+	qb := sq.Select(reviewColumns).
+		From("operation_review").PlaceholderFormat(sq.Dollar).
+		OrderBy("created_at")
+
+	if rl.Status != "" {
+		qb = qb.Where(sq.Eq{"status::text": string(rl.Status)})
+	}
+
+	if rl.UserID != "" {
+		qb = qb.Where(sq.Eq{"user_id": rl.UserID})
+	}
+
+	if rl.Limit > 0 {
+		qb = qb.Limit(uint64(rl.Limit))
+	}
+
+	if rl.Offset > 0 {
+		qb = qb.Offset(uint64(rl.Offset))
+	}
+
+	q, i, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	rows, err := conn(ctx, r.client).Query(ctx, q, i...)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := make([]model.Review, 0)
+	for rows.Next() {
+		rv, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, *rv)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+// ChangeReviewStatus переводит заявку в status, если ее текущий статус - один из from.
+// Комментарий администратора сохраняется, только если он передан
+func (r *ReviewRepository) ChangeReviewStatus(ctx context.Context, id string, status model.ReviewStatus, comment, reviewErr string, from ...model.ReviewStatus) (*model.Review, error) {
+	q := `
+		UPDATE operation_review
+		SET status     = $2,
+		    comment    = COALESCE(NULLIF($3, ''), comment),
+		    error      = $4,
+		    decided_at = now() AT TIME ZONE 'utc'
+		WHERE review_id = $1
+		  AND status::text = ANY ($5)
+		RETURNING ` + reviewColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	allowed := make([]string, 0, len(from))
+	for _, s := range from {
+		allowed = append(allowed, string(s))
+	}
+
+	rv, err := scanReview(conn(ctx, r.client).QueryRow(ctx, q, id, string(status), comment, reviewErr, allowed))
+	if err == nil {
+		return rv, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	current, err := r.GetReview(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, apperror.NewAppError(apperror.ErrConflict, InvalidReviewTransition.Error(),
+		fmt.Sprintf("%s -> %s", current.Status, status))
+}
+
+func scanReview(row pgx.Row) (*model.Review, error) {
+	var rv model.Review
+	err := row.Scan(&rv.ReviewID, &rv.Operation, &rv.UserID, &rv.UserIDTo, &rv.Amount, &rv.Request, &rv.Score,
+		&rv.Reasons, &rv.Status, &rv.IdempotencyKey, &rv.RequestHash, &rv.Comment, &rv.Error, &rv.CreatedAt, &rv.DecidedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rv, nil
+}
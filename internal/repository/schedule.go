@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/postgresql"
+	"github.com/garet2gis/user_balance_service/pkg/utils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+var (
+	InvalidScheduleTransition = errors.New("invalid schedule status transition")
+	ScheduleRunFinished       = errors.New("schedule run was already finished by another instance")
+)
+
+const scheduleColumns = `schedule_id::text, operation::text, user_id::text, COALESCE(user_id_to::text, ''), amount, comment,
+		recurrence::text, COALESCE(day_of_month, 0), status::text, due_at, next_run_at, attempt, max_retries, created_at`
+
+type ScheduleRepository struct {
+	TransactionHelper
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func NewScheduleRepository(c *pgxpool.Pool, l *logging.Logger) *ScheduleRepository {
+	return &ScheduleRepository{
+		TransactionHelper: *NewTransactionHelper(c, l),
+		client:            c,
+		logger:            l,
+	}
+}
+
+func (r *ScheduleRepository) CreateSchedule(ctx context.Context, s model.Schedule) (*model.Schedule, error) {
+	q := `
+		INSERT INTO schedule (operation, user_id, user_id_to, amount, comment, recurrence, day_of_month, due_at,
+		                      next_run_at, max_retries)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $9)
+		RETURNING ` + scheduleColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var dayOfMonth interface{}
+	if s.DayOfMonth > 0 {
+		dayOfMonth = s.DayOfMonth
+	}
+
+	row := conn(ctx, r.client).QueryRow(ctx, q, string(s.Operation), s.UserID, nullUUID(s.UserIDTo), s.Amount, s.Comment,
+		string(s.Recurrence), dayOfMonth, s.DueAt.UTC(), s.MaxRetries)
+
+	created, err := scanSchedule(row)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (r *ScheduleRepository) GetSchedules(ctx context.Context, sl dto.ScheduleListRequest) ([]model.Schedule, error) {
+	qb := sq.Select(scheduleColumns).
+		From("schedule").
+		Where(sq.Eq{"user_id": sl.UserID}).PlaceholderFormat(sq.Dollar).
+		OrderBy("created_at DESC")
+
+	if sl.Status != "" {
+		qb = qb.Where(sq.Eq{"status::text": string(sl.Status)})
+	}
+
+	if sl.Limit > 0 {
+		qb = qb.Limit(uint64(sl.Limit))
+	}
+
+	if sl.Offset > 0 {
+		qb = qb.Offset(uint64(sl.Offset))
+	}
+
+	q, i, err := qb.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	rows, err := conn(ctx, r.client).Query(ctx, q, i...)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return scanSchedules(rows)
+}
+
+// ChangeScheduleStatus переводит расписание в статус status, если его текущий статус входит в from
+func (r *ScheduleRepository) ChangeScheduleStatus(ctx context.Context, id string, status model.ScheduleStatus, from ...model.ScheduleStatus) (*model.Schedule, error) {
+	q := `
+		UPDATE schedule
+		SET status = $2
+		WHERE schedule_id = $1
+		  AND status::text = ANY ($3)
+		RETURNING ` + scheduleColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	allowed := make([]string, 0, len(from))
+	for _, s := range from {
+		allowed = append(allowed, string(s))
+	}
+
+	s, err := scanSchedule(conn(ctx, r.client).QueryRow(ctx, q, id, string(status), allowed))
+	if err == nil {
+		return s, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	q = `
+		SELECT status::text
+		FROM schedule
+		WHERE schedule_id = $1
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	var current model.ScheduleStatus
+	err = conn(ctx, r.client).QueryRow(ctx, q, id).Scan(&current)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.ErrNotFound
+		}
+
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return nil, apperror.NewAppError(apperror.ErrConflict, InvalidScheduleTransition.Error(),
+		fmt.Sprintf("%s -> %s", current, status))
+}
+
+func (r *ScheduleRepository) GetScheduleRuns(ctx context.Context, id string) ([]model.ScheduleRun, error) {
+	q := `
+		SELECT schedule_id::text, due_at, attempt, status::text, error, COALESCE(review_id::text, ''), executed_at
+		FROM schedule_run
+		WHERE schedule_id = $1
+		ORDER BY executed_at DESC
+		`
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	rows, err := conn(ctx, r.client).Query(ctx, q, id)
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]model.ScheduleRun, 0)
+	for rows.Next() {
+		var run model.ScheduleRun
+		err = rows.Scan(&run.ScheduleID, &run.DueAt, &run.Attempt, &run.Status, &run.Error, &run.ReviewID, &run.ExecutedAt)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// ClaimDueSchedules забирает в работу до limit активных расписаний, время выполнения которых наступило.
+// Строки, заблокированные другими экземплярами сервиса, пропускаются (SKIP LOCKED), а взятое расписание
+// не выдается повторно до истечения lease
+func (r *ScheduleRepository) ClaimDueSchedules(ctx context.Context, limit int, lease time.Duration) ([]model.Schedule, error) {
+	q := `
+		UPDATE schedule
+		SET locked_until = (now() AT TIME ZONE 'utc') + make_interval(secs => $2)
+		WHERE schedule_id IN (SELECT schedule_id
+		                      FROM schedule
+		                      WHERE status = 'active'
+		                        AND next_run_at <= (now() AT TIME ZONE 'utc')
+		                        AND (locked_until IS NULL OR locked_until < (now() AT TIME ZONE 'utc'))
+		                      ORDER BY next_run_at
+		                      LIMIT $1 FOR UPDATE SKIP LOCKED)
+		RETURNING ` + scheduleColumns
+	r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+	rows, err := conn(ctx, r.client).Query(ctx, q, limit, lease.Seconds())
+	if err != nil {
+		err = PgxErrorLog(err, r.logger)
+		return nil, err
+	}
+
+	return scanSchedules(rows)
+}
+
+// FinishScheduleRun записывает результат попытки и сохраняет следующее состояние расписания next.
+// Если попытку тем временем записал другой экземпляр сервиса, возвращается apperror.ErrConflict: вызванный
+// в транзакции WithTx вместе с операцией расписания, он откатывает и ее, поэтому операция выполняется
+// ровно один раз независимо от срока хранения ключей идемпотентности.
+// Поставленное на паузу или отмененное расписание сохраняет свой статус
+func (r *ScheduleRepository) FinishScheduleRun(ctx context.Context, claimed model.Schedule, run model.ScheduleRun, next model.Schedule) error {
+	return r.inTx(ctx, func(t pgx.Tx) (err error) {
+		q := `
+		UPDATE schedule
+		SET status       = CASE WHEN status = 'active' THEN $3::schedule_status ELSE status END,
+		    due_at       = $4,
+		    next_run_at  = $5,
+		    attempt      = $6,
+		    locked_until = NULL
+		WHERE schedule_id = $1
+		  AND due_at = $2
+		  AND attempt = $7
+		`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		commandTag, err := t.Exec(ctx, q, claimed.ScheduleID, claimed.DueAt.UTC(), string(next.Status),
+			next.DueAt.UTC(), next.NextRunAt.UTC(), next.Attempt, claimed.Attempt)
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return err
+		}
+
+		if commandTag.RowsAffected() == 0 {
+			return apperror.NewAppError(apperror.ErrConflict, ScheduleRunFinished.Error(),
+				fmt.Sprintf("schedule %s, due at %s, attempt %d", claimed.ScheduleID, claimed.DueAt.UTC().Format(time.RFC3339), claimed.Attempt))
+		}
+
+		q = `
+		INSERT INTO schedule_run (schedule_id, due_at, attempt, status, error, review_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		`
+		r.logger.Trace(fmt.Sprintf("SQL Query: %s", utils.FormatQuery(q)))
+
+		_, err = t.Exec(ctx, q, claimed.ScheduleID, run.DueAt.UTC(), run.Attempt, string(run.Status), run.Error, nullUUID(run.ReviewID))
+		if err != nil {
+			err = PgxErrorLog(err, r.logger)
+			return err
+		}
+
+		return nil
+	})
+}
+
+func scanSchedule(row pgx.Row) (*model.Schedule, error) {
+	var s model.Schedule
+	err := row.Scan(&s.ScheduleID, &s.Operation, &s.UserID, &s.UserIDTo, &s.Amount, &s.Comment, &s.Recurrence,
+		&s.DayOfMonth, &s.Status, &s.DueAt, &s.NextRunAt, &s.Attempt, &s.MaxRetries, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func scanSchedules(rows pgx.Rows) ([]model.Schedule, error) {
+	defer rows.Close()
+
+	schedules := make([]model.Schedule, 0)
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
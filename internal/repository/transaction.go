@@ -2,12 +2,38 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
 	"github.com/garet2gis/user_balance_service/pkg/postgresql"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"math/rand"
+	"time"
 )
 
+const (
+	// maxTxAttempts сколько раз выполняется единица работы, прерванная конфликтом сериализации
+	maxTxAttempts = 5
+	// txRetryBaseDelay верхняя граница задержки перед первым повтором, далее удваивается
+	txRetryBaseDelay = 10 * time.Millisecond
+	txRetryMaxDelay  = 500 * time.Millisecond
+)
+
+var (
+	TransactionAborted = errors.New("transaction was aborted by concurrent updates, retry the request")
+	// errRollback откатывает транзакцию без ошибки для вызывающего кода
+	errRollback = errors.New("rollback")
+)
+
+// txRetries счетчики повторов транзакций, публикуются в /debug/vars служебного сервера:
+// retried - все повторы, 40001 и 40P01 - повторы по коду ошибки,
+// recovered - транзакции, выполненные после повтора, exhausted - транзакции, не выполненные за maxTxAttempts попыток
+var txRetries = expvar.NewMap("transaction_retries")
+
 type TransactionHelper struct {
 	client postgresql.Client
 	logger *logging.Logger
@@ -20,20 +46,175 @@ func NewTransactionHelper(c *pgxpool.Pool, l *logging.Logger) *TransactionHelper
 	}
 }
 
-func (r *TransactionHelper) beginTransaction(ctx context.Context) (pgx.Tx, error) {
-	return r.client.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+// txKey ключ контекста, в котором WithTx передает транзакцию вложенным вызовам
+type txKey struct{}
+
+type unitOfWork struct {
+	tx pgx.Tx
+	// ошибка сериализации во вложенном вызове: транзакцию нельзя зафиксировать, она выполняется заново целиком
+	abort *error
 }
 
-func (r *TransactionHelper) rollbackTransaction(ctx context.Context, tx pgx.Tx) {
-	err := tx.Rollback(ctx)
+// querier общая часть пула соединений и транзакции
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// conn возвращает транзакцию WithTx, если она есть в контексте, иначе - пул соединений
+func conn(ctx context.Context, c postgresql.Client) querier {
+	if uow, ok := ctx.Value(txKey{}).(*unitOfWork); ok {
+		return uow.tx
+	}
+	return c
+}
+
+// WithTx выполняет fn в одной транзакции: методы репозиториев, вызванные с переданным в fn контекстом,
+// присоединяются к ней, а их собственные транзакции становятся точками сохранения, поэтому ошибка
+// вложенного вызова откатывает только его изменения. Транзакция фиксируется, если fn не вернула ошибку,
+// ошибка фиксации возвращается вызывающему коду. Пустой уровень изоляции означает serializable.
+// При конфликте сериализации fn выполняется заново, поэтому она не должна иметь побочных эффектов вне БД.
+// Вложенный WithTx создает точку сохранения, opts у него не учитываются
+func (r *TransactionHelper) WithTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error {
+	if opts.IsoLevel == "" {
+		opts.IsoLevel = pgx.Serializable
+	}
+	return r.run(ctx, opts, func(ctx context.Context, _ pgx.Tx) error {
+		return fn(ctx)
+	})
+}
+
+// inTx выполняет fn в serializable транзакции или в точке сохранения транзакции WithTx
+func (r *TransactionHelper) inTx(ctx context.Context, fn func(t pgx.Tx) error) error {
+	return r.run(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(_ context.Context, t pgx.Tx) error {
+		return fn(t)
+	})
+}
+
+// inTxWithResult аналог inTx для единицы работы с результатом. Возвращается результат последней попытки
+func inTxWithResult[T any](ctx context.Context, r *TransactionHelper, fn func(t pgx.Tx) (T, error)) (T, error) {
+	var res T
+	err := r.inTx(ctx, func(t pgx.Tx) error {
+		var err error
+		res, err = fn(t)
+		return err
+	})
+	return res, err
+}
+
+// run выполняет fn в новой транзакции и фиксирует ее, если fn не вернула ошибку.
+// Транзакция, прерванная конфликтом сериализации (40001) или взаимной блокировкой (40P01), выполняется
+// заново целиком, до maxTxAttempts раз со случайной экспоненциальной задержкой. Если попытки закончились,
+// возвращается apperror.ErrTryAgain. Внутри WithTx fn выполняется в точке сохранения без повторов
+func (r *TransactionHelper) run(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context, t pgx.Tx) error) error {
+	if uow, ok := ctx.Value(txKey{}).(*unitOfWork); ok {
+		return r.runSavepoint(ctx, uow, fn)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := r.runTx(ctx, opts, fn)
+		if err == nil {
+			if attempt > 1 {
+				txRetries.Add("recovered", 1)
+			}
+			return nil
+		}
+
+		code, ok := retryableCode(err)
+		if !ok {
+			return err
+		}
+
+		if attempt == maxTxAttempts {
+			txRetries.Add("exhausted", 1)
+			r.logger.Errorf("transaction aborted with %s after %d attempts: %v", code, attempt, err)
+			return apperror.NewAppError(apperror.ErrTryAgain, TransactionAborted.Error(),
+				fmt.Sprintf("%s after %d attempts", code, attempt))
+		}
+
+		txRetries.Add("retried", 1)
+		txRetries.Add(code, 1)
+		r.logger.Warnf("transaction aborted with %s, attempt %d of %d", code, attempt, maxTxAttempts)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay(attempt)):
+		}
+	}
+}
+
+func (r *TransactionHelper) runTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context, t pgx.Tx) error) (err error) {
+	t, err := r.client.BeginTx(ctx, opts)
 	if err != nil {
-		r.logger.Errorf("transaction rollback failed")
+		return err
 	}
+	committing := false
+	defer func() {
+		if err != nil && !committing {
+			r.rollbackTransaction(ctx, t)
+		}
+	}()
+
+	var abort error
+	err = fn(context.WithValue(ctx, txKey{}, &unitOfWork{tx: t, abort: &abort}), t)
+	if abort != nil {
+		err = abort
+	}
+	if err != nil {
+		return err
+	}
+
+	// неудачный Commit уже закрывает транзакцию, откатывать ее не нужно
+	committing = true
+	return t.Commit(ctx)
 }
 
-func (r *TransactionHelper) commitTransaction(ctx context.Context, tx pgx.Tx) {
-	err := tx.Commit(ctx)
+func (r *TransactionHelper) runSavepoint(ctx context.Context, uow *unitOfWork, fn func(ctx context.Context, t pgx.Tx) error) (err error) {
+	sp, err := uow.tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			r.rollbackTransaction(ctx, sp)
+		}
+		if _, ok := retryableCode(err); ok && *uow.abort == nil {
+			*uow.abort = err
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, txKey{}, &unitOfWork{tx: sp, abort: uow.abort}), sp)
+	if err != nil {
+		return err
+	}
+
+	return sp.Commit(ctx)
+}
+
+// retryableCode возвращает код ошибки, после которой транзакцию можно выполнить заново
+func retryableCode(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01") {
+		return pgErr.Code, true
+	}
+	return "", false
+}
+
+// retryDelay случайная задержка от 0 до экспоненциально растущей границы, чтобы конкурирующие
+// транзакции не повторялись одновременно
+func retryDelay(attempt int) time.Duration {
+	ceiling := txRetryBaseDelay << (attempt - 1)
+	if ceiling > txRetryMaxDelay {
+		ceiling = txRetryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+func (r *TransactionHelper) rollbackTransaction(ctx context.Context, tx pgx.Tx) {
+	err := tx.Rollback(ctx)
 	if err != nil {
-		r.logger.Errorf("transaction commit failed")
+		r.logger.Errorf("transaction rollback failed: %v", err)
 	}
 }
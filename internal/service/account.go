@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+)
+
+type AccountRepository interface {
+	balanceReader
+	SetCreditLimit(ctx context.Context, cl dto.CreditLimitRequest) error
+	ChangeStatus(ctx context.Context, sr dto.AccountStatusRequest, status model.AccountStatus) error
+}
+
+type AccountService struct {
+	repo   AccountRepository
+	logger *logging.Logger
+}
+
+func NewAccountService(r AccountRepository, l *logging.Logger) *AccountService {
+	return &AccountService{
+		repo:   r,
+		logger: l,
+	}
+}
+
+func (as *AccountService) SetCreditLimit(ctx context.Context, cl dto.CreditLimitRequest) (*model.Balance, error) {
+	err := as.repo.SetCreditLimit(ctx, cl)
+	if err != nil {
+		return nil, err
+	}
+
+	return getBalance(ctx, as.repo, cl.UserID, nil)
+}
+
+// ChangeAccountStatus замораживает, размораживает или закрывает счет пользователя
+func (as *AccountService) ChangeAccountStatus(ctx context.Context, sr dto.AccountStatusRequest, status model.AccountStatus) (*model.Balance, error) {
+	err := as.repo.ChangeStatus(ctx, sr, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return getBalance(ctx, as.repo, sr.UserID, nil)
+}
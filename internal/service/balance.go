@@ -2,42 +2,84 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
 	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
 	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+var (
+	SelfTransfer       = errors.New("sender must not be among recipients")
+	DuplicateRecipient = errors.New("recipient must not be listed twice")
 )
 
 type BalanceRepository interface {
-	GetBalanceByUserID(ctx context.Context, id string) (float64, error)
+	GetBalanceByUserID(ctx context.Context, id string) (money.Amount, error)
+	GetBalanceAt(ctx context.Context, id string, asOf *time.Time) (available money.Amount, reserved money.Amount, err error)
+	GetReservedAmount(ctx context.Context, userID string) (money.Amount, error)
+	GetAccountState(ctx context.Context, id string) (*model.AccountState, error)
 	ChangeUserBalance(ctx context.Context, b dto.BalanceChangeRequest, depositType model.DepositType) (bm *dto.BalanceChangeRequest, err error)
 	TransferMoney(ctx context.Context, transfer dto.TransferRequest) (err error)
+	ExecuteBatch(ctx context.Context, batch dto.BatchRequest, check func(ctx context.Context, i int, item dto.BatchItem) error) (*model.BatchResult, error)
+	SplitTransfer(ctx context.Context, transfer dto.SplitTransferRequest) (*model.SplitTransfer, error)
+	IdempotencyKeyUsed(ctx context.Context, key idempotency.Key) (bool, error)
+	WithTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error
 }
 
 type BalanceService struct {
-	repo   BalanceRepository
-	logger *logging.Logger
+	repo    BalanceRepository
+	policy  *policy.Engine
+	reviews *ReviewService
+	logger  *logging.Logger
 }
 
-func NewBalanceService(r BalanceRepository, l *logging.Logger) *BalanceService {
+func NewBalanceService(r BalanceRepository, p *policy.Engine, reviews *ReviewService, l *logging.Logger) *BalanceService {
 	return &BalanceService{
-		repo:   r,
-		logger: l,
+		repo:    r,
+		policy:  p,
+		reviews: reviews,
+		logger:  l,
 	}
 }
 
-func (bs *BalanceService) GetBalanceByUserID(ctx context.Context, id string) (*model.Balance, error) {
-	balance, err := bs.repo.GetBalanceByUserID(ctx, id)
+// GetBalanceByUserID возвращает текущий баланс пользователя либо баланс на момент asOf
+func (bs *BalanceService) GetBalanceByUserID(ctx context.Context, id string, asOf *time.Time) (*model.Balance, error) {
+	return getBalance(ctx, bs.repo, id, asOf)
+}
+
+func (bs *BalanceService) ChangeUserBalance(ctx context.Context, b dto.BalanceChangeRequest, depositType model.DepositType) (bm *dto.BalanceChangeRequest, err error) {
+	replay, err := replayed(ctx, bs.repo)
 	if err != nil {
 		return nil, err
 	}
+	if replay {
+		return bs.repo.ChangeUserBalance(ctx, b, depositType)
+	}
 
-	return &model.Balance{
-		Balance: balance,
-		UserID:  id,
-	}, nil
-}
+	err = bs.policy.Check(policy.Operation{
+		Type:    model.OperationType(depositType),
+		UserID:  b.UserID,
+		Amount:  b.Amount,
+		Comment: b.Comment,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if depositType == model.Reduce {
+		err = bs.reviews.screen(ctx, model.ReduceOperation, b.UserID, nil, b.Amount, b)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-func (bs *BalanceService) ChangeUserBalance(ctx context.Context, b dto.BalanceChangeRequest, depositType model.DepositType) (bm *dto.BalanceChangeRequest, err error) {
 	balance, err := bs.repo.ChangeUserBalance(ctx, b, depositType)
 	if err != nil {
 		return nil, err
@@ -46,9 +88,214 @@ func (bs *BalanceService) ChangeUserBalance(ctx context.Context, b dto.BalanceCh
 }
 
 func (bs *BalanceService) TransferMoney(ctx context.Context, transfer dto.TransferRequest) (err error) {
+	replay, err := replayed(ctx, bs.repo)
+	if err != nil {
+		return err
+	}
+	if replay {
+		return bs.repo.TransferMoney(ctx, transfer)
+	}
+
+	err = bs.policy.Check(transferOperation(transfer))
+	if err != nil {
+		return err
+	}
+
+	err = bs.reviews.screen(ctx, model.TransferOperation, transfer.UserIDFrom, []string{transfer.UserIDTo}, transfer.Amount, transfer)
+	if err != nil {
+		return err
+	}
+
 	err = bs.repo.TransferMoney(ctx, transfer)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// SplitTransfer проверяет правилами перевод каждому получателю отдельно, а оценивает риск и отправляет
+// на проверку весь перевод целиком
+func (bs *BalanceService) SplitTransfer(ctx context.Context, transfer dto.SplitTransferRequest) (*model.SplitTransfer, error) {
+	err := checkRecipients(transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	replay, err := replayed(ctx, bs.repo)
+	if err != nil {
+		return nil, err
+	}
+	if replay {
+		return bs.repo.SplitTransfer(ctx, transfer)
+	}
+
+	recipients := make([]string, 0, len(transfer.Recipients))
+	var total money.Amount
+	for _, to := range transfer.Recipients {
+		recipients = append(recipients, to.UserIDTo)
+		total += to.Amount
+
+		comment := to.Comment
+		if comment == "" {
+			comment = transfer.Comment
+		}
+		err = bs.policy.Check(transferOperation(dto.TransferRequest{
+			Amount:     to.Amount,
+			UserIDFrom: transfer.UserIDFrom,
+			UserIDTo:   to.UserIDTo,
+			Comment:    comment,
+		}))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = bs.reviews.screen(ctx, model.SplitTransferOperation, transfer.UserIDFrom, recipients, total, transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	return bs.repo.SplitTransfer(ctx, transfer)
+}
+
+// checkRecipients запрещает перевод самому себе и повтор получателя: такие проводки не меняют итог,
+// но расходуют лимиты отправителя и учитываются в оценке риска
+func checkRecipients(transfer dto.SplitTransferRequest) error {
+	seen := make(map[string]bool, len(transfer.Recipients))
+	for _, recipient := range transfer.Recipients {
+		if recipient.UserIDTo == transfer.UserIDFrom {
+			return apperror.NewAppError(SelfTransfer, SelfTransfer.Error(), fmt.Sprintf("user_id_from: %s", transfer.UserIDFrom))
+		}
+		if seen[recipient.UserIDTo] {
+			return apperror.NewAppError(DuplicateRecipient, DuplicateRecipient.Error(), fmt.Sprintf("user_id_to: %s", recipient.UserIDTo))
+		}
+		seen[recipient.UserIDTo] = true
+	}
+	return nil
+}
+
+// ExecuteBatch проверяет правилами каждую операцию перед ее выполнением: отклоненная операция завершается
+// с ошибкой так же, как операция, которой не хватило денег. Списания и переводы с высокой оценкой риска
+// не выполняются, а отправляются на проверку
+func (bs *BalanceService) ExecuteBatch(ctx context.Context, batch dto.BatchRequest) (*model.BatchResult, error) {
+	return bs.repo.ExecuteBatch(ctx, batch, func(ctx context.Context, i int, item dto.BatchItem) error {
+		err := bs.policy.Check(policy.Operation{
+			Type:     item.Type,
+			UserID:   item.UserID,
+			UserIDTo: item.UserIDTo,
+			Amount:   item.Amount,
+			Comment:  item.Comment,
+		})
+		if err != nil {
+			return err
+		}
+
+		switch item.Type {
+		case model.ReduceOperation:
+			b := dto.BalanceChangeRequest{
+				Amount:  item.Amount,
+				UserID:  item.UserID,
+				Comment: item.Comment,
+			}
+			ctx, err = batchItemKey(ctx, i, item.Type, b)
+			if err != nil {
+				return err
+			}
+			return bs.reviews.screen(ctx, item.Type, item.UserID, nil, item.Amount, b)
+		case model.TransferOperation:
+			transfer := dto.TransferRequest{
+				Amount:     item.Amount,
+				UserIDFrom: item.UserID,
+				UserIDTo:   item.UserIDTo,
+				Comment:    item.Comment,
+			}
+			ctx, err = batchItemKey(ctx, i, item.Type, transfer)
+			if err != nil {
+				return err
+			}
+			return bs.reviews.screen(ctx, item.Type, item.UserID, []string{item.UserIDTo}, item.Amount, transfer)
+		}
+		return nil
+	})
+}
+
+// batchItemKey заменяет ключ идемпотентности пакета ключом его i-й операции, с которым операция выполнится
+// при одобрении заявки на проверку. Без ключа пакета заявка выполняется с ключом самой заявки
+func batchItemKey(ctx context.Context, i int, op model.OperationType, request interface{}) (context.Context, error) {
+	key, ok := idempotency.FromContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+
+	itemKey, err := idempotency.NewKey(fmt.Sprintf("batch:%s:%d", key.Value, i), string(op), request)
+	if err != nil {
+		return nil, err
+	}
+	return idempotency.WithKey(ctx, itemKey), nil
+}
+
+func transferOperation(transfer dto.TransferRequest) policy.Operation {
+	return policy.Operation{
+		Type:     model.TransferOperation,
+		UserID:   transfer.UserIDFrom,
+		UserIDTo: transfer.UserIDTo,
+		Amount:   transfer.Amount,
+		Comment:  transfer.Comment,
+	}
+}
+
+type balanceReader interface {
+	GetBalanceByUserID(ctx context.Context, id string) (money.Amount, error)
+	GetBalanceAt(ctx context.Context, id string, asOf *time.Time) (available money.Amount, reserved money.Amount, err error)
+	GetReservedAmount(ctx context.Context, userID string) (money.Amount, error)
+	GetAccountState(ctx context.Context, id string) (*model.AccountState, error)
+	WithTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error
+}
+
+// getBalance читает баланс, резервы и состояние счета из одного снимка БД, чтобы версия соответствовала балансу
+func getBalance(ctx context.Context, repo balanceReader, id string, asOf *time.Time) (b *model.Balance, err error) {
+	err = repo.WithTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, func(ctx context.Context) error {
+		balance, err := repo.GetBalanceByUserID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// текущие остатки берутся из balance и действующих резервов, исторические - из проводок
+		var reserved money.Amount
+		if asOf == nil {
+			reserved, err = repo.GetReservedAmount(ctx, id)
+		} else {
+			balance, reserved, err = repo.GetBalanceAt(ctx, id, asOf)
+		}
+		if err != nil {
+			return err
+		}
+
+		state, err := repo.GetAccountState(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		availableCredit := state.CreditLimit
+		if balance < 0 {
+			availableCredit += balance
+		}
+
+		b = &model.Balance{
+			Balance:         balance,
+			Reserved:        reserved,
+			CreditLimit:     state.CreditLimit,
+			Status:          state.Status,
+			AvailableCredit: availableCredit,
+			UserID:          id,
+			Version:         state.Version,
+			AsOf:            asOf,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+)
+
+type CatalogRepository interface {
+	CreateService(ctx context.Context, sc dto.ServiceCreateRequest) (*model.Service, error)
+	RenameService(ctx context.Context, sr dto.ServiceRenameRequest) (*model.Service, error)
+	SetServiceActive(ctx context.Context, id string, active bool) (*model.Service, error)
+	GetServices(ctx context.Context, sl dto.ServiceListRequest) ([]model.Service, error)
+	CreateServicePrice(ctx context.Context, p model.ServicePrice) (*model.ServicePrice, error)
+	GetServicePrices(ctx context.Context, serviceID string) ([]model.ServicePrice, error)
+}
+
+type CatalogService struct {
+	repo   CatalogRepository
+	logger *logging.Logger
+}
+
+func NewCatalogService(r CatalogRepository, l *logging.Logger) *CatalogService {
+	return &CatalogService{
+		repo:   r,
+		logger: l,
+	}
+}
+
+func (cs *CatalogService) CreateService(ctx context.Context, sc dto.ServiceCreateRequest) (*model.Service, error) {
+	return cs.repo.CreateService(ctx, sc)
+}
+
+func (cs *CatalogService) RenameService(ctx context.Context, sr dto.ServiceRenameRequest) (*model.Service, error) {
+	return cs.repo.RenameService(ctx, sr)
+}
+
+func (cs *CatalogService) DeactivateService(ctx context.Context, id string) (*model.Service, error) {
+	return cs.repo.SetServiceActive(ctx, id, false)
+}
+
+func (cs *CatalogService) ActivateService(ctx context.Context, id string) (*model.Service, error) {
+	return cs.repo.SetServiceActive(ctx, id, true)
+}
+
+func (cs *CatalogService) GetServices(ctx context.Context, sl dto.ServiceListRequest) ([]model.Service, error) {
+	return cs.repo.GetServices(ctx, sl)
+}
+
+func (cs *CatalogService) CreateServicePrice(ctx context.Context, pr dto.ServicePriceRequest) (*model.ServicePrice, error) {
+	p := model.ServicePrice{
+		ServiceID: pr.ServiceID,
+		Price:     pr.Price,
+		MinPrice:  pr.MinPrice,
+		MaxPrice:  pr.MaxPrice,
+		ValidTo:   pr.ValidTo,
+	}
+	if pr.ValidFrom != nil {
+		p.ValidFrom = *pr.ValidFrom
+	}
+
+	return cs.repo.CreateServicePrice(ctx, p)
+}
+
+func (cs *CatalogService) GetServicePrices(ctx context.Context, serviceID string) ([]model.ServicePrice, error) {
+	return cs.repo.GetServicePrices(ctx, serviceID)
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+)
+
+type FeeRepository interface {
+	CreateFeeRule(ctx context.Context, f model.FeeRule) (*model.FeeRule, error)
+	GetFeeRules(ctx context.Context, fl dto.FeeRuleListRequest) ([]model.FeeRule, error)
+	DeactivateFeeRule(ctx context.Context, id string) (*model.FeeRule, error)
+}
+
+type FeeService struct {
+	repo   FeeRepository
+	logger *logging.Logger
+}
+
+func NewFeeService(r FeeRepository, l *logging.Logger) *FeeService {
+	return &FeeService{
+		repo:   r,
+		logger: l,
+	}
+}
+
+func (fs *FeeService) CreateFeeRule(ctx context.Context, fr dto.FeeRuleRequest) (*model.FeeRule, error) {
+	return fs.repo.CreateFeeRule(ctx, model.FeeRule{
+		Operation: fr.Operation,
+		ServiceID: fr.ServiceID,
+		Flat:      fr.Flat,
+		Percent:   fr.Percent,
+		MinFee:    fr.MinFee,
+		MaxFee:    fr.MaxFee,
+	})
+}
+
+func (fs *FeeService) GetFeeRules(ctx context.Context, fl dto.FeeRuleListRequest) ([]model.FeeRule, error) {
+	return fs.repo.GetFeeRules(ctx, fl)
+}
+
+func (fs *FeeService) DeactivateFeeRule(ctx context.Context, id string) (*model.FeeRule, error) {
+	return fs.repo.DeactivateFeeRule(ctx, id)
+}
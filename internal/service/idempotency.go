@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"time"
+)
+
+type IdempotencyRepository interface {
+	DeleteExpiredIdempotencyKeys(ctx context.Context, ttl time.Duration) (int64, error)
+}
+
+type idempotencyChecker interface {
+	IdempotencyKeyUsed(ctx context.Context, key idempotency.Key) (bool, error)
+}
+
+type IdempotencyService struct {
+	repo   IdempotencyRepository
+	logger *logging.Logger
+}
+
+func NewIdempotencyService(r IdempotencyRepository, l *logging.Logger) *IdempotencyService {
+	return &IdempotencyService{
+		repo:   r,
+		logger: l,
+	}
+}
+
+// DeleteExpiredIdempotencyKeys удаляет ключи идемпотентности старше ttl.
+// После удаления повтор запроса с тем же ключом будет выполнен как новый
+func (is *IdempotencyService) DeleteExpiredIdempotencyKeys(ctx context.Context, ttl time.Duration) error {
+	deleted, err := is.repo.DeleteExpiredIdempotencyKeys(ctx, ttl)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		is.logger.Infof("deleted %d expired idempotency keys", deleted)
+	}
+
+	return nil
+}
+
+// replayed сообщает, что запрос с ключом идемпотентности из контекста уже выполнен. Повтор такого запроса
+// возвращает сохраненный результат, поэтому правила, действующие сейчас, к нему не применяются
+func replayed(ctx context.Context, repo idempotencyChecker) (bool, error) {
+	key, ok := idempotency.FromContext(ctx)
+	if !ok {
+		return false, nil
+	}
+	return repo.IdempotencyKeyUsed(ctx, key)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+)
+
+type LimitRepository interface {
+	SetLimit(ctx context.Context, l model.Limit) error
+	ResetLimit(ctx context.Context, l model.Limit) error
+	SetDefaultLimits(ctx context.Context, limits []model.Limit) error
+	GetLimitUsage(ctx context.Context, userID string) ([]model.LimitUsage, error)
+}
+
+type LimitService struct {
+	repo   LimitRepository
+	logger *logging.Logger
+}
+
+func NewLimitService(r LimitRepository, l *logging.Logger) *LimitService {
+	return &LimitService{
+		repo:   r,
+		logger: l,
+	}
+}
+
+func (ls *LimitService) SetLimit(ctx context.Context, lr dto.LimitRequest) ([]model.LimitUsage, error) {
+	err := ls.repo.SetLimit(ctx, model.Limit{
+		UserID:    lr.UserID,
+		Operation: lr.Operation,
+		Period:    lr.Period,
+		Amount:    lr.Amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ls.repo.GetLimitUsage(ctx, lr.UserID)
+}
+
+func (ls *LimitService) ResetLimit(ctx context.Context, lr dto.LimitResetRequest) ([]model.LimitUsage, error) {
+	err := ls.repo.ResetLimit(ctx, model.Limit{
+		UserID:    lr.UserID,
+		Operation: lr.Operation,
+		Period:    lr.Period,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ls.repo.GetLimitUsage(ctx, lr.UserID)
+}
+
+// SetDefaultLimits заменяет общие лимиты, нулевые лимиты не сохраняются
+func (ls *LimitService) SetDefaultLimits(ctx context.Context, limits []model.Limit) error {
+	defaults := make([]model.Limit, 0, len(limits))
+	for _, l := range limits {
+		if l.Amount > 0 {
+			defaults = append(defaults, l)
+		}
+	}
+
+	return ls.repo.SetDefaultLimits(ctx, defaults)
+}
+
+func (ls *LimitService) GetLimitUsage(ctx context.Context, userID string) ([]model.LimitUsage, error) {
+	return ls.repo.GetLimitUsage(ctx, userID)
+}
@@ -2,39 +2,195 @@ package service
 
 import (
 	"context"
+	"errors"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
 	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"time"
 )
 
+// expireBatchSize сколько просроченных резервов отменяется за один запуск
+const expireBatchSize = 100
+
 type ReservationRepository interface {
-	ReserveMoney(ctx context.Context, rm model.Reservation) (err error)
-	CommitReservation(ctx context.Context, rm model.Reservation, status model.ReservationStatus) (err error)
+	ReserveMoney(ctx context.Context, rm model.Reservation) (*model.ReservationState, error)
+	CommitReservation(ctx context.Context, rc dto.ReservationCommitRequest, status model.ReservationStatus) (err error)
+	AdjustReservation(ctx context.Context, ra dto.ReservationAdjustRequest) (*model.ReservationAdjustment, error)
+	RefundReservation(ctx context.Context, rr dto.RefundRequest) (*model.ReservationState, error)
+	ReserveOrder(ctx context.Context, or dto.OrderReservationRequest) (*model.OrderReservation, error)
+	CommitOrder(ctx context.Context, oc dto.OrderCommitRequest, status model.ReservationStatus) (err error)
+	GetReservation(ctx context.Context, id string) (*model.ReservationState, error)
+	GetOpenReservations(ctx context.Context, rl dto.ReservationListRequest) (*model.ReservationList, error)
+	GetExpiredReservations(ctx context.Context, limit int) ([]model.ReservationState, error)
+	IdempotencyKeyUsed(ctx context.Context, key idempotency.Key) (bool, error)
 }
 
 type ReservationService struct {
 	repo   ReservationRepository
-	logger *logging.Logger
+	policy *policy.Engine
+	// defaultTTL время жизни резерва, если ttl не передан при резервировании. 0 - резерв бессрочный
+	defaultTTL time.Duration
+	logger     *logging.Logger
 }
 
-func NewReservationService(r ReservationRepository, l *logging.Logger) *ReservationService {
+func NewReservationService(r ReservationRepository, p *policy.Engine, l *logging.Logger) *ReservationService {
 	return &ReservationService{
 		repo:   r,
+		policy: p,
 		logger: l,
 	}
 }
 
-func (rs *ReservationService) ReserveMoney(ctx context.Context, rm model.Reservation) (err error) {
-	err = rs.repo.ReserveMoney(ctx, rm)
+// SetReservationTTL задает время жизни резервов, созданных без ttl
+func (rs *ReservationService) SetReservationTTL(ttl time.Duration) {
+	rs.defaultTTL = ttl
+}
+
+func (rs *ReservationService) ReserveMoney(ctx context.Context, rm model.Reservation) (*model.ReservationState, error) {
+	rm.TTL = rs.ttl(rm.TTL)
+
+	replay, err := replayed(ctx, rs.repo)
+	if err != nil {
+		return nil, err
+	}
+	if replay {
+		return rs.repo.ReserveMoney(ctx, rm)
+	}
+
+	err = rs.policy.Check(policy.Operation{
+		Type:      model.ReserveOperation,
+		UserID:    rm.UserID,
+		ServiceID: rm.ServiceID,
+		Amount:    rm.Cost,
+		Comment:   rm.Comment,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := rs.repo.ReserveMoney(ctx, rm)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (rs *ReservationService) CommitReservation(ctx context.Context, rc dto.ReservationCommitRequest, status model.ReservationStatus) (err error) {
+	err = rs.repo.CommitReservation(ctx, rc, status)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReserveOrder проверяет правилами резерв каждой услуги заказа
+func (rs *ReservationService) ReserveOrder(ctx context.Context, or dto.OrderReservationRequest) (*model.OrderReservation, error) {
+	or.TTL = rs.ttl(or.TTL)
+
+	replay, err := replayed(ctx, rs.repo)
+	if err != nil {
+		return nil, err
+	}
+	if replay {
+		return rs.repo.ReserveOrder(ctx, or)
+	}
+
+	for _, line := range or.Lines {
+		comment := line.Comment
+		if comment == "" {
+			comment = or.Comment
+		}
+		err = rs.policy.Check(policy.Operation{
+			Type:      model.ReserveOperation,
+			UserID:    or.UserID,
+			ServiceID: line.ServiceID,
+			Amount:    line.Cost,
+			Comment:   comment,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	order, err := rs.repo.ReserveOrder(ctx, or)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (rs *ReservationService) CommitOrder(ctx context.Context, oc dto.OrderCommitRequest, status model.ReservationStatus) (err error) {
+	err = rs.repo.CommitOrder(ctx, oc, status)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (rs *ReservationService) CommitReservation(ctx context.Context, rm model.Reservation, status model.ReservationStatus) (err error) {
-	err = rs.repo.CommitReservation(ctx, rm, status)
+func (rs *ReservationService) RefundReservation(ctx context.Context, rr dto.RefundRequest) (*model.ReservationState, error) {
+	state, err := rs.repo.RefundReservation(ctx, rr)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (rs *ReservationService) GetReservation(ctx context.Context, id string) (*model.ReservationState, error) {
+	state, err := rs.repo.GetReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (rs *ReservationService) AdjustReservation(ctx context.Context, ra dto.ReservationAdjustRequest) (*model.ReservationAdjustment, error) {
+	adjustment, err := rs.repo.AdjustReservation(ctx, ra)
+	if err != nil {
+		return nil, err
+	}
+	return adjustment, nil
+}
+
+func (rs *ReservationService) GetOpenReservations(ctx context.Context, rl dto.ReservationListRequest) (*model.ReservationList, error) {
+	list, err := rs.repo.GetOpenReservations(ctx, rl)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ttl возвращает переданное время жизни резерва или время жизни по умолчанию, если оно не передано
+func (rs *ReservationService) ttl(ttl *int) *int {
+	if ttl != nil {
+		return ttl
+	}
+	seconds := int(rs.defaultTTL / time.Second)
+	return &seconds
+}
+
+// ExpireReservations возвращает пользователям деньги просроченных резервов тем же способом, что и отмена
+func (rs *ReservationService) ExpireReservations(ctx context.Context) error {
+	reservations, err := rs.repo.GetExpiredReservations(ctx, expireBatchSize)
 	if err != nil {
 		return err
 	}
+
+	for _, rm := range reservations {
+		ref := dto.ReservationRef{ReservationID: rm.ReservationID}
+		err = rs.repo.CommitReservation(ctx, dto.ReservationCommitRequest{ReservationRef: ref}, model.Expired)
+		// резерв успели подтвердить или отменить
+		if errors.Is(err, apperror.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			rs.logger.Errorf("failed to expire reservation of order %s: %v", rm.OrderID, err)
+			continue
+		}
+		rs.logger.Infof("reservation of order %s for service %s expired", rm.OrderID, rm.ServiceID)
+	}
+
 	return nil
 }
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/garet2gis/user_balance_service/pkg/money"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+var OperationRejected = errors.New("operation was rejected on review")
+
+type ReviewRepository interface {
+	GetRiskSignals(ctx context.Context, userID string, recipients []string, since time.Time) (*model.RiskSignals, error)
+	CreateReview(ctx context.Context, rv model.Review) (*model.Review, error)
+	GetReviews(ctx context.Context, rl dto.ReviewListRequest) ([]model.Review, error)
+	ChangeReviewStatus(ctx context.Context, id string, status model.ReviewStatus, comment, reviewErr string, from ...model.ReviewStatus) (*model.Review, error)
+	ChangeUserBalance(ctx context.Context, b dto.BalanceChangeRequest, depositType model.DepositType) (bm *dto.BalanceChangeRequest, err error)
+	TransferMoney(ctx context.Context, transfer dto.TransferRequest) (err error)
+	SplitTransfer(ctx context.Context, transfer dto.SplitTransferRequest) (*model.SplitTransfer, error)
+	CheckVersion(ctx context.Context, userID string) error
+	WithTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error
+}
+
+type ReviewService struct {
+	repo ReviewRepository
+	// общая для всех копий сервиса, задается при запуске
+	config *model.RiskConfig
+	logger *logging.Logger
+}
+
+func NewReviewService(r ReviewRepository, l *logging.Logger) *ReviewService {
+	return &ReviewService{
+		repo:   r,
+		config: &model.RiskConfig{},
+		logger: l,
+	}
+}
+
+// SetRiskConfig задает веса оценки риска. Без вызова оценка риска выключена
+func (rs *ReviewService) SetRiskConfig(c model.RiskConfig) {
+	*rs.config = c
+}
+
+// screen оценивает риск списания или перевода получателям recipients. Операция с высокой оценкой сохраняется
+// на проверку и возвращается ошибка *model.ReviewRequired. Если повтор запроса с тем же ключом идемпотентности
+// уже одобрен, операция выполняется как обычно и вернет сохраненный результат. Перед сохранением на проверку
+// сверяется версия баланса из заголовка If-Match
+func (rs *ReviewService) screen(ctx context.Context, op model.OperationType, userID string, recipients []string, amount money.Amount, request interface{}) error {
+	cfg := *rs.config
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	signals, err := rs.repo.GetRiskSignals(ctx, userID, recipients, time.Now().Add(-cfg.Window))
+	if err != nil {
+		return err
+	}
+
+	score, reasons := cfg.Score(*signals)
+	if score < cfg.ReviewScore {
+		return nil
+	}
+
+	// устаревший If-Match должен получить 412 сразу, а не после одобрения проверки
+	err = rs.repo.CheckVersion(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	rv := model.Review{
+		Operation: op,
+		UserID:    userID,
+		Amount:    amount,
+		Request:   body,
+		Score:     score,
+		Reasons:   reasons,
+	}
+	if len(recipients) == 1 {
+		rv.UserIDTo = recipients[0]
+	}
+	if key, ok := idempotency.FromContext(ctx); ok {
+		rv.IdempotencyKey, rv.RequestHash = key.Value, key.RequestHash
+	}
+
+	review, err := rs.repo.CreateReview(ctx, rv)
+	if err != nil {
+		return err
+	}
+
+	switch review.Status {
+	case model.ApprovedReview:
+		return nil
+	case model.RejectedReview:
+		return apperror.NewAppError(OperationRejected, OperationRejected.Error(), fmt.Sprintf("review %s", review.ReviewID))
+	}
+
+	rs.logger.Warnf("%s of user %s sent to review %s, risk score %d", op, userID, review.ReviewID, score)
+	return &model.ReviewRequired{Review: review}
+}
+
+func (rs *ReviewService) GetReviews(ctx context.Context, rl dto.ReviewListRequest) ([]model.Review, error) {
+	return rs.repo.GetReviews(ctx, rl)
+}
+
+// ApproveReview выполняет отложенную операцию в одной транзакции со сменой статуса заявки. Если выполнить
+// операцию не удалось, заявка получает статус failed и может быть одобрена повторно
+func (rs *ReviewService) ApproveReview(ctx context.Context, rd dto.ReviewDecisionRequest) (review *model.Review, err error) {
+	err = rs.repo.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		review, err = rs.repo.ChangeReviewStatus(ctx, rd.ReviewID, model.ApprovedReview, rd.Comment, "",
+			model.PendingReview, model.FailedReview)
+		if err != nil {
+			return err
+		}
+
+		execErr := rs.execute(ctx, review)
+		if execErr == nil {
+			return nil
+		}
+
+		rs.logger.Errorf("approved review %s failed: %v", review.ReviewID, execErr)
+		review, err = rs.repo.ChangeReviewStatus(ctx, review.ReviewID, model.FailedReview, "", errorMessage(execErr),
+			model.ApprovedReview)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+func (rs *ReviewService) RejectReview(ctx context.Context, rd dto.ReviewDecisionRequest) (*model.Review, error) {
+	return rs.repo.ChangeReviewStatus(ctx, rd.ReviewID, model.RejectedReview, rd.Comment, "",
+		model.PendingReview, model.FailedReview)
+}
+
+// execute выполняет исходный запрос заявки с ключом идемпотентности клиента, а без него - с ключом заявки,
+// поэтому операция не выполнится дважды
+func (rs *ReviewService) execute(ctx context.Context, rv *model.Review) error {
+	key := idempotency.Key{
+		Value:       rv.IdempotencyKey,
+		Operation:   string(rv.Operation),
+		RequestHash: rv.RequestHash,
+	}
+
+	switch rv.Operation {
+	case model.SplitTransferOperation:
+		var transfer dto.SplitTransferRequest
+		err := json.Unmarshal(rv.Request, &transfer)
+		if err != nil {
+			return err
+		}
+		if key.Value == "" {
+			key, err = idempotency.NewKey("review:"+rv.ReviewID, string(rv.Operation), transfer)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = rs.repo.SplitTransfer(idempotency.WithKey(ctx, key), transfer)
+		return err
+	case model.TransferOperation:
+		var transfer dto.TransferRequest
+		err := json.Unmarshal(rv.Request, &transfer)
+		if err != nil {
+			return err
+		}
+		if key.Value == "" {
+			key, err = idempotency.NewKey("review:"+rv.ReviewID, string(rv.Operation), transfer)
+			if err != nil {
+				return err
+			}
+		}
+		return rs.repo.TransferMoney(idempotency.WithKey(ctx, key), transfer)
+	}
+
+	var b dto.BalanceChangeRequest
+	err := json.Unmarshal(rv.Request, &b)
+	if err != nil {
+		return err
+	}
+	if key.Value == "" {
+		key, err = idempotency.NewKey("review:"+rv.ReviewID, string(rv.Operation), b)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = rs.repo.ChangeUserBalance(idempotency.WithKey(ctx, key), b, model.Reduce)
+	return err
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/garet2gis/user_balance_service/internal/apperror"
+	"github.com/garet2gis/user_balance_service/internal/dto"
+	"github.com/garet2gis/user_balance_service/internal/idempotency"
+	"github.com/garet2gis/user_balance_service/internal/model"
+	"github.com/garet2gis/user_balance_service/internal/policy"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"time"
+)
+
+// maxScheduleRetryDelay верхняя граница экспоненциальной задержки между повторами
+const maxScheduleRetryDelay = 24 * time.Hour
+
+type ScheduleRepository interface {
+	CreateSchedule(ctx context.Context, s model.Schedule) (*model.Schedule, error)
+	GetSchedules(ctx context.Context, sl dto.ScheduleListRequest) ([]model.Schedule, error)
+	ChangeScheduleStatus(ctx context.Context, id string, status model.ScheduleStatus, from ...model.ScheduleStatus) (*model.Schedule, error)
+	GetScheduleRuns(ctx context.Context, id string) ([]model.ScheduleRun, error)
+	ClaimDueSchedules(ctx context.Context, limit int, lease time.Duration) ([]model.Schedule, error)
+	FinishScheduleRun(ctx context.Context, claimed model.Schedule, run model.ScheduleRun, next model.Schedule) error
+	ChangeUserBalance(ctx context.Context, b dto.BalanceChangeRequest, depositType model.DepositType) (bm *dto.BalanceChangeRequest, err error)
+	TransferMoney(ctx context.Context, transfer dto.TransferRequest) (err error)
+	WithTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error
+}
+
+type ScheduleService struct {
+	repo    ScheduleRepository
+	policy  *policy.Engine
+	reviews *ReviewService
+	logger  *logging.Logger
+}
+
+func NewScheduleService(r ScheduleRepository, p *policy.Engine, reviews *ReviewService, l *logging.Logger) *ScheduleService {
+	return &ScheduleService{
+		repo:    r,
+		policy:  p,
+		reviews: reviews,
+		logger:  l,
+	}
+}
+
+func (ss *ScheduleService) CreateSchedule(ctx context.Context, sr dto.ScheduleRequest) (*model.Schedule, error) {
+	s := model.Schedule{
+		Operation:  sr.Operation,
+		UserID:     sr.UserID,
+		UserIDTo:   sr.UserIDTo,
+		Amount:     sr.Amount,
+		Comment:    sr.Comment,
+		Recurrence: sr.Recurrence,
+		DueAt:      sr.StartAt.UTC(),
+		MaxRetries: model.DefaultScheduleMaxRetries,
+	}
+	if s.Recurrence == "" {
+		s.Recurrence = model.Once
+	}
+	if s.Operation != model.TransferOperation {
+		s.UserIDTo = ""
+	}
+	if sr.MaxRetries != nil {
+		s.MaxRetries = *sr.MaxRetries
+	}
+
+	if s.Recurrence == model.Monthly {
+		s.DayOfMonth = sr.DayOfMonth
+		if s.DayOfMonth == 0 {
+			s.DayOfMonth = s.DueAt.Day()
+		}
+		// первое выполнение - ближайший day_of_month не раньше start_at
+		first := model.DayOfMonth(s.DueAt.Year(), s.DueAt.Month(), s.DayOfMonth, s.DueAt)
+		if first.Before(s.DueAt) {
+			first = model.DayOfMonth(s.DueAt.Year(), s.DueAt.Month()+1, s.DayOfMonth, s.DueAt)
+		}
+		s.DueAt = first
+	}
+
+	return ss.repo.CreateSchedule(ctx, s)
+}
+
+func (ss *ScheduleService) GetSchedules(ctx context.Context, sl dto.ScheduleListRequest) ([]model.Schedule, error) {
+	return ss.repo.GetSchedules(ctx, sl)
+}
+
+func (ss *ScheduleService) PauseSchedule(ctx context.Context, id string) (*model.Schedule, error) {
+	return ss.repo.ChangeScheduleStatus(ctx, id, model.PausedSchedule, model.ActiveSchedule)
+}
+
+// ResumeSchedule возобновляет расписание. Выполнения, пропущенные на паузе, будут выполнены один раз
+// при ближайшем запуске исполнителя
+func (ss *ScheduleService) ResumeSchedule(ctx context.Context, id string) (*model.Schedule, error) {
+	return ss.repo.ChangeScheduleStatus(ctx, id, model.ActiveSchedule, model.PausedSchedule)
+}
+
+func (ss *ScheduleService) CancelSchedule(ctx context.Context, id string) (*model.Schedule, error) {
+	return ss.repo.ChangeScheduleStatus(ctx, id, model.CancelledSchedule, model.ActiveSchedule, model.PausedSchedule)
+}
+
+func (ss *ScheduleService) GetScheduleRuns(ctx context.Context, id string) ([]model.ScheduleRun, error) {
+	return ss.repo.GetScheduleRuns(ctx, id)
+}
+
+// RunDueSchedules выполняет наступившие расписания. Неудачная попытка повторяется до max_retries раз
+// с экспоненциальной задержкой от retryDelay. Ошибка одного расписания не останавливает остальные:
+// она записывается в лог, а после обхода возвращаются все ошибки вместе
+func (ss *ScheduleService) RunDueSchedules(ctx context.Context, limit int, lease, retryDelay time.Duration) error {
+	schedules, err := ss.repo.ClaimDueSchedules(ctx, limit, lease)
+	if err != nil {
+		return err
+	}
+
+	var failed []error
+	for _, s := range schedules {
+		err = ss.runSchedule(ctx, s, retryDelay)
+		if err != nil {
+			ss.logger.Errorf("schedule %s run failed: %v", s.ScheduleID, err)
+			failed = append(failed, fmt.Errorf("schedule %s: %w", s.ScheduleID, err))
+		}
+	}
+
+	return joinErrors(failed, len(schedules))
+}
+
+// runSchedule выполняет операцию и записывает результат попытки в одной транзакции, поэтому успешная операция
+// не останется без записи о выполнении. Ошибка операции откатывает только ее изменения, а если попытку уже
+// записал другой экземпляр сервиса, откатывается вся транзакция вместе с операцией. Операция, отправленная
+// на проверку, выполнится при одобрении заявки, а расписание переходит к следующему выполнению
+func (ss *ScheduleService) runSchedule(ctx context.Context, s model.Schedule, retryDelay time.Duration) error {
+	err := ss.repo.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context) error {
+		execErr := ss.execute(ctx, s)
+		// ключ выполнения сейчас используется другим экземпляром сервиса, результат запишет он
+		if errors.Is(execErr, apperror.ErrConflict) {
+			return nil
+		}
+
+		now := time.Now().UTC()
+		run := model.ScheduleRun{
+			ScheduleID: s.ScheduleID,
+			DueAt:      s.DueAt,
+			Attempt:    s.Attempt,
+			Status:     model.SucceededRun,
+		}
+		next := s
+
+		var review *model.ReviewRequired
+		switch {
+		case errors.As(execErr, &review):
+			ss.logger.Warnf("schedule %s sent to review %s", s.ScheduleID, review.Review.ReviewID)
+			run.Status, run.ReviewID = model.PendingReviewRun, review.Review.ReviewID
+			next = advance(s, now)
+		case execErr == nil:
+			next = advance(s, now)
+		case s.Attempt < s.MaxRetries:
+			ss.logger.Errorf("schedule %s attempt %d failed: %v", s.ScheduleID, s.Attempt, execErr)
+			run.Status, run.Error = model.FailedRun, errorMessage(execErr)
+			next.Attempt++
+			next.NextRunAt = now.Add(retryBackoff(retryDelay, s.Attempt))
+		default:
+			ss.logger.Errorf("schedule %s failed after %d retries: %v", s.ScheduleID, s.Attempt, execErr)
+			run.Status, run.Error = model.FailedRun, errorMessage(execErr)
+			next = advance(s, now)
+			if next.Status == model.CompletedSchedule {
+				next.Status = model.FailedSchedule
+			}
+		}
+
+		return ss.repo.FinishScheduleRun(ctx, s, run, next)
+	})
+	if errors.Is(err, apperror.ErrConflict) {
+		ss.logger.Infof("schedule %s was already run by another instance: %v", s.ScheduleID, err)
+		return nil
+	}
+	return err
+}
+
+// execute выполняет операцию расписания с ключом идемпотентности, общим для всех попыток одного
+// планового выполнения, поэтому повторный захват расписания не спишет деньги дважды. С этим же ключом
+// сохраняется заявка на проверку, и одобренная операция не выполнится повторно
+func (ss *ScheduleService) execute(ctx context.Context, s model.Schedule) error {
+	value := fmt.Sprintf("schedule:%s:%d", s.ScheduleID, s.DueAt.Unix())
+
+	if s.Operation == model.TransferOperation {
+		transfer := dto.TransferRequest{
+			Amount:     s.Amount,
+			UserIDFrom: s.UserID,
+			UserIDTo:   s.UserIDTo,
+			Comment:    s.Comment,
+		}
+		err := ss.policy.Check(transferOperation(transfer))
+		if err != nil {
+			return err
+		}
+		key, err := idempotency.NewKey(value, string(model.TransferOperation), transfer)
+		if err != nil {
+			return err
+		}
+		ctx = idempotency.WithKey(ctx, key)
+		err = ss.reviews.screen(ctx, model.TransferOperation, transfer.UserIDFrom, []string{transfer.UserIDTo}, transfer.Amount, transfer)
+		if err != nil {
+			return err
+		}
+		return ss.repo.TransferMoney(ctx, transfer)
+	}
+
+	b := dto.BalanceChangeRequest{
+		Amount:  s.Amount,
+		UserID:  s.UserID,
+		Comment: s.Comment,
+	}
+	err := ss.policy.Check(policy.Operation{
+		Type:    model.ReduceOperation,
+		UserID:  b.UserID,
+		Amount:  b.Amount,
+		Comment: b.Comment,
+	})
+	if err != nil {
+		return err
+	}
+	key, err := idempotency.NewKey(value, string(model.ReduceOperation), b)
+	if err != nil {
+		return err
+	}
+	ctx = idempotency.WithKey(ctx, key)
+	err = ss.reviews.screen(ctx, model.ReduceOperation, b.UserID, nil, b.Amount, b)
+	if err != nil {
+		return err
+	}
+	_, err = ss.repo.ChangeUserBalance(ctx, b, model.Reduce)
+	return err
+}
+
+// advance переводит расписание к следующему плановому выполнению либо завершает разовое расписание
+func advance(s model.Schedule, now time.Time) model.Schedule {
+	s.Attempt = 0
+	due, ok := s.Next(now)
+	if !ok {
+		s.Status = model.CompletedSchedule
+		return s
+	}
+	s.DueAt, s.NextRunAt = due, due
+	return s
+}
+
+func retryBackoff(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 0; i < attempt && delay < maxScheduleRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxScheduleRetryDelay {
+		delay = maxScheduleRetryDelay
+	}
+	return delay
+}
+
+// joinErrors объединяет ошибки выполнения расписаний в одну. Первая ошибка обернута и доступна errors.Is
+func joinErrors(errs []error, total int) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		return fmt.Errorf("1 of %d schedules failed: %w", total, errs[0])
+	}
+	rest := make([]string, 0, len(errs)-1)
+	for _, err := range errs[1:] {
+		rest = append(rest, err.Error())
+	}
+	return fmt.Errorf("%d of %d schedules failed: %w; %s", len(errs), total, errs[0], strings.Join(rest, "; "))
+}
+
+// errorMessage описание ошибки для истории выполнений вместе с подробностями для разработчика
+func errorMessage(err error) string {
+	var appErr *apperror.AppError
+	if errors.As(err, &appErr) && appErr.DeveloperMessage != "" {
+		return fmt.Sprintf("%s: %s", appErr.Message, appErr.DeveloperMessage)
+	}
+	return err.Error()
+}
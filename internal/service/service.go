@@ -2,6 +2,7 @@ package service
 
 import (
 	"github.com/garet2gis/user_balance_service/internal/csv"
+	"github.com/garet2gis/user_balance_service/internal/policy"
 	"github.com/garet2gis/user_balance_service/internal/repository"
 	"github.com/garet2gis/user_balance_service/pkg/logging"
 )
@@ -11,13 +12,29 @@ type Service struct {
 	HistoryService
 	ReservationService
 	ReportService
+	IdempotencyService
+	AccountService
+	ScheduleService
+	CatalogService
+	FeeService
+	LimitService
+	ReviewService
 }
 
-func NewService(r *repository.Repository, csv *csv.Builder, l *logging.Logger) *Service {
+func NewService(r *repository.Repository, csv *csv.Builder, p *policy.Engine, l *logging.Logger) *Service {
+	reviews := NewReviewService(r, l)
+
 	return &Service{
-		BalanceService:     *NewBalanceService(r, l),
+		BalanceService:     *NewBalanceService(r, p, reviews, l),
 		HistoryService:     *NewHistoryService(r, l),
-		ReservationService: *NewReservationService(r, l),
+		ReservationService: *NewReservationService(r, p, l),
 		ReportService:      *NewReportService(r, csv, l),
+		IdempotencyService: *NewIdempotencyService(r, l),
+		AccountService:     *NewAccountService(r, l),
+		ScheduleService:    *NewScheduleService(r, p, reviews, l),
+		CatalogService:     *NewCatalogService(r, l),
+		FeeService:         *NewFeeService(r, l),
+		LimitService:       *NewLimitService(r, l),
+		ReviewService:      *reviews,
 	}
 }
@@ -0,0 +1,28 @@
+package worker
+
+import (
+	"context"
+	"github.com/garet2gis/user_balance_service/pkg/logging"
+	"time"
+)
+
+// Run вызывает job каждые interval, пока не будет отменен ctx. Блокирует вызывающую горутину
+func Run(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error, l *logging.Logger) {
+	logger := l.GetLoggerWithField("worker", name)
+	logger.Infof("started with interval %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("stopped")
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				logger.Errorf("job failed: %v", err)
+			}
+		}
+	}
+}
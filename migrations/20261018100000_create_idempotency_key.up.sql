@@ -0,0 +1,10 @@
+CREATE TABLE idempotency_key
+(
+    key          VARCHAR(255) PRIMARY KEY,
+    operation    VARCHAR(32) NOT NULL,
+    request_hash CHAR(64)    NOT NULL,
+    response     JSONB,
+    created_at   TIMESTAMP   NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+CREATE INDEX idx_idempotency_key_created_at ON idempotency_key (created_at);
@@ -0,0 +1,276 @@
+CREATE TYPE account_type AS ENUM ('user', 'reserved', 'revenue', 'cash');
+CREATE TABLE account
+(
+    account_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    type       account_type NOT NULL,
+    -- user_id для счетов user и reserved, service_id для revenue, нулевой UUID для cash
+    owner_id   UUID         NOT NULL,
+
+    CONSTRAINT uq_account UNIQUE (type, owner_id)
+);
+
+CREATE TYPE operation_type AS ENUM ('opening', 'replenish', 'reduce', 'transfer', 'reserve', 'confirm', 'cancel');
+CREATE TABLE journal
+(
+    journal_id UUID PRIMARY KEY         DEFAULT gen_random_uuid(),
+    operation  operation_type NOT NULL,
+    order_id   UUID                     DEFAULT NULL,
+    service_id UUID                     DEFAULT NULL,
+    comment    TEXT           NOT NULL DEFAULT '',
+    created_at TIMESTAMP      NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+
+    CONSTRAINT fk_service
+        FOREIGN KEY (service_id)
+            REFERENCES service (service_id)
+);
+
+-- amount > 0 - поступление на счет, amount < 0 - списание со счета
+CREATE TABLE posting
+(
+    posting_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    journal_id UUID           NOT NULL,
+    account_id UUID           NOT NULL,
+    amount     decimal(18, 2) NOT NULL CHECK ( amount <> 0 ),
+
+    CONSTRAINT fk_journal
+        FOREIGN KEY (journal_id)
+            REFERENCES journal (journal_id),
+
+    CONSTRAINT fk_account
+        FOREIGN KEY (account_id)
+            REFERENCES account (account_id)
+);
+
+CREATE INDEX idx_posting_journal_id ON posting (journal_id);
+CREATE INDEX idx_posting_account_id ON posting (account_id);
+
+-- сумма проводок каждого журнала обязана быть нулевой, проверяется при коммите транзакции
+CREATE FUNCTION check_journal_balanced() RETURNS trigger AS
+$$
+BEGIN
+    IF (SELECT SUM(amount) FROM posting WHERE journal_id = NEW.journal_id) <> 0 THEN
+        RAISE EXCEPTION 'journal % is not balanced', NEW.journal_id
+            USING ERRCODE = '23514', CONSTRAINT = 'posting_balanced_check';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER trg_posting_balanced
+    AFTER INSERT
+    ON posting
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW
+EXECUTE FUNCTION check_journal_balanced();
+
+-- проводки неизменяемы, исправления делаются новыми журналами
+CREATE FUNCTION forbid_posting_change() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'postings are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_posting_append_only
+    BEFORE UPDATE OR DELETE
+    ON posting
+    FOR EACH ROW
+EXECUTE FUNCTION forbid_posting_change();
+
+
+-- перенос существующих данных
+INSERT INTO account (type, owner_id)
+VALUES ('cash', '00000000-0000-0000-0000-000000000000');
+
+INSERT INTO account (type, owner_id)
+SELECT t.type, balance.user_id
+FROM balance
+         CROSS JOIN (VALUES ('user'::account_type), ('reserved'::account_type)) AS t(type);
+
+INSERT INTO account (type, owner_id)
+SELECT 'revenue', service_id
+FROM service;
+
+-- пополнения и списания: счет пользователя <-> внешняя касса
+INSERT INTO journal (journal_id, operation, comment, created_at)
+SELECT history_deposit_id,
+       CASE WHEN amount > 0 THEN 'replenish'::operation_type ELSE 'reduce'::operation_type END,
+       comment,
+       created_at
+FROM history_deposit
+WHERE from_user_id IS NULL
+  AND to_user_id IS NULL;
+
+INSERT INTO posting (journal_id, account_id, amount)
+SELECT history_deposit_id, account.account_id, history_deposit.amount
+FROM history_deposit
+         JOIN account ON account.type = 'user' AND account.owner_id = history_deposit.user_id
+WHERE from_user_id IS NULL
+  AND to_user_id IS NULL
+UNION ALL
+SELECT history_deposit_id, account.account_id, -history_deposit.amount
+FROM history_deposit
+         JOIN account ON account.type = 'cash'
+WHERE from_user_id IS NULL
+  AND to_user_id IS NULL;
+
+-- переводы хранились двумя строками, журнал строится по строке отправителя
+INSERT INTO journal (journal_id, operation, comment, created_at)
+SELECT history_deposit_id, 'transfer', comment, created_at
+FROM history_deposit
+WHERE to_user_id IS NOT NULL;
+
+INSERT INTO posting (journal_id, account_id, amount)
+SELECT history_deposit_id, account.account_id, history_deposit.amount
+FROM history_deposit
+         JOIN account ON account.type = 'user' AND account.owner_id = history_deposit.user_id
+WHERE to_user_id IS NOT NULL
+UNION ALL
+SELECT history_deposit_id, account.account_id, -history_deposit.amount
+FROM history_deposit
+         JOIN account ON account.type = 'user' AND account.owner_id = history_deposit.to_user_id
+WHERE to_user_id IS NOT NULL;
+
+-- каждой завершенной резервации предшествовало резервирование. Старая схема удаляла резервацию при завершении,
+-- и время ее создания не сохранилось: history_reservation.created_at - время подтверждения или отмены.
+-- Журнал резервирования датируется последним известным моментом до завершения, чтобы as_of не показывал
+-- завершение раньше резервирования; более ранний момент списания восстановить нельзя
+INSERT INTO journal (journal_id, operation, order_id, service_id, comment, created_at)
+SELECT md5(commit_reservation_id::text || 'reserve')::uuid,
+       'reserve',
+       order_id,
+       service_id,
+       comment,
+       created_at - INTERVAL '1 microsecond'
+FROM history_reservation;
+
+INSERT INTO journal (journal_id, operation, order_id, service_id, comment, created_at)
+SELECT commit_reservation_id,
+       status::text::operation_type,
+       order_id,
+       service_id,
+       comment,
+       created_at
+FROM history_reservation;
+
+INSERT INTO account (type, owner_id)
+SELECT DISTINCT t.type, history_reservation.user_id
+FROM history_reservation
+         CROSS JOIN (VALUES ('user'::account_type), ('reserved'::account_type)) AS t(type)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO posting (journal_id, account_id, amount)
+SELECT md5(commit_reservation_id::text || 'reserve')::uuid, account.account_id, -abs(cost)
+FROM history_reservation
+         JOIN account ON account.type = 'user' AND account.owner_id = history_reservation.user_id
+UNION ALL
+SELECT md5(commit_reservation_id::text || 'reserve')::uuid, account.account_id, abs(cost)
+FROM history_reservation
+         JOIN account ON account.type = 'reserved' AND account.owner_id = history_reservation.user_id
+UNION ALL
+SELECT commit_reservation_id, account.account_id, -abs(cost)
+FROM history_reservation
+         JOIN account ON account.type = 'reserved' AND account.owner_id = history_reservation.user_id
+UNION ALL
+SELECT commit_reservation_id, account.account_id, abs(cost)
+FROM history_reservation
+         JOIN account ON account.type = 'revenue' AND account.owner_id = history_reservation.service_id
+WHERE status = 'confirm'
+UNION ALL
+SELECT commit_reservation_id, account.account_id, abs(cost)
+FROM history_reservation
+         JOIN account ON account.type = 'user' AND account.owner_id = history_reservation.user_id
+WHERE status = 'cancel';
+
+-- открытые резервации
+INSERT INTO journal (journal_id, operation, order_id, service_id, comment, created_at)
+SELECT reservation_id, 'reserve', order_id, service_id, comment, created_at
+FROM reservation;
+
+INSERT INTO posting (journal_id, account_id, amount)
+SELECT reservation_id, account.account_id, -cost
+FROM reservation
+         JOIN account ON account.type = 'user' AND account.owner_id = reservation.user_id
+UNION ALL
+SELECT reservation_id, account.account_id, cost
+FROM reservation
+         JOIN account ON account.type = 'reserved' AND account.owner_id = reservation.user_id;
+
+-- история могла быть неполной: входящий остаток выравнивает сумму проводок с текущим балансом
+CREATE TEMPORARY TABLE opening_balance AS
+SELECT balance.user_id,
+       account.account_id,
+       balance.balance - COALESCE(SUM(posting.amount), 0) AS amount,
+       COALESCE(MIN(journal.created_at) - INTERVAL '1 microsecond',
+                now() AT TIME ZONE 'utc')                  AS created_at
+FROM balance
+         JOIN account ON account.type = 'user' AND account.owner_id = balance.user_id
+         LEFT JOIN posting USING (account_id)
+         LEFT JOIN journal USING (journal_id)
+GROUP BY balance.user_id, account.account_id, balance.balance;
+
+INSERT INTO journal (journal_id, operation, comment, created_at)
+SELECT md5(user_id::text || 'opening')::uuid, 'opening', 'opening balance', created_at
+FROM opening_balance
+WHERE amount <> 0;
+
+INSERT INTO posting (journal_id, account_id, amount)
+SELECT md5(user_id::text || 'opening')::uuid, opening_balance.account_id, amount
+FROM opening_balance
+WHERE amount <> 0
+UNION ALL
+SELECT md5(user_id::text || 'opening')::uuid, account.account_id, -amount
+FROM opening_balance
+         JOIN account ON account.type = 'cash'
+WHERE amount <> 0;
+
+DROP TABLE opening_balance;
+
+
+DROP VIEW balance_history;
+DROP TABLE history_deposit;
+DROP TABLE history_reservation;
+
+-- balance хранит текущий доступный остаток счета user и обновляется в одной транзакции с проводками
+CREATE VIEW account_balance AS
+SELECT account.account_id,
+       account.type,
+       account.owner_id,
+       COALESCE(SUM(posting.amount), 0) AS balance
+FROM account
+         LEFT JOIN posting USING (account_id)
+GROUP BY account.account_id, account.type, account.owner_id;
+
+-- история строится по проводкам счетов пользователя. Перемещения между собственными счетами
+-- (резервирование и его отмена) показываются только со стороны доступного остатка
+CREATE VIEW balance_history AS
+SELECT account.owner_id                                              as user_id,
+       CASE WHEN posting.amount > 0 THEN counterpart.owner_id END    as from_user_id,
+       CASE WHEN posting.amount < 0 THEN counterpart.owner_id END    as to_user_id,
+       journal.order_id,
+       COALESCE(service.name, '')                                    as service_name,
+       journal.created_at                                            as create_date,
+       posting.amount,
+       journal.comment,
+       journal.operation::varchar(32)                                as transaction_type
+FROM posting
+         JOIN account USING (account_id)
+         JOIN journal USING (journal_id)
+         LEFT JOIN service ON service.service_id = journal.service_id
+         LEFT JOIN LATERAL (
+    SELECT other_account.owner_id
+    FROM posting other_posting
+             JOIN account other_account USING (account_id)
+    WHERE other_posting.journal_id = posting.journal_id
+      AND other_account.type = 'user'
+      AND other_account.owner_id <> account.owner_id
+    LIMIT 1
+    ) counterpart ON TRUE
+WHERE account.type = 'user'
+   OR (account.type = 'reserved' AND NOT EXISTS(
+        SELECT 1
+        FROM posting own_posting
+                 JOIN account own_account USING (account_id)
+        WHERE own_posting.journal_id = posting.journal_id
+          AND own_account.type = 'user'
+          AND own_account.owner_id = account.owner_id));
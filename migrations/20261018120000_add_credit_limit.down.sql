@@ -0,0 +1,7 @@
+ALTER TABLE balance
+    DROP CONSTRAINT balance_balance_check;
+ALTER TABLE balance
+    ADD CONSTRAINT balance_balance_check CHECK ( balance >= 0 );
+
+ALTER TABLE balance
+    DROP COLUMN credit_limit;
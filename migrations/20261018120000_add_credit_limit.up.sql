@@ -0,0 +1,8 @@
+ALTER TABLE balance
+    ADD COLUMN credit_limit decimal(18, 2) NOT NULL DEFAULT 0 CHECK ( credit_limit >= 0 );
+
+-- баланс может уйти в минус, но не больше кредитного лимита
+ALTER TABLE balance
+    DROP CONSTRAINT balance_balance_check;
+ALTER TABLE balance
+    ADD CONSTRAINT balance_balance_check CHECK ( balance >= -credit_limit );
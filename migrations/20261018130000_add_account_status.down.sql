@@ -0,0 +1,39 @@
+DROP VIEW IF EXISTS balance_history;
+CREATE VIEW balance_history AS
+SELECT account.owner_id                                              as user_id,
+       CASE WHEN posting.amount > 0 THEN counterpart.owner_id END    as from_user_id,
+       CASE WHEN posting.amount < 0 THEN counterpart.owner_id END    as to_user_id,
+       journal.order_id,
+       COALESCE(service.name, '')                                    as service_name,
+       journal.created_at                                            as create_date,
+       posting.amount,
+       journal.comment,
+       journal.operation::varchar(32)                                as transaction_type
+FROM posting
+         JOIN account USING (account_id)
+         JOIN journal USING (journal_id)
+         LEFT JOIN service ON service.service_id = journal.service_id
+         LEFT JOIN LATERAL (
+    SELECT other_account.owner_id
+    FROM posting other_posting
+             JOIN account other_account USING (account_id)
+    WHERE other_posting.journal_id = posting.journal_id
+      AND other_account.type = 'user'
+      AND other_account.owner_id <> account.owner_id
+    LIMIT 1
+    ) counterpart ON TRUE
+WHERE account.type = 'user'
+   OR (account.type = 'reserved' AND NOT EXISTS(
+        SELECT 1
+        FROM posting own_posting
+                 JOIN account own_account USING (account_id)
+        WHERE own_posting.journal_id = posting.journal_id
+          AND own_account.type = 'user'
+          AND own_account.owner_id = account.owner_id));
+
+DROP TABLE IF EXISTS account_status_history CASCADE;
+
+ALTER TABLE balance
+    DROP COLUMN status;
+
+DROP TYPE IF EXISTS account_status CASCADE;
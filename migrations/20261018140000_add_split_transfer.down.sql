@@ -0,0 +1,54 @@
+DROP VIEW IF EXISTS balance_history;
+CREATE VIEW balance_history AS
+SELECT account.owner_id                                              as user_id,
+       CASE WHEN posting.amount > 0 THEN counterpart.owner_id END    as from_user_id,
+       CASE WHEN posting.amount < 0 THEN counterpart.owner_id END    as to_user_id,
+       journal.order_id,
+       COALESCE(service.name, '')                                    as service_name,
+       journal.created_at                                            as create_date,
+       posting.amount,
+       journal.comment,
+       journal.operation::varchar(32)                                as transaction_type
+FROM posting
+         JOIN account USING (account_id)
+         JOIN journal USING (journal_id)
+         LEFT JOIN service ON service.service_id = journal.service_id
+         LEFT JOIN LATERAL (
+    SELECT other_account.owner_id
+    FROM posting other_posting
+             JOIN account other_account USING (account_id)
+    WHERE other_posting.journal_id = posting.journal_id
+      AND other_account.type = 'user'
+      AND other_account.owner_id <> account.owner_id
+    LIMIT 1
+    ) counterpart ON TRUE
+WHERE account.type = 'user'
+   OR (account.type = 'reserved' AND NOT EXISTS(
+        SELECT 1
+        FROM posting own_posting
+                 JOIN account own_account USING (account_id)
+        WHERE own_posting.journal_id = posting.journal_id
+          AND own_account.type = 'user'
+          AND own_account.owner_id = account.owner_id))
+
+UNION ALL
+
+SELECT account_status_history.user_id,
+       CAST(NULL AS UUID)                       as from_user_id,
+       CAST(NULL AS UUID)                       as to_user_id,
+       CAST(NULL AS UUID)                       as order_id,
+       ''                                       as service_name,
+       account_status_history.created_at        as create_date,
+       CAST(0 AS decimal(18, 2))                as amount,
+       account_status_history.comment,
+       CASE account_status_history.status
+           WHEN 'frozen' THEN 'freeze'
+           WHEN 'active' THEN 'unfreeze'
+           WHEN 'closed' THEN 'close'
+           END::varchar(32)                     as transaction_type
+FROM account_status_history;
+
+ALTER TABLE posting
+    DROP COLUMN comment;
+
+-- значение split_transfer из operation_type удалить нельзя, оно остается неиспользуемым
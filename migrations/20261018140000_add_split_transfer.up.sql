@@ -0,0 +1,62 @@
+-- разделенный перевод: один журнал со списанием у отправителя и зачислениями нескольким получателям
+ALTER TYPE operation_type ADD VALUE 'split_transfer';
+
+-- собственный комментарий проводки, например, для каждого получателя разделенного перевода
+ALTER TABLE posting
+    ADD COLUMN comment TEXT NOT NULL DEFAULT '';
+
+DROP VIEW balance_history;
+CREATE VIEW balance_history AS
+SELECT account.owner_id                                                    as user_id,
+       CASE WHEN posting.amount > 0 THEN counterpart.owner_id END          as from_user_id,
+       CASE WHEN posting.amount < 0 THEN counterpart.owner_id END          as to_user_id,
+       journal.order_id,
+       COALESCE(service.name, '')                                          as service_name,
+       journal.created_at                                                  as create_date,
+       posting.amount,
+       COALESCE(NULLIF(posting.comment, ''), journal.comment)              as comment,
+       journal.operation::varchar(32)                                      as transaction_type,
+       CASE WHEN journal.operation::text = 'split_transfer' THEN journal.journal_id END as group_id
+FROM posting
+         JOIN account USING (account_id)
+         JOIN journal USING (journal_id)
+         LEFT JOIN service ON service.service_id = journal.service_id
+         -- контрагент - единственный другой пользователь с проводкой противоположного знака,
+         -- у отправителя разделенного перевода получателей несколько и контрагент не заполняется
+         LEFT JOIN LATERAL (
+    SELECT CASE
+               WHEN COUNT(DISTINCT other_account.owner_id) = 1
+                   THEN (array_agg(other_account.owner_id))[1] END as owner_id
+    FROM posting other_posting
+             JOIN account other_account USING (account_id)
+    WHERE other_posting.journal_id = posting.journal_id
+      AND other_account.type = 'user'
+      AND other_account.owner_id <> account.owner_id
+      AND sign(other_posting.amount) <> sign(posting.amount)
+    ) counterpart ON TRUE
+WHERE account.type = 'user'
+   OR (account.type = 'reserved' AND NOT EXISTS(
+        SELECT 1
+        FROM posting own_posting
+                 JOIN account own_account USING (account_id)
+        WHERE own_posting.journal_id = posting.journal_id
+          AND own_account.type = 'user'
+          AND own_account.owner_id = account.owner_id))
+
+UNION ALL
+
+SELECT account_status_history.user_id,
+       CAST(NULL AS UUID)                       as from_user_id,
+       CAST(NULL AS UUID)                       as to_user_id,
+       CAST(NULL AS UUID)                       as order_id,
+       ''                                       as service_name,
+       account_status_history.created_at        as create_date,
+       CAST(0 AS decimal(18, 2))                as amount,
+       account_status_history.comment,
+       CASE account_status_history.status
+           WHEN 'frozen' THEN 'freeze'
+           WHEN 'active' THEN 'unfreeze'
+           WHEN 'closed' THEN 'close'
+           END::varchar(32)                     as transaction_type,
+       CAST(NULL AS UUID)                       as group_id
+FROM account_status_history;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS schedule_run CASCADE;
+DROP TYPE IF EXISTS schedule_run_status CASCADE;
+DROP TABLE IF EXISTS schedule CASCADE;
+DROP TYPE IF EXISTS schedule_recurrence CASCADE;
+DROP TYPE IF EXISTS schedule_status CASCADE;
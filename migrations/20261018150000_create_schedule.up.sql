@@ -0,0 +1,49 @@
+CREATE TYPE schedule_status AS ENUM ('active', 'paused', 'cancelled', 'completed', 'failed');
+CREATE TYPE schedule_recurrence AS ENUM ('once', 'daily', 'weekly', 'monthly');
+
+CREATE TABLE schedule
+(
+    schedule_id  UUID PRIMARY KEY             DEFAULT gen_random_uuid(),
+    operation    operation_type      NOT NULL CHECK ( operation IN ('reduce', 'transfer') ),
+    user_id      UUID                NOT NULL,
+    user_id_to   UUID                         DEFAULT NULL,
+    amount       decimal(18, 2)      NOT NULL CHECK ( amount > 0 ),
+    comment      TEXT                NOT NULL DEFAULT '',
+    recurrence   schedule_recurrence NOT NULL DEFAULT 'once',
+    -- день месяца для recurrence = 'monthly', в коротких месяцах используется последний день
+    day_of_month SMALLINT                     DEFAULT NULL CHECK ( day_of_month BETWEEN 1 AND 31 ),
+    status       schedule_status     NOT NULL DEFAULT 'active',
+    -- плановое время текущего выполнения, по нему строится ключ идемпотентности
+    due_at       TIMESTAMP           NOT NULL,
+    -- время следующей попытки: due_at или позже при повторе после ошибки
+    next_run_at  TIMESTAMP           NOT NULL,
+    attempt      INT                 NOT NULL DEFAULT 0,
+    max_retries  INT                 NOT NULL DEFAULT 3 CHECK ( max_retries >= 0 ),
+    -- экземпляр сервиса, взявший расписание в работу, владеет им до locked_until
+    locked_until TIMESTAMP                    DEFAULT NULL,
+    created_at   TIMESTAMP           NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+
+    CONSTRAINT transfer_recipient_check CHECK ( (operation = 'transfer') = (user_id_to IS NOT NULL) )
+);
+
+CREATE INDEX idx_schedule_user_id ON schedule (user_id);
+CREATE INDEX idx_schedule_next_run_at ON schedule (next_run_at) WHERE status = 'active';
+
+CREATE TYPE schedule_run_status AS ENUM ('succeeded', 'failed');
+
+CREATE TABLE schedule_run
+(
+    schedule_run_id UUID PRIMARY KEY             DEFAULT gen_random_uuid(),
+    schedule_id     UUID                NOT NULL,
+    due_at          TIMESTAMP           NOT NULL,
+    attempt         INT                 NOT NULL,
+    status          schedule_run_status NOT NULL,
+    error           TEXT                NOT NULL DEFAULT '',
+    executed_at     TIMESTAMP           NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+
+    CONSTRAINT fk_schedule
+        FOREIGN KEY (schedule_id)
+            REFERENCES schedule (schedule_id)
+);
+
+CREATE INDEX idx_schedule_run_schedule_id ON schedule_run (schedule_id);
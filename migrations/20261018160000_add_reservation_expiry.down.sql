@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_reservation_expires_at;
+
+ALTER TABLE reservation
+    DROP COLUMN expires_at;
+
+-- значения expired из reservation_status и operation_type удалить нельзя, они остаются неиспользуемыми
@@ -0,0 +1,9 @@
+ALTER TYPE reservation_status ADD VALUE 'expired';
+-- возврат денег по истечении срока резерва отличается в истории от отмены
+ALTER TYPE operation_type ADD VALUE 'expired';
+
+-- срок резерва фиксируется при резервировании, NULL - бессрочный резерв. Существующие резервы остаются бессрочными
+ALTER TABLE reservation
+    ADD COLUMN expires_at TIMESTAMP DEFAULT NULL;
+
+CREATE INDEX idx_reservation_expires_at ON reservation (expires_at);
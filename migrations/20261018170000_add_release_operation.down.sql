@@ -0,0 +1 @@
+-- значение release из operation_type удалить нельзя, оно остается неиспользуемым
@@ -0,0 +1,2 @@
+-- возврат пользователю неподтвержденной части резерва при частичном подтверждении
+ALTER TYPE operation_type ADD VALUE 'release';
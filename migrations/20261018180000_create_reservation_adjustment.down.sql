@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reservation_adjustment;
+
+-- значение adjust из operation_type удалить нельзя, оно остается неиспользуемым
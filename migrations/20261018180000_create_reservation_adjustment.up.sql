@@ -0,0 +1,18 @@
+-- изменение суммы действующего резерва
+ALTER TYPE operation_type ADD VALUE 'adjust';
+
+-- журнал изменений суммы резерва, сохраняется после подтверждения или отмены резерва
+CREATE TABLE reservation_adjustment
+(
+    adjustment_id  UUID PRIMARY KEY        DEFAULT gen_random_uuid(),
+    reservation_id UUID           NOT NULL,
+    user_id        UUID           NOT NULL,
+    order_id       UUID           NOT NULL,
+    service_id     UUID           NOT NULL,
+    old_cost       decimal(18, 2) NOT NULL,
+    new_cost       decimal(18, 2) NOT NULL CHECK ( new_cost > 0 ),
+    comment        TEXT           NOT NULL DEFAULT '',
+    created_at     TIMESTAMP      NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+CREATE INDEX idx_reservation_adjustment_reservation_id ON reservation_adjustment (reservation_id);
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_reservation_order_service;
+DROP INDEX IF EXISTS uq_reservation_active;
+
+DELETE
+FROM reservation
+WHERE status IS NOT NULL;
+
+ALTER TABLE reservation
+    ADD CONSTRAINT uq_reservation UNIQUE (user_id, order_id, service_id, cost);
+
+ALTER TABLE reservation
+    DROP COLUMN closed_at,
+    DROP COLUMN captured,
+    DROP COLUMN status;
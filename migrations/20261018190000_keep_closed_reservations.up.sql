@@ -0,0 +1,13 @@
+-- подтвержденные, отмененные и просроченные резервы больше не удаляются, NULL - резерв действует
+ALTER TABLE reservation
+    ADD COLUMN status    reservation_status DEFAULT NULL,
+    -- подтвержденная сумма, для частичного подтверждения меньше cost
+    ADD COLUMN captured  decimal(18, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN closed_at TIMESTAMP               DEFAULT NULL;
+
+-- такой же резерв можно создать повторно после закрытия предыдущего
+ALTER TABLE reservation
+    DROP CONSTRAINT uq_reservation;
+CREATE UNIQUE INDEX uq_reservation_active ON reservation (user_id, order_id, service_id, cost) WHERE status IS NULL;
+
+CREATE INDEX idx_reservation_order_service ON reservation (order_id, service_id);
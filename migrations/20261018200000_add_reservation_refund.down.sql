@@ -0,0 +1,5 @@
+ALTER TABLE reservation
+    DROP CONSTRAINT reservation_refunded_check,
+    DROP COLUMN refunded;
+
+-- значение refund из operation_type удалить нельзя, оно остается неиспользуемым
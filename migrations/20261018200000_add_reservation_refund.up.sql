@@ -0,0 +1,6 @@
+-- возврат денег по подтвержденному резерву
+ALTER TYPE operation_type ADD VALUE 'refund';
+
+ALTER TABLE reservation
+    ADD COLUMN refunded decimal(18, 2) NOT NULL DEFAULT 0,
+    ADD CONSTRAINT reservation_refunded_check CHECK ( refunded >= 0 AND refunded <= captured );
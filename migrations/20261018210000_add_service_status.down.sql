@@ -0,0 +1,3 @@
+ALTER TABLE service
+    DROP COLUMN created_at,
+    DROP COLUMN active;
@@ -0,0 +1,4 @@
+-- неактивная услуга недоступна для новых резервов, но остается в истории и отчетах
+ALTER TABLE service
+    ADD COLUMN active     BOOLEAN   NOT NULL DEFAULT TRUE,
+    ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
@@ -0,0 +1 @@
+DROP TABLE service_price;
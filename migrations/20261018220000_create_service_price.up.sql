@@ -0,0 +1,19 @@
+-- прайс-лист услуги: фиксированная цена (price) либо допустимый диапазон (min_price, max_price).
+-- Действует цена с наибольшим valid_from среди периодов, в которые попадает текущее время
+CREATE TABLE service_price
+(
+    price_id   UUID PRIMARY KEY        DEFAULT gen_random_uuid(),
+    service_id UUID           NOT NULL REFERENCES service (service_id),
+    price      decimal(18, 2)          DEFAULT NULL CHECK ( price > 0 ),
+    min_price  decimal(18, 2)          DEFAULT NULL CHECK ( min_price > 0 ),
+    max_price  decimal(18, 2)          DEFAULT NULL CHECK ( max_price > 0 ),
+    valid_from TIMESTAMP      NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    valid_to   TIMESTAMP               DEFAULT NULL,
+    created_at TIMESTAMP      NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+
+    CONSTRAINT service_price_kind_check CHECK ( (price IS NOT NULL) <> (min_price IS NOT NULL OR max_price IS NOT NULL) ),
+    CONSTRAINT service_price_range_check CHECK ( min_price <= max_price ),
+    CONSTRAINT service_price_period_check CHECK ( valid_to > valid_from )
+);
+
+CREATE INDEX idx_service_price_service_id ON service_price (service_id, valid_from);
@@ -0,0 +1,2 @@
+-- значения fee из account_type и operation_type удалить нельзя, они остаются неиспользуемыми
+DROP TABLE fee_rule;
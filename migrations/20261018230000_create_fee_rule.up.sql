@@ -0,0 +1,25 @@
+-- системный счет комиссий и журнал списания комиссии с пользователя
+ALTER TYPE account_type ADD VALUE 'fee';
+ALTER TYPE operation_type ADD VALUE 'fee';
+
+-- правило комиссии: flat + percent% от суммы операции, ограниченное min_fee и max_fee.
+-- Правило услуги важнее общего правила операции (service_id IS NULL). Разделенный перевод платит по правилу transfer
+CREATE TABLE fee_rule
+(
+    fee_rule_id UUID PRIMARY KEY        DEFAULT gen_random_uuid(),
+    operation   operation_type NOT NULL CHECK ( operation IN ('transfer', 'reserve', 'confirm') ),
+    service_id  UUID                    DEFAULT NULL REFERENCES service (service_id),
+    flat        decimal(18, 2) NOT NULL DEFAULT 0 CHECK ( flat >= 0 ),
+    percent     decimal(7, 4)  NOT NULL DEFAULT 0 CHECK ( percent >= 0 AND percent <= 100 ),
+    min_fee     decimal(18, 2) NOT NULL DEFAULT 0 CHECK ( min_fee >= 0 ),
+    max_fee     decimal(18, 2)          DEFAULT NULL CHECK ( max_fee > 0 ),
+    active      BOOLEAN        NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMP      NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+
+    CONSTRAINT fee_rule_service_check CHECK ( operation <> 'transfer' OR service_id IS NULL ),
+    CONSTRAINT fee_rule_caps_check CHECK ( min_fee <= max_fee )
+);
+
+-- для операции и услуги действует не больше одного правила
+CREATE UNIQUE INDEX uq_fee_rule_active ON fee_rule (operation, COALESCE(service_id, '00000000-0000-0000-0000-000000000000'))
+    WHERE active;
@@ -0,0 +1,2 @@
+DROP TABLE spending_limit;
+DROP TYPE limit_period;
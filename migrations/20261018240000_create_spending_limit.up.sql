@@ -0,0 +1,14 @@
+CREATE TYPE limit_period AS ENUM ('single', 'daily', 'weekly', 'monthly');
+
+-- лимиты расходных операций пользователя. Строки с нулевым user_id - общие лимиты из конфигурации,
+-- лимит пользователя заменяет общий лимит той же операции и периода
+CREATE TABLE spending_limit
+(
+    user_id    UUID           NOT NULL,
+    operation  operation_type NOT NULL CHECK ( operation IN ('reduce', 'transfer', 'reserve') ),
+    period     limit_period   NOT NULL,
+    amount     decimal(18, 2) NOT NULL CHECK ( amount > 0 ),
+    updated_at TIMESTAMP      NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+
+    PRIMARY KEY (user_id, operation, period)
+);
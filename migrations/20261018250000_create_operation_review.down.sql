@@ -0,0 +1,2 @@
+DROP TABLE operation_review;
+DROP TYPE review_status;
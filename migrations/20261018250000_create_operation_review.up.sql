@@ -0,0 +1,28 @@
+CREATE TYPE review_status AS ENUM ('pending', 'approved', 'rejected', 'failed');
+
+-- операции с высокой оценкой риска, отложенные до решения администратора
+CREATE TABLE operation_review
+(
+    review_id       UUID PRIMARY KEY        DEFAULT gen_random_uuid(),
+    operation       operation_type NOT NULL CHECK ( operation IN ('reduce', 'transfer') ),
+    user_id         UUID           NOT NULL,
+    user_id_to      UUID                    DEFAULT NULL,
+    amount          decimal(18, 2) NOT NULL CHECK ( amount > 0 ),
+    -- исходный запрос, выполняется при одобрении
+    request         JSONB          NOT NULL,
+    score           INT            NOT NULL,
+    reasons         TEXT[]         NOT NULL DEFAULT '{}',
+    status          review_status  NOT NULL DEFAULT 'pending',
+    -- ключ идемпотентности исходного запроса, с ним же операция выполняется при одобрении
+    idempotency_key TEXT                    DEFAULT NULL,
+    request_hash    TEXT                    DEFAULT NULL,
+    -- комментарий администратора и ошибка выполнения одобренной операции
+    comment         TEXT           NOT NULL DEFAULT '',
+    error           TEXT           NOT NULL DEFAULT '',
+    created_at      TIMESTAMP      NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    decided_at      TIMESTAMP               DEFAULT NULL
+);
+
+CREATE UNIQUE INDEX uq_operation_review_idempotency_key ON operation_review (idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+CREATE INDEX idx_operation_review_status ON operation_review (status, created_at);
@@ -0,0 +1,4 @@
+DROP TRIGGER trg_balance_version ON balance;
+DROP FUNCTION increment_balance_version();
+ALTER TABLE balance
+    DROP COLUMN version;
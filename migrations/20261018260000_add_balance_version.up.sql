@@ -0,0 +1,19 @@
+-- версия строки баланса для оптимистичной блокировки (ETag / If-Match)
+ALTER TABLE balance
+    ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- версия растет при любом изменении строки баланса, в том числе статуса и кредитного лимита
+CREATE FUNCTION increment_balance_version() RETURNS trigger AS
+$$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_balance_version
+    BEFORE UPDATE
+    ON balance
+    FOR EACH ROW
+    WHEN (OLD.* IS DISTINCT FROM NEW.*)
+EXECUTE FUNCTION increment_balance_version();
@@ -0,0 +1,11 @@
+-- значение pending_review из schedule_run_status удалить нельзя, оно остается неиспользуемым
+ALTER TABLE schedule_run
+    DROP COLUMN review_id;
+
+DELETE
+FROM operation_review
+WHERE operation = 'split_transfer';
+ALTER TABLE operation_review
+    DROP CONSTRAINT operation_review_operation_check;
+ALTER TABLE operation_review
+    ADD CONSTRAINT operation_review_operation_check CHECK ( operation IN ('reduce', 'transfer') );
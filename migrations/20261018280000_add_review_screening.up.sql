@@ -0,0 +1,11 @@
+-- на проверку отправляются и разделенные переводы
+ALTER TABLE operation_review
+    DROP CONSTRAINT operation_review_operation_check;
+ALTER TABLE operation_review
+    ADD CONSTRAINT operation_review_operation_check CHECK ( operation IN ('reduce', 'transfer', 'split_transfer') );
+
+-- выполнение расписания, отправленное на проверку, и его заявка
+ALTER TYPE schedule_run_status ADD VALUE 'pending_review';
+
+ALTER TABLE schedule_run
+    ADD COLUMN review_id UUID DEFAULT NULL;
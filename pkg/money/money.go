@@ -0,0 +1,215 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Scale количество знаков после запятой, совпадает с decimal(18, 2) в БД
+const Scale = 2
+
+const minorInMajor = 100
+
+var (
+	ErrInvalidAmount = errors.New("invalid money amount")
+	ErrTooPrecise    = fmt.Errorf("%w: more than %d fractional digits", ErrInvalidAmount, Scale)
+	ErrOverflow      = fmt.Errorf("%w: value out of range", ErrInvalidAmount)
+)
+
+// Amount денежная сумма в минимальных единицах (копейках).
+//
+// Правила округления:
+//   - суммы, пришедшие от клиента, никогда не округляются: значение с более чем
+//     двумя значащими знаками после запятой отклоняется (ErrTooPrecise);
+//   - вычисляемые суммы (проценты, доли) округляются функцией Round
+//     по правилу half away from zero: 0.005 -> 0.01, -0.005 -> -0.01.
+type Amount int64
+
+// FromMinor создает сумму из количества копеек
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// Parse разбирает десятичную запись суммы ("120", "-20.5", "0.01").
+// Лишние нули после второго знака допускаются, любые другие цифры - нет.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" || (hasDot && fracPart == "") || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	if len(fracPart) > Scale {
+		if strings.Trim(fracPart[Scale:], "0") != "" {
+			return 0, ErrTooPrecise
+		}
+		fracPart = fracPart[:Scale]
+	}
+	fracPart += strings.Repeat("0", Scale-len(fracPart))
+
+	major, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || major > math.MaxInt64/minorInMajor-1 {
+		return 0, ErrOverflow
+	}
+	minor, _ := strconv.ParseInt(fracPart, 10, 64)
+
+	a := Amount(major*minorInMajor + minor)
+	if negative {
+		a = -a
+	}
+	return a, nil
+}
+
+// MustParse аналог Parse, паникующий при ошибке. Предназначен для констант и тестов
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Round переводит произвольную дробь в рублях в Amount, округляя half away from zero.
+// Если результат не помещается в Amount, возвращается ErrOverflow
+func Round(r *big.Rat) (Amount, error) {
+	minor := new(big.Rat).Mul(r, big.NewRat(minorInMajor, 1))
+
+	q, m := new(big.Int).QuoRem(minor.Num(), minor.Denom(), new(big.Int))
+	// |остаток| * 2 >= знаменатель -> округляем от нуля
+	if m.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(minor.Denom()) >= 0 {
+		if minor.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	if !q.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return Amount(q.Int64()), nil
+}
+
+// Minor возвращает сумму в копейках
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// Rat возвращает сумму в рублях в виде точной дроби
+func (a Amount) Rat() *big.Rat {
+	return big.NewRat(int64(a), minorInMajor)
+}
+
+func (a Amount) String() string {
+	sign := ""
+	minor := int64(a)
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/minorInMajor, minor%minorInMajor)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	// допускаем сумму строкой: "10.50"
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	if strings.ContainsAny(s, "eE") {
+		return fmt.Errorf("%w: exponent notation is not allowed", ErrInvalidAmount)
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// UnmarshalText разбирает сумму из текстовых форматов, например YAML
+func (a *Amount) UnmarshalText(text []byte) error {
+	return a.SetValue(string(text))
+}
+
+// SetValue разбирает сумму из переменной окружения при чтении конфигурации
+func (a *Amount) SetValue(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a *Amount) ScanNumeric(v pgtype.Numeric) error {
+	if !v.Valid {
+		*a = 0
+		return nil
+	}
+	if v.NaN || v.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("%w: cannot scan %v", ErrInvalidAmount, v)
+	}
+
+	r := new(big.Rat).SetInt(v.Int)
+	exp := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(v.Exp))), nil))
+	if v.Exp < 0 {
+		r.Quo(r, exp)
+	} else {
+		r.Mul(r, exp)
+	}
+
+	rounded, err := Round(r)
+	if err != nil {
+		return err
+	}
+	*a = rounded
+	return nil
+}
+
+func (a Amount) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(a)), Exp: -Scale, Valid: true}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
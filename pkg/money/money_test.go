@@ -0,0 +1,145 @@
+package money
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		err  error
+	}{
+		{in: "120", want: 12000},
+		{in: "0.01", want: 1},
+		{in: "-20.5", want: -2050},
+		{in: "+7.25", want: 725},
+		{in: " 3 ", want: 300},
+		{in: "1.500", want: 150},
+		{in: "-0.10000", want: -10},
+		{in: "92233720368547757.99", want: 9223372036854775799},
+		{in: "1.001", err: ErrTooPrecise},
+		{in: "-0.005", err: ErrTooPrecise},
+		{in: "92233720368547758", err: ErrOverflow},
+		{in: "99999999999999999999", err: ErrOverflow},
+		{in: "1e2", err: ErrInvalidAmount},
+		{in: "1.5e1", err: ErrInvalidAmount},
+		{in: "", err: ErrInvalidAmount},
+		{in: "-", err: ErrInvalidAmount},
+		{in: ".5", err: ErrInvalidAmount},
+		{in: "5.", err: ErrInvalidAmount},
+		{in: "1,5", err: ErrInvalidAmount},
+		{in: "--1", err: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRound(t *testing.T) {
+	huge := new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 63))
+
+	tests := []struct {
+		name string
+		in   *big.Rat
+		want Amount
+		err  error
+	}{
+		{name: "exact", in: big.NewRat(1205, 100), want: 1205},
+		{name: "half up", in: big.NewRat(1, 200), want: 1},
+		{name: "half away from zero", in: big.NewRat(-1, 200), want: -1},
+		{name: "below half", in: big.NewRat(1, 300), want: 0},
+		{name: "negative below half", in: big.NewRat(-1, 300), want: 0},
+		{name: "above half", in: big.NewRat(-2, 300), want: -1},
+		{name: "overflow", in: huge, err: ErrOverflow},
+		{name: "negative overflow", in: new(big.Rat).Neg(huge), err: ErrOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Round(tt.in)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		err  error
+	}{
+		{in: `10.5`, want: 1050},
+		{in: `"10.50"`, want: 1050},
+		{in: `-3`, want: -300},
+		{in: `null`, want: 42},
+		{in: `1.005`, err: ErrTooPrecise},
+		{in: `1e2`, err: ErrInvalidAmount},
+		{in: `"1E2"`, err: ErrInvalidAmount},
+		{in: `92233720368547758`, err: ErrOverflow},
+		{in: `"ten"`, err: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			// null не меняет значение
+			got := Amount(42)
+			err := got.UnmarshalJSON([]byte(tt.in))
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestScanNumeric(t *testing.T) {
+	tests := []struct {
+		name string
+		in   pgtype.Numeric
+		want Amount
+		err  error
+	}{
+		{name: "scale 2", in: pgtype.Numeric{Int: big.NewInt(12345), Exp: -2, Valid: true}, want: 12345},
+		{name: "negative", in: pgtype.Numeric{Int: big.NewInt(-5), Exp: -1, Valid: true}, want: -50},
+		{name: "positive exponent", in: pgtype.Numeric{Int: big.NewInt(12), Exp: 3, Valid: true}, want: 1200000},
+		{name: "rounded", in: pgtype.Numeric{Int: big.NewInt(12345), Exp: -4, Valid: true}, want: 123},
+		{name: "null", in: pgtype.Numeric{}, want: 0},
+		{name: "nan", in: pgtype.Numeric{NaN: true, Valid: true}, err: ErrInvalidAmount},
+		{name: "infinity", in: pgtype.Numeric{InfinityModifier: pgtype.Infinity, Valid: true}, err: ErrInvalidAmount},
+		{name: "overflow", in: pgtype.Numeric{Int: big.NewInt(1), Exp: 30, Valid: true}, err: ErrOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Amount(42)
+			err := got.ScanNumeric(tt.in)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
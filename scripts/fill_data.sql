@@ -11,7 +11,39 @@ VALUES ('34e16535-480c-43f8-95a9-b7a503499af0', 'Курьерская доста
        ('34e16535-480c-43f8-95a9-b7a503499af1', 'Бронирование'),
        ('34e16535-480c-43f8-95a9-b7a503499af2', 'Дополнительная гарантия для товара');
 
+INSERT INTO account (account_id, type, owner_id)
+VALUES ('acc00000-0000-0000-0000-000000000069', 'user', '7a13445c-d6df-4111-abc0-abb12f610069'),
+       ('acc00000-0000-0000-0000-000000000068', 'user', '7a13445c-d6df-4111-abc0-abb12f610068'),
+       ('acc00000-0000-0000-0000-000000000062', 'user', '7a13445c-d6df-4111-abc0-abb12f610062'),
+       ('acc00000-0000-0000-0000-000000000065', 'user', '7a13445c-d6df-4111-abc0-abb12f610065'),
+       ('acc00000-0000-0000-0000-000000001068', 'reserved', '7a13445c-d6df-4111-abc0-abb12f610068'),
+       ('acc00000-0000-0000-0000-000000001065', 'reserved', '7a13445c-d6df-4111-abc0-abb12f610065'),
+       ('acc00000-0000-0000-0000-0000000002f0', 'revenue', '34e16535-480c-43f8-95a9-b7a503499af0'),
+       ('acc00000-0000-0000-0000-0000000002f1', 'revenue', '34e16535-480c-43f8-95a9-b7a503499af1'),
+       ('acc00000-0000-0000-0000-0000000002f2', 'revenue', '34e16535-480c-43f8-95a9-b7a503499af2');
 
+-- начальные остатки, совпадающие с balance с учетом резервов ниже
+INSERT INTO journal (journal_id, operation, comment)
+VALUES ('00000000-0000-0000-0000-000000000069', 'opening', 'opening balance'),
+       ('00000000-0000-0000-0000-000000000068', 'opening', 'opening balance'),
+       ('00000000-0000-0000-0000-000000000062', 'opening', 'opening balance'),
+       ('00000000-0000-0000-0000-000000000065', 'opening', 'opening balance');
+
+INSERT INTO posting (journal_id, account_id, amount)
+SELECT journal_id, account_id, amount
+FROM (VALUES ('00000000-0000-0000-0000-000000000069'::uuid, 'acc00000-0000-0000-0000-000000000069'::uuid, 500.34),
+             ('00000000-0000-0000-0000-000000000068', 'acc00000-0000-0000-0000-000000000068', 171),
+             ('00000000-0000-0000-0000-000000000062', 'acc00000-0000-0000-0000-000000000062', 32.32),
+             ('00000000-0000-0000-0000-000000000065', 'acc00000-0000-0000-0000-000000000065', 248.52)) AS opening(journal_id, account_id, amount)
+UNION ALL
+SELECT journal_id, account.account_id, -amount
+FROM (VALUES ('00000000-0000-0000-0000-000000000069'::uuid, 500.34),
+             ('00000000-0000-0000-0000-000000000068', 171),
+             ('00000000-0000-0000-0000-000000000062', 32.32),
+             ('00000000-0000-0000-0000-000000000065', 248.52)) AS opening(journal_id, amount)
+         JOIN account ON account.type = 'cash';
+
+-- открытая резервация
 INSERT INTO reservation (user_id, order_id, service_id, cost, comment)
 VALUES ('7a13445c-d6df-4111-abc0-abb12f610068',
         '983e8792-6736-41bd-9f1a-7c67f8501645',
@@ -19,30 +51,53 @@ VALUES ('7a13445c-d6df-4111-abc0-abb12f610068',
         50,
         'reserve 50');
 
-INSERT INTO history_reservation (user_id, order_id, service_id, cost, status)
-VALUES ('7a13445c-d6df-4111-abc0-abb12f610065',
-        '983e8792-6736-41bd-9f1a-7c67f8501645',
-        '34e16535-480c-43f8-95a9-b7a503499af2',
-        -50.34,
-        'confirm'),
-       ('7a13445c-d6df-4111-abc0-abb12f610065',
-        '983e8792-6736-41bd-9f1a-7c67f8501645',
-        '34e16535-480c-43f8-95a9-b7a503499af2',
-        -20.40,
-        'confirm'),
-       ('7a13445c-d6df-4111-abc0-abb12f610065',
-        '983e8792-6736-41bd-9f1a-7c67f8501645',
-        '34e16535-480c-43f8-95a9-b7a503499af0',
-        -120.78,
-        'confirm'),
-       ('7a13445c-d6df-4111-abc0-abb12f610065',
-        '983e8792-6736-41bd-9f1a-7c67f8501645',
-        '34e16535-480c-43f8-95a9-b7a503499af1',
-        -57,
-        'confirm'),
-       ('7a13445c-d6df-4111-abc0-abb12f610065',
-        '983e8792-6736-41bd-9f1a-7c67f8501645',
-        '34e16535-480c-43f8-95a9-b7a503499af1',
-        7,
-        'cancel')
-;
\ No newline at end of file
+-- резервации с подтверждением и отменой
+INSERT INTO journal (journal_id, operation, order_id, service_id, comment)
+VALUES ('00000000-0000-0000-0000-000000000100', 'reserve', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af2', 'reserve 50'),
+       ('00000000-0000-0000-0000-000000000101', 'reserve', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af2', ''),
+       ('00000000-0000-0000-0000-000000000102', 'reserve', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af2', ''),
+       ('00000000-0000-0000-0000-000000000103', 'reserve', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af0', ''),
+       ('00000000-0000-0000-0000-000000000104', 'reserve', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af1', ''),
+       ('00000000-0000-0000-0000-000000000105', 'reserve', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af1', ''),
+       ('00000000-0000-0000-0000-000000000201', 'confirm', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af2', ''),
+       ('00000000-0000-0000-0000-000000000202', 'confirm', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af2', ''),
+       ('00000000-0000-0000-0000-000000000203', 'confirm', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af0', ''),
+       ('00000000-0000-0000-0000-000000000204', 'confirm', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af1', ''),
+       ('00000000-0000-0000-0000-000000000205', 'cancel', '983e8792-6736-41bd-9f1a-7c67f8501645',
+        '34e16535-480c-43f8-95a9-b7a503499af1', '');
+
+INSERT INTO posting (journal_id, account_id, amount)
+VALUES ('00000000-0000-0000-0000-000000000100', 'acc00000-0000-0000-0000-000000000068', -50),
+       ('00000000-0000-0000-0000-000000000100', 'acc00000-0000-0000-0000-000000001068', 50),
+
+       ('00000000-0000-0000-0000-000000000101', 'acc00000-0000-0000-0000-000000000065', -50.34),
+       ('00000000-0000-0000-0000-000000000101', 'acc00000-0000-0000-0000-000000001065', 50.34),
+       ('00000000-0000-0000-0000-000000000102', 'acc00000-0000-0000-0000-000000000065', -20.40),
+       ('00000000-0000-0000-0000-000000000102', 'acc00000-0000-0000-0000-000000001065', 20.40),
+       ('00000000-0000-0000-0000-000000000103', 'acc00000-0000-0000-0000-000000000065', -120.78),
+       ('00000000-0000-0000-0000-000000000103', 'acc00000-0000-0000-0000-000000001065', 120.78),
+       ('00000000-0000-0000-0000-000000000104', 'acc00000-0000-0000-0000-000000000065', -57),
+       ('00000000-0000-0000-0000-000000000104', 'acc00000-0000-0000-0000-000000001065', 57),
+       ('00000000-0000-0000-0000-000000000105', 'acc00000-0000-0000-0000-000000000065', -7),
+       ('00000000-0000-0000-0000-000000000105', 'acc00000-0000-0000-0000-000000001065', 7),
+
+       ('00000000-0000-0000-0000-000000000201', 'acc00000-0000-0000-0000-000000001065', -50.34),
+       ('00000000-0000-0000-0000-000000000201', 'acc00000-0000-0000-0000-0000000002f2', 50.34),
+       ('00000000-0000-0000-0000-000000000202', 'acc00000-0000-0000-0000-000000001065', -20.40),
+       ('00000000-0000-0000-0000-000000000202', 'acc00000-0000-0000-0000-0000000002f2', 20.40),
+       ('00000000-0000-0000-0000-000000000203', 'acc00000-0000-0000-0000-000000001065', -120.78),
+       ('00000000-0000-0000-0000-000000000203', 'acc00000-0000-0000-0000-0000000002f0', 120.78),
+       ('00000000-0000-0000-0000-000000000204', 'acc00000-0000-0000-0000-000000001065', -57),
+       ('00000000-0000-0000-0000-000000000204', 'acc00000-0000-0000-0000-0000000002f1', 57),
+       ('00000000-0000-0000-0000-000000000205', 'acc00000-0000-0000-0000-000000001065', -7),
+       ('00000000-0000-0000-0000-000000000205', 'acc00000-0000-0000-0000-000000000065', 7);